@@ -0,0 +1,187 @@
+package cell_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	archive_helper "code.cloudfoundry.org/archiver/extractor/test_helper"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/fixtures"
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/fakeloggregator"
+	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+	"github.com/tedsuo/ifrit/grouper"
+)
+
+var _ = Describe("App Logs", func() {
+	var (
+		ifritRuntime        ifrit.Process
+		ingress             *fakeloggregator.Ingress
+		fileServerStaticDir string
+		repConfigs          []func(*repconfig.RepConfig)
+		logGuid             string
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+
+		logGuid = helpers.GenerateGuid()
+		ingress = componentMaker.Loggregator()
+		repConfigs = nil
+	})
+
+	JustBeforeEach(func() {
+		var fileServer ifrit.Runner
+		fileServer, fileServerStaticDir = componentMaker.FileServer()
+
+		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Kill, grouper.Members{
+			{"loggregator", ingress},
+			{"file-server", fileServer},
+			{"rep", componentMaker.Rep(repConfigs...)},
+			{"auctioneer", componentMaker.Auctioneer()},
+		}))
+
+		archive_helper.CreateZipArchive(
+			filepath.Join(fileServerStaticDir, "lrp.zip"),
+			fixtures.GoServerApp(),
+		)
+	})
+
+	AfterEach(func() {
+		helpers.StopProcesses(ifritRuntime)
+	})
+
+	Context("for a task", func() {
+		var task *models.Task
+
+		JustBeforeEach(func() {
+			task = helpers.TaskCreateRequest(
+				helpers.GenerateGuid(),
+				&models.RunAction{
+					User:      "vcap",
+					Path:      "sh",
+					Args:      []string{"-c", "echo out-line; echo err-line 1>&2"},
+					LogSource: "APP/TASK/my-task",
+				},
+			)
+			task.LogGuid = logGuid
+			task.LogSource = "CELL"
+
+			err := bbsClient.DesireTask(lgr, task.TaskGuid, task.Domain, task.TaskDefinition)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("sends the task's stdout and stderr to loggregator", func() {
+			Eventually(helpers.TaskStatePoller(lgr, bbsClient, task.TaskGuid, nil)).Should(Equal(models.Task_Completed))
+
+			Eventually(helpers.AppLogs(ingress, logGuid)).Should(SatisfyAll(
+				helpers.ContainLogLine("APP/TASK/my-task", "^out-line$"),
+				helpers.ContainLogLine("APP/TASK/my-task", "^err-line$"),
+			))
+		})
+
+		It("sends the container lifecycle lines to loggregator", func() {
+			Eventually(helpers.AppLogs(ingress, logGuid)).Should(SatisfyAll(
+				helpers.ContainLogLine("CELL", "creating container for instance"),
+				helpers.ContainLogLine("CELL", "successfully created container for instance"),
+			))
+		})
+	})
+
+	Context("for an LRP", func() {
+		var lrp *models.DesiredLRP
+
+		JustBeforeEach(func() {
+			lrp = helpers.DefaultLRPCreateRequest(componentMaker.Addresses(), helpers.GenerateGuid(), logGuid, 2)
+			lrp.LogSource = "CELL"
+			lrp.Setup = models.WrapAction(&models.DownloadAction{
+				Artifact: "droplet",
+				From:     fmt.Sprintf("http://%s/v1/static/%s", componentMaker.Addresses().FileServer, "lrp.zip"),
+				To:       "/tmp/diego",
+				User:     "vcap",
+			})
+			lrp.Action = models.WrapAction(&models.RunAction{
+				User:      "vcap",
+				Path:      "/tmp/diego/go-server",
+				Env:       []*models.EnvironmentVariable{{"PORT", "8080"}},
+				LogSource: "APP/PROC/WEB",
+			})
+
+			err := bbsClient.DesireLRP(lgr, lrp)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("sends the app's output to loggregator for every instance", func() {
+			Eventually(helpers.AppLogs(ingress, logGuid)).Should(SatisfyAll(
+				helpers.ContainLogLineFromInstance("APP/PROC/WEB", 0, "listening..."),
+				helpers.ContainLogLineFromInstance("APP/PROC/WEB", 1, "listening..."),
+			))
+		})
+
+		It("sends the setup download progress to loggregator", func() {
+			Eventually(helpers.AppLogs(ingress, logGuid)).Should(helpers.ContainLogLine("CELL", "^Downloading droplet"))
+		})
+
+		Context("when the app logs faster than the rep allows", func() {
+			BeforeEach(func() {
+				repConfigs = append(repConfigs, func(cfg *repconfig.RepConfig) {
+					cfg.MaxLogLinesPerSecond = 5
+				})
+			})
+
+			JustBeforeEach(func() {
+				noisyLRP := helpers.LightweightLRPCreateRequest(componentMaker.Addresses(), helpers.GenerateGuid())
+				noisyLRP.LogGuid = logGuid
+				noisyLRP.Action = models.WrapAction(&models.RunAction{
+					User:      "vcap",
+					Path:      "sh",
+					Args:      []string{"-c", "while true; do echo noisy; done"},
+					LogSource: "APP/PROC/NOISY",
+				})
+
+				err := bbsClient.DesireLRP(lgr, noisyLRP)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("reports that the instance exceeded the log rate limit", func() {
+				Eventually(helpers.AppLogs(ingress, logGuid)).Should(
+					helpers.ContainLogLine("APP/PROC/NOISY", helpers.LogRateLimitExceededPattern(5)),
+				)
+			})
+		})
+	})
+
+	Context("for an LRP whose health check never passes", func() {
+		JustBeforeEach(func() {
+			lrp := helpers.NewLRP(componentMaker.Addresses()).
+				WithLogGuid(logGuid).
+				WithAction(&models.RunAction{
+					User: "vcap",
+					Path: "sh",
+					Args: []string{"-c", "while true; do sleep 1; done"},
+				}).
+				WithMonitor(&models.RunAction{
+					User: "vcap",
+					Path: "false",
+				}).
+				WithStartTimeout(2 * time.Second).
+				Build()
+
+			err := bbsClient.DesireLRP(lgr, lrp)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("sends the health check failure to loggregator", func() {
+			Eventually(helpers.AppLogs(ingress, logGuid)).Should(helpers.ContainLogLine("HEALTH", "health check never passed"))
+		})
+	})
+})
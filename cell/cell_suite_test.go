@@ -27,8 +27,11 @@ import (
 	"code.cloudfoundry.org/bbs/serviceclient"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/bbsevents"
 	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/leakcheck"
 	"code.cloudfoundry.org/inigo/helpers/portauthority"
+	"code.cloudfoundry.org/inigo/helpers/timeline"
 	"code.cloudfoundry.org/inigo/inigo_announcement_server"
 	"code.cloudfoundry.org/inigo/world"
 )
@@ -42,6 +45,9 @@ var (
 	bbsServiceClient                    serviceclient.ServiceClient
 	lgr                                 lager.Logger
 	certDepot                           string
+	bbsEvents                           *bbsevents.Recorder
+	timelineRecorder                    *timeline.Recorder
+	leakChecker                         *leakcheck.Checker
 )
 
 func overrideConvergenceRepeatInterval(conf *bbsconfig.BBSConfig) {
@@ -105,7 +111,9 @@ var _ = SynchronizedBeforeSuite(func() []byte {
 	Expect(err).NotTo(HaveOccurred())
 
 	componentMaker = world.MakeComponentMaker(builtArtifacts, addresses, allocator, certAuthority)
+	leakChecker = helpers.NewLeakChecker(componentMaker, leakcheck.PortRange{From: uint16(startPort), To: uint16(endPort)})
 	componentMaker.Setup()
+	helpers.RegisterStopTimeoutDiagnostics(componentMaker.ComponentLogs(), componentMaker.ComponentStats())
 })
 
 var _ = AfterSuite(func() {
@@ -117,6 +125,17 @@ var _ = AfterSuite(func() {
 })
 
 var _ = BeforeEach(func() {
+	helpers.SnapshotLeaks(leakChecker)
+})
+
+var _ = BeforeEach(func() {
+	// the recorders are only started once the components they need are up
+	bbsEvents = nil
+	timelineRecorder = nil
+
+	componentMaker.ComponentLogs().Reset()
+	componentMaker.ComponentStats().Reset()
+
 	plumbing = ginkgomon.Invoke(grouper.NewOrdered(os.Kill, grouper.Members{
 		{"initial-services", grouper.NewParallel(os.Kill, grouper.Members{
 			{"sql", componentMaker.SQL()},
@@ -136,10 +155,28 @@ var _ = BeforeEach(func() {
 	bbsClient = componentMaker.BBSClient()
 	bbsServiceClient = componentMaker.BBSServiceClient(lgr)
 
+	bbsEvents = bbsevents.NewRecorder(lgr, bbsClient)
+	timelineRecorder = timeline.NewRecorder(bbsEvents, componentMaker.ComponentLogs())
+	timelineRecorder.Start()
+	Expect(bbsEvents.Start()).To(Succeed())
+
 	inigo_announcement_server.Start(os.Getenv("EXTERNAL_ADDRESS"))
 })
 
+var _ = JustAfterEach(func() {
+	helpers.DumpClusterStateOnFailure(lgr, componentMaker)
+})
+
 var _ = AfterEach(func() {
+	if bbsEvents != nil {
+		bbsEvents.Stop()
+	}
+	if timelineRecorder != nil {
+		helpers.ExportTimelineOnFailure(timelineRecorder)
+	}
+	helpers.ExportComponentLogsOnFailure(componentMaker.ComponentLogs())
+	helpers.ReportComponentStats(componentMaker.ComponentStats())
+
 	inigo_announcement_server.Stop()
 
 	destroyContainerErrors := helpers.CleanupGarden(gardenClient)
@@ -155,6 +192,10 @@ var _ = AfterEach(func() {
 	)
 })
 
+var _ = AfterEach(func() {
+	helpers.CheckForLeaks(leakChecker)
+})
+
 func TestCell(t *testing.T) {
 	helpers.RegisterDefaultTimeouts()
 
@@ -203,6 +244,9 @@ func CompileTestedExecutables() world.BuiltExecutables {
 	if runtime.GOOS != "windows" {
 		builtExecutables["router"], err = gexec.BuildIn(os.Getenv("ROUTER_GOPATH"), "code.cloudfoundry.org/gorouter", "-race")
 		Expect(err).NotTo(HaveOccurred())
+
+		builtExecutables["tcp-router"], err = gexec.BuildIn(os.Getenv("ROUTER_GOPATH"), "code.cloudfoundry.org/cf-tcp-router", "-race")
+		Expect(err).NotTo(HaveOccurred())
 	}
 
 	builtExecutables["routing-api"], err = gexec.BuildIn(os.Getenv("ROUTING_API_GOPATH"), "code.cloudfoundry.org/routing-api/cmd/routing-api", "-race")
@@ -73,7 +73,7 @@ var _ = Describe("Convergence to desired state", func() {
 	})
 
 	AfterEach(func() {
-		By("Stopping all the processes")
+		timelineRecorder.By("Stopping all the processes")
 		helpers.StopProcesses(auctioneer, rep, ifritRuntime)
 	})
 
@@ -88,42 +88,59 @@ var _ = Describe("Convergence to desired state", func() {
 			BeforeEach(func() {
 				rep = ginkgomon.Invoke(componentMaker.Rep())
 
-				By("restarting the bbs with smaller convergeRepeatInterval")
+				timelineRecorder.By("restarting the bbs with smaller convergeRepeatInterval")
 				ginkgomon.Interrupt(bbsProcess)
 				bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 					overrideConvergenceRepeatInterval,
 				))
 
-				By("creating and ActualLRP")
+				timelineRecorder.By("creating and ActualLRP")
 				err := bbsClient.DesireLRP(lgr, helpers.DefaultLRPCreateRequest(componentMaker.Addresses(), processGuid, appId, 2))
 				Expect(err).NotTo(HaveOccurred())
 				Eventually(runningLRPsPoller).Should(HaveLen(2))
 				Eventually(helloWorldInstancePoller).Should(Equal([]string{"0", "1"}))
 
-				By("collecting the ActualLRP instance guids")
+				timelineRecorder.By("collecting the ActualLRP instance guids")
 				initialActuals := runningLRPsPoller()
 				initialInstanceGuids = []string{initialActuals[0].InstanceGuid, initialActuals[1].InstanceGuid}
 			})
 
 			It("marks the LRPs as Suspect until the rep comes back, and then marks the LRPs as Ordinary", func() {
-				By("killing the lone rep")
+				timelineRecorder.By("killing the lone rep")
 				ginkgomon.Interrupt(rep)
 
-				By("Asserting that the LRPs are marked as Suspect")
+				timelineRecorder.By("Asserting that the LRPs are marked as Suspect")
 				Eventually(runningLRPsPresencePoller(models.ActualLRP_Suspect)).Should(HaveLen(2))
 
-				By("bringing back the original rep")
+				timelineRecorder.By("bringing back the original rep")
 				rep = ginkgomon.Invoke(componentMaker.Rep())
 
 				Eventually(runningLRPsPoller).Should(HaveLen(2))
 				Eventually(helloWorldInstancePoller).Should(Equal([]string{"0", "1"}))
 
-				By("Asserting that the LRPs marked as Ordinary")
+				timelineRecorder.By("Asserting that the LRPs marked as Ordinary")
 				currentActuals := runningLRPsPoller()
 				instanceGuids := []string{currentActuals[0].InstanceGuid, currentActuals[1].InstanceGuid}
 				Expect(instanceGuids).NotTo(ContainElement(initialInstanceGuids[0]))
 				Expect(instanceGuids).NotTo(ContainElement(initialInstanceGuids[1]))
 				Eventually(runningLRPsPresencePoller(models.ActualLRP_Ordinary)).Should(HaveLen(2))
+
+				timelineRecorder.By("Asserting that convergence left no Suspect or orphaned rows in the database")
+				db := componentMaker.BBSDB(lgr)
+				defer db.Close()
+
+				Eventually(func() ([]models.ActualLRP_Presence, error) {
+					lrps, err := db.ActualLRPs(processGuid)
+					if err != nil {
+						return nil, err
+					}
+					presences := []models.ActualLRP_Presence{}
+					for _, lrp := range lrps {
+						presences = append(presences, lrp.Presence)
+					}
+					return presences, nil
+				}).Should(Equal([]models.ActualLRP_Presence{models.ActualLRP_Ordinary, models.ActualLRP_Ordinary}))
+				Expect(db.OrphanedActualLRPs()).To(BeEmpty())
 			})
 
 			Context("and a second rep is running", func() {
@@ -140,13 +157,13 @@ var _ = Describe("Convergence to desired state", func() {
 				})
 
 				It("marks the LRPs as Suspect until they get started on the other rep", func() {
-					By("killing the original rep")
+					timelineRecorder.By("killing the original rep")
 					ginkgomon.Interrupt(rep)
 
-					By("Asserting that the LRPs are marked as Suspect")
+					timelineRecorder.By("Asserting that the LRPs are marked as Suspect")
 					Eventually(runningLRPsPresencePoller(models.ActualLRP_Suspect)).Should(HaveLen(2))
 
-					By("Asserting that the LRPs are started on the second rep")
+					timelineRecorder.By("Asserting that the LRPs are started on the second rep")
 					Eventually(func() bool {
 						secondActualLRPs := runningLRPsPoller()
 						if len(secondActualLRPs) != 2 {
@@ -161,7 +178,7 @@ var _ = Describe("Convergence to desired state", func() {
 
 		Context("when a converger is running without a rep", func() {
 			BeforeEach(func() {
-				By("restarting the bbs with smaller convergeRepeatInterval")
+				timelineRecorder.By("restarting the bbs with smaller convergeRepeatInterval")
 				ginkgomon.Interrupt(bbsProcess)
 				bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 					overrideConvergenceRepeatInterval,
@@ -193,7 +210,7 @@ var _ = Describe("Convergence to desired state", func() {
 
 	Describe("Auctioneer Fault Tolerance", func() {
 		BeforeEach(func() {
-			By("restarting the bbs with smaller convergeRepeatInterval")
+			timelineRecorder.By("restarting the bbs with smaller convergeRepeatInterval")
 			ginkgomon.Interrupt(bbsProcess)
 			bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 				overrideConvergenceRepeatInterval,
@@ -15,6 +15,8 @@ import (
 	"code.cloudfoundry.org/guardian/gqt/runner"
 	"code.cloudfoundry.org/inigo/fixtures"
 	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/bbsstate"
+	"code.cloudfoundry.org/inigo/world"
 	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
 	"code.cloudfoundry.org/tlsconfig"
 	"github.com/tedsuo/ifrit"
@@ -37,8 +39,8 @@ var _ = Describe("Evacuation", func() {
 		cellBRepAddr       string
 		cellBRepSecureAddr string
 
-		cellARepRunner *ginkgomon.Runner
-		cellBRepRunner *ginkgomon.Runner
+		cellARepRunner *world.ComponentRunner
+		cellBRepRunner *world.ComponentRunner
 
 		cellA ifrit.Process
 		cellB ifrit.Process
@@ -58,7 +60,7 @@ var _ = Describe("Evacuation", func() {
 
 		fileServer, fileServerStaticDir := componentMaker.FileServer()
 
-		By("restarting the bbs with smaller convergeRepeatInterval")
+		timelineRecorder.By("restarting the bbs with smaller convergeRepeatInterval")
 		ginkgomon.Interrupt(bbsProcess)
 		bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 			overrideConvergenceRepeatInterval,
@@ -133,11 +135,11 @@ var _ = Describe("Evacuation", func() {
 	})
 
 	It("handles evacuation", func() {
-		By("desiring an LRP")
+		timelineRecorder.By("desiring an LRP")
 		err := bbsClient.DesireLRP(lgr, lrp)
 		Expect(err).NotTo(HaveOccurred())
 
-		By("running an actual LRP instance")
+		timelineRecorder.By("running an actual LRP instance")
 		Eventually(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)).Should(Equal(models.ActualLRPStateRunning))
 		Eventually(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost)).Should(Equal(http.StatusOK))
 
@@ -145,38 +147,45 @@ var _ = Describe("Evacuation", func() {
 		lrps, err := bbsClient.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: processGuid, Index: &index})
 		Expect(err).NotTo(HaveOccurred())
 		Expect(len(lrps)).To(Equal(1))
-		Expect(lrps[0].Presence).NotTo(Equal(models.ActualLRP_Evacuating))
+		Expect(lrps[0]).NotTo(bbsstate.HavePresence(models.ActualLRP_Evacuating))
 
 		var evacuatingRepPort uint16
-		var evacuatingRepRunner *ginkgomon.Runner
+		var evacuatingRepRunner *world.ComponentRunner
+		var otherCellID string
 
 		switch lrps[0].CellId {
 		case cellAID:
 			evacuatingRepRunner = cellARepRunner
 			evacuatingRepPort = cellPortsStart
+			otherCellID = cellBID
 		case cellBID:
 			evacuatingRepRunner = cellBRepRunner
 			evacuatingRepPort = cellPortsStart + 2
+			otherCellID = cellAID
 		default:
 			panic("what? who?")
 		}
 
-		By("posting the evacuation endpoint")
+		timelineRecorder.By("posting the evacuation endpoint")
 		// Rep admin endpoint verifies and validate 127.0.0.1 for IP SAN
 		resp, err := httpClient.Post(fmt.Sprintf("https://127.0.0.1:%d/evacuate", evacuatingRepPort), "text/html", nil)
 		Expect(err).NotTo(HaveOccurred())
 		resp.Body.Close()
 		Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
 
-		By("staying routable so long as its rep is alive")
+		timelineRecorder.By("staying routable so long as its rep is alive")
 		Eventually(func() int {
 			Expect(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost)()).To(Equal(http.StatusOK))
 			return evacuatingRepRunner.ExitCode()
 		}).Should(Equal(0))
 
-		By("running immediately after the rep exits and is routable")
+		timelineRecorder.By("running immediately after the rep exits and is routable")
 		Expect(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)()).To(Equal(models.ActualLRPStateRunning))
 		Consistently(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost)).Should(Equal(http.StatusOK))
+
+		lrps, err = bbsClient.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: processGuid, Index: &index})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lrps).To(ContainElement(bbsstate.BeRunningOn(otherCellID)))
 	})
 
 	Context("when garden Destroy hangs", func() {
@@ -204,10 +213,10 @@ var _ = Describe("Evacuation", func() {
 			err := bbsClient.DesireLRP(lgr, lrp)
 			Expect(err).NotTo(HaveOccurred())
 
-			By("running an actual LRP instance")
+			timelineRecorder.By("running an actual LRP instance")
 			Eventually(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)).Should(Equal(models.ActualLRPStateRunning))
 
-			By("posting the evacuation endpoint")
+			timelineRecorder.By("posting the evacuation endpoint")
 			// Rep admin endpoint verifies and validate 127.0.0.1 for IP SAN
 			resp, err := httpClient.Post(fmt.Sprintf("https://127.0.0.1:%d/evacuate", cellPortsStart), "text/html", nil)
 			Expect(err).NotTo(HaveOccurred())
@@ -10,7 +10,6 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -20,14 +19,13 @@ import (
 	archive_helper "code.cloudfoundry.org/archiver/extractor/test_helper"
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/bbs/models"
-	logging "code.cloudfoundry.org/diego-logging-client"
-	"code.cloudfoundry.org/diego-logging-client/testhelpers"
 	"code.cloudfoundry.org/durationjson"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
 	"code.cloudfoundry.org/inigo/fixtures"
 	"code.cloudfoundry.org/inigo/helpers"
 	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/fakeloggregator"
 	"code.cloudfoundry.org/inigo/world"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
@@ -183,7 +181,7 @@ var _ = Describe("InstanceIdentity", func() {
 		Expect(block).NotTo(BeNil())
 		containerKey := block.Bytes
 
-		By("verify the certificate is signed properly")
+		timelineRecorder.By("verify the certificate is signed properly")
 		cert := parseCertificate(containerCert, false)
 		Expect(cert.Subject.OrganizationalUnit).To(Equal(organizationalUnit))
 		Expect(cert.NotAfter.Sub(cert.NotBefore)).To(Equal(validityPeriod))
@@ -194,14 +192,14 @@ var _ = Describe("InstanceIdentity", func() {
 		caCert := parseCertificate(caCertContent, true)
 		verifyCertificateIsSignedBy(cert, caCert)
 
-		By("verify the private key matches the cert public key")
+		timelineRecorder.By("verify the private key matches the cert public key")
 		key, err := x509.ParsePKCS1PrivateKey(containerKey)
 		Expect(err).NotTo(HaveOccurred())
 		Expect(&key.PublicKey).To(Equal(cert.PublicKey))
 	}
 
 	verifyCertAndKeyArePresentForTask := func(certPath, keyPath string, organizationalUnit []string) {
-		By("running the task and getting the concatenated pem cert and key")
+		timelineRecorder.By("running the task and getting the concatenated pem cert and key")
 		var commandTemplate string
 		if runtime.GOOS == "windows" {
 			commandTemplate = "cat %s,%s"
@@ -350,10 +348,14 @@ var _ = Describe("InstanceIdentity", func() {
 			configRepCerts       func(cfg *config.RepConfig)
 			enableContainerProxy func(cfg *config.RepConfig)
 			loggregatorConfig    func(cfg *config.RepConfig)
-			testIngressServer    *testhelpers.TestIngressServer
+			ingress              *fakeloggregator.Ingress
+			ingressProcess       ifrit.Process
+			envoyConfigDir       string
 		)
 
 		BeforeEach(func() {
+			envoyConfigDir = world.TempDir("envoy_config")
+
 			configRepCerts = func(cfg *config.RepConfig) {
 				cfg.InstanceIdentityCredDir = credDir
 				cfg.InstanceIdentityCAPath = intermediateCACertPath
@@ -365,37 +367,15 @@ var _ = Describe("InstanceIdentity", func() {
 				config.EnableContainerProxy = true
 				config.EnvoyConfigRefreshDelay = durationjson.Duration(time.Second)
 				config.ContainerProxyPath = os.Getenv("ENVOY_PATH")
-
-				tmpdir := world.TempDir("envoy_config")
-
-				config.ContainerProxyConfigPath = tmpdir
+				config.ContainerProxyConfigPath = envoyConfigDir
 			}
 
-			fixturesPath := path.Join(os.Getenv("GOPATH"), "src/code.cloudfoundry.org/inigo/fixtures/certs")
-			metronCAFile := path.Join(fixturesPath, "metron", "CA.crt")
-			metronClientCertFile := path.Join(fixturesPath, "metron", "client.crt")
-			metronClientKeyFile := path.Join(fixturesPath, "metron", "client.key")
-			metronServerCertFile := path.Join(fixturesPath, "metron", "metron.crt")
-			metronServerKeyFile := path.Join(fixturesPath, "metron", "metron.key")
-			var err error
-			testIngressServer, err = testhelpers.NewTestIngressServer(metronServerCertFile, metronServerKeyFile, metronCAFile)
-			Expect(err).NotTo(HaveOccurred())
-
-			Expect(testIngressServer.Start()).To(Succeed())
-
-			metricsPort, err := testIngressServer.Port()
-			Expect(err).NotTo(HaveOccurred())
+			// the rep always sends its logs and metrics to the loggregator
+			// stand-in
+			ingress = componentMaker.Loggregator()
+			ingressProcess = ginkgomon.Invoke(ingress)
 
 			loggregatorConfig = func(cfg *config.RepConfig) {
-				cfg.LoggregatorConfig = logging.Config{
-					BatchFlushInterval: 10 * time.Millisecond,
-					BatchMaxSize:       1,
-					UseV2API:           true,
-					APIPort:            metricsPort,
-					CACertPath:         metronCAFile,
-					KeyPath:            metronClientKeyFile,
-					CertPath:           metronClientCertFile,
-				}
 				cfg.ContainerMetricsReportInterval = durationjson.Duration(5 * time.Second)
 			}
 
@@ -404,11 +384,11 @@ var _ = Describe("InstanceIdentity", func() {
 			logger := lagertest.NewTestLogger("metron-agent")
 			metronAgent = ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 				close(ready)
-				testMetricsChan, signalMetricsChan := testhelpers.TestMetricChan(testIngressServer.Receivers())
-				defer close(signalMetricsChan)
+				envelopes, unsubscribe := ingress.Subscribe()
+				defer unsubscribe()
 				for {
 					select {
-					case envelope := <-testMetricsChan:
+					case envelope := <-envelopes:
 						if log := envelope.GetLog(); log != nil {
 							logger.Info("received-data", lager.Data{"message": string(log.GetPayload())})
 						}
@@ -420,7 +400,8 @@ var _ = Describe("InstanceIdentity", func() {
 		})
 
 		AfterEach(func() {
-			testIngressServer.Stop()
+			ginkgomon.Interrupt(ingressProcess)
+			os.RemoveAll(envoyConfigDir)
 		})
 
 		connect := func() error {
@@ -761,11 +742,11 @@ var _ = Describe("InstanceIdentity", func() {
 
 						metronAgent = ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 							close(ready)
-							testMetricsChan, signalMetricsChan := testhelpers.TestMetricChan(testIngressServer.Receivers())
-							defer close(signalMetricsChan)
+							envelopes, unsubscribe := ingress.Subscribe()
+							defer unsubscribe()
 							for {
 								select {
-								case envelope := <-testMetricsChan:
+								case envelope := <-envelopes:
 									metric := getContainerMetricEnvelope(logger, envelope)
 									if metric == nil {
 										continue
@@ -866,20 +847,19 @@ var _ = Describe("InstanceIdentity", func() {
 		})
 
 		Context("and envoy takes longer to start", func() {
+			var sleepyEnvoyDir string
+
 			BeforeEach(func() {
 				if runtime.GOOS == "windows" {
 					Skip("TODO: figure out a way to create .exe or .bat file that emulates the slep behavior in windows")
 				}
-				dir := createSleepyEnvoy()
+				sleepyEnvoyDir = createSleepyEnvoy()
 
 				enableContainerProxy = func(config *config.RepConfig) {
 					config.EnableContainerProxy = true
 					config.EnvoyConfigRefreshDelay = durationjson.Duration(time.Second)
-					config.ContainerProxyPath = dir
-
-					tmpdir := world.TempDir("envoy_config")
-
-					config.ContainerProxyConfigPath = tmpdir
+					config.ContainerProxyPath = sleepyEnvoyDir
+					config.ContainerProxyConfigPath = envoyConfigDir
 				}
 
 				enableDeclarativeHealthChecks := func(config *config.RepConfig) {
@@ -891,6 +871,10 @@ var _ = Describe("InstanceIdentity", func() {
 				rep = componentMaker.Rep(configRepCerts, enableContainerProxy, loggregatorConfig, enableDeclarativeHealthChecks)
 			})
 
+			AfterEach(func() {
+				os.RemoveAll(sleepyEnvoyDir)
+			})
+
 			JustBeforeEach(func() {
 				err := bbsClient.DesireLRP(lgr, lrp)
 				Expect(err).NotTo(HaveOccurred())
@@ -974,7 +958,7 @@ func memoryInBytes(memoryMb uint64) uint64 {
 }
 
 func getContainerInternalAddress(client bbs.Client, processGuid string, port uint32, tls bool) string {
-	By("getting the internal ip address of the container")
+	timelineRecorder.By("getting the internal ip address of the container")
 	lrps, err := client.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: processGuid})
 	Expect(err).NotTo(HaveOccurred())
 	Expect(lrps).To(HaveLen(1))
@@ -1011,35 +995,22 @@ func runTaskAndGetCommandOutput(command string, organizationalUnits []string) st
 		resultFile = "/home/vcap/thingy"
 	}
 
-	expectedTask := helpers.TaskCreateRequestWithCertificateProperties(
-		guid,
-		&models.RunAction{
+	task := helpers.NewTask(guid).
+		WithAction(&models.RunAction{
 			User: "vcap",
 			Path: shell,
 			Args: args,
-		},
-		&models.CertificateProperties{
+		}).
+		WithResultFile(resultFile).
+		WithCertificateProperties(&models.CertificateProperties{
 			OrganizationalUnit: organizationalUnits,
-		},
-	)
-	expectedTask.ResultFile = resultFile
-
-	err := bbsClient.DesireTask(lgr, expectedTask.TaskGuid, expectedTask.Domain, expectedTask.TaskDefinition)
-	Expect(err).NotTo(HaveOccurred())
-
-	var task *models.Task
-	Eventually(func() interface{} {
-		var err error
-
-		task, err = bbsClient.TaskByGuid(lgr, guid)
-		Expect(err).NotTo(HaveOccurred())
-
-		return task.State
-	}).Should(Equal(models.Task_Completed))
+		}).
+		Build()
 
-	Expect(task.Failed).To(BeFalse(), "Task Should've succeeded")
+	result, err := helpers.RunTaskToCompletion(lgr, bbsClient, task)
+	Expect(err).NotTo(HaveOccurred(), "Task Should've succeeded")
 
-	return task.Result
+	return result
 }
 
 func parseCertificate(cert []byte, pemEncoded bool) *x509.Certificate {
@@ -2,6 +2,8 @@ package cell_test
 
 import (
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -15,11 +17,15 @@ import (
 	"code.cloudfoundry.org/durationjson"
 	"code.cloudfoundry.org/inigo/fixtures"
 	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/routedistribution"
+	"code.cloudfoundry.org/inigo/helpers/routeservice"
+	"code.cloudfoundry.org/inigo/helpers/servicediscovery"
+	"code.cloudfoundry.org/inigo/world"
 	"code.cloudfoundry.org/lager"
 	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
 	routeemitterconfig "code.cloudfoundry.org/route-emitter/cmd/route-emitter/config"
-	routingapi "code.cloudfoundry.org/route-emitter/cmd/route-emitter/runners"
 	"code.cloudfoundry.org/routing-info/cfroutes"
+	"code.cloudfoundry.org/routing-info/internalroutes"
 	"code.cloudfoundry.org/routing-info/tcp_routes"
 	"code.cloudfoundry.org/tlsconfig"
 	. "github.com/onsi/ginkgo"
@@ -32,11 +38,13 @@ import (
 var _ = Describe("LocalRouteEmitter", func() {
 	var (
 		processGuid                                  string
-		ifritRuntime, cellAProcess, cellBProcess     ifrit.Process
+		ifritRuntime, routerProcess                  ifrit.Process
+		cellAProcess, cellBProcess                   ifrit.Process
 		archiveFiles                                 []archive_helper.ArchiveFile
 		fileServerStaticDir                          string
 		cellAID, cellBID, cellARepAddr, cellBRepAddr string
 		routeEmitterConfigs                          []func(*routeemitterconfig.RouteEmitterConfig)
+		routerConfigs                                []func(*world.RouterConfig)
 		cellAPort, cellBPort                         uint16
 	)
 
@@ -45,6 +53,8 @@ var _ = Describe("LocalRouteEmitter", func() {
 			Skip(" not yet working on windows")
 		}
 		processGuid = helpers.GenerateGuid()
+		routerConfigs = nil
+		routeEmitterConfigs = nil
 
 		var fileServer ifrit.Runner
 		fileServer, fileServerStaticDir = componentMaker.FileServer()
@@ -62,7 +72,6 @@ var _ = Describe("LocalRouteEmitter", func() {
 		cellBRepAddr = fmt.Sprintf("0.0.0.0:%d", cellBPort)
 
 		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Kill, grouper.Members{
-			{"router", componentMaker.Router()},
 			{"file-server", fileServer},
 			{"auctioneer", componentMaker.Auctioneer()},
 		}))
@@ -71,10 +80,12 @@ var _ = Describe("LocalRouteEmitter", func() {
 	})
 
 	AfterEach(func() {
-		helpers.StopProcesses(ifritRuntime, cellAProcess, cellBProcess)
+		helpers.StopProcesses(ifritRuntime, routerProcess, cellAProcess, cellBProcess)
 	})
 
 	JustBeforeEach(func() {
+		routerProcess = ginkgomon.Invoke(componentMaker.Router(routerConfigs...))
+
 		repA := componentMaker.RepN(1, func(config *repconfig.RepConfig) {
 			config.CellID = cellAID
 			config.ListenAddr = cellARepAddr
@@ -87,7 +98,9 @@ var _ = Describe("LocalRouteEmitter", func() {
 			config.EvacuationTimeout = durationjson.Duration(30 * time.Second)
 		})
 
-		routeEmitterAConfigs := append(routeEmitterConfigs, func(config *routeemitterconfig.RouteEmitterConfig) {
+		// copy the shared configs, so that appending to them for one cell
+		// does not overwrite the other's
+		routeEmitterAConfigs := append(append([]func(*routeemitterconfig.RouteEmitterConfig){}, routeEmitterConfigs...), func(config *routeemitterconfig.RouteEmitterConfig) {
 			config.SyncInterval = durationjson.Duration(time.Hour)
 			config.CellID = cellAID
 		})
@@ -95,7 +108,7 @@ var _ = Describe("LocalRouteEmitter", func() {
 			{"rep-a", repA},
 			{"route-emitter-a", componentMaker.RouteEmitterN(1, routeEmitterAConfigs...)},
 		}))
-		routeEmitterBConfigs := append(routeEmitterConfigs, func(config *routeemitterconfig.RouteEmitterConfig) {
+		routeEmitterBConfigs := append(append([]func(*routeemitterconfig.RouteEmitterConfig){}, routeEmitterConfigs...), func(config *routeemitterconfig.RouteEmitterConfig) {
 			config.SyncInterval = durationjson.Duration(time.Hour)
 			config.CellID = cellBID
 		})
@@ -136,9 +149,239 @@ var _ = Describe("LocalRouteEmitter", func() {
 			).Should(Equal(http.StatusOK))
 		})
 
+		Context("with three instances", func() {
+			BeforeEach(func() {
+				instances = 3
+			})
+
+			for _, algorithm := range []string{world.RouterRoundRobin, world.RouterLeastConnection} {
+				algorithm := algorithm
+
+				Context("and the router balances with "+algorithm, func() {
+					BeforeEach(func() {
+						routerConfigs = append(routerConfigs, world.RouterBalancingAlgorithm(algorithm))
+					})
+
+					It("spreads the requests roughly evenly across the instances", func() {
+						Eventually(helpers.HelloWorldInstancePoller(componentMaker.Addresses().Router, helpers.DefaultHost)).Should(ConsistOf("0", "1", "2"))
+
+						distribution := routedistribution.Measure(componentMaker.Addresses().Router, helpers.DefaultHost, 300)
+						Expect(distribution.Errors).To(BeEmpty())
+						Expect(distribution.RouterErrors).To(BeZero())
+						Expect(distribution).To(routedistribution.BeRoughlyBalanced(3, 0.5))
+					})
+				})
+			}
+		})
+
+		Context("when recording the NATS messages", func() {
+			var recorder *helpers.NATSRecorder
+
+			BeforeEach(func() {
+				recorder = helpers.NewNATSRecorder(componentMaker.Addresses().NATS)
+			})
+
+			AfterEach(func() {
+				recorder.Close()
+			})
+
+			It("registers the route of the lrp with its instance address", func() {
+				lrps, err := bbsClient.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: processGuid})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lrps).To(HaveLen(1))
+				actualLRP := lrps[0]
+
+				Eventually(recorder).Should(helpers.HaveRegisteredRoute(helpers.RouteSpec{
+					URI:                  helpers.DefaultHost,
+					Host:                 actualLRP.Address,
+					Port:                 actualLRP.Ports[0].HostPort,
+					PrivateInstanceId:    actualLRP.InstanceGuid,
+					PrivateInstanceIndex: "0",
+					Tags:                 map[string]string{"component": "route-emitter"},
+				}))
+			})
+
+			It("unregisters the route when the lrp is removed", func() {
+				err := bbsClient.RemoveDesiredLRP(lgr, processGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(recorder).Should(helpers.HaveUnregisteredRoute(helpers.RouteSpec{URI: helpers.DefaultHost}))
+			})
+		})
+
+		Context("when the router serves TLS", func() {
+			BeforeEach(func() {
+				routerConfigs = append(routerConfigs, componentMaker.RouterTLS(helpers.DefaultHost))
+			})
+
+			It("serves the lrp over HTTPS", func() {
+				request := helpers.RouteRequest{
+					Method: http.MethodPost,
+					Path:   "/echo",
+					Header: http.Header{"X-Original": {"from-client"}},
+					Body:   []byte("hello over tls"),
+				}
+
+				var response *helpers.RouteResponse
+				Eventually(func() (int, error) {
+					var err error
+					response, err = helpers.HTTPSRouteResponse(componentMaker.RouterTLSAddress(), componentMaker.CACert(), helpers.DefaultHost, request)
+					if err != nil {
+						return 0, err
+					}
+					return response.StatusCode, nil
+				}).Should(Equal(http.StatusOK))
+
+				Expect(response.Proto).To(Equal("HTTP/1.1"))
+				Expect(string(response.Body)).To(Equal("hello over tls"))
+				Expect(response.Header.Get("X-Echo-X-Original")).To(Equal("from-client"))
+			})
+
+			Context("and HTTP/2 is enabled", func() {
+				BeforeEach(func() {
+					routerConfigs = append(routerConfigs, func(cfg *world.RouterConfig) {
+						cfg.EnableHTTP2 = true
+					})
+				})
+
+				It("serves the lrp over HTTP/2", func() {
+					Eventually(func() (string, error) {
+						response, err := helpers.HTTP2RouteResponse(componentMaker.RouterTLSAddress(), componentMaker.CACert(), helpers.DefaultHost, helpers.RouteRequest{Path: "/instance"})
+						if err != nil {
+							return "", err
+						}
+						if _, err := helpers.ParseAppInstance(response); err != nil {
+							return "", err
+						}
+						return response.Proto, nil
+					}).Should(Equal("HTTP/2.0"))
+				})
+			})
+		})
+
+		It("passes WebSocket connections through to the lrp", func() {
+			var conn net.Conn
+			Eventually(func() error {
+				var err error
+				conn, err = helpers.WebSocketThroughRouter(componentMaker.Addresses().Router, helpers.DefaultHost, "/websocket")
+				return err
+			}).Should(Succeed())
+			defer conn.Close()
+
+			Expect(conn.SetDeadline(time.Now().Add(10 * time.Second))).To(Succeed())
+
+			message := []byte("\x81\x05hello")
+			_, err := conn.Write(message)
+			Expect(err).NotTo(HaveOccurred())
+
+			echoed := make([]byte, len(message))
+			_, err = io.ReadFull(conn, echoed)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(echoed).To(Equal(message))
+		})
+
+		Context("when the route is bound to a route service", func() {
+			var (
+				routeService        *routeservice.Server
+				routeServiceProcess ifrit.Process
+			)
+
+			BeforeEach(func() {
+				routerConfigs = append(routerConfigs, componentMaker.RouterRouteServices("route-services-secret"))
+				routeService = componentMaker.RouteService()
+				routeServiceProcess = ginkgomon.Invoke(routeService)
+
+				routes := cfroutes.CFRoutes{{
+					Hostnames:       []string{helpers.DefaultHost},
+					Port:            8080,
+					RouteServiceUrl: routeService.URL(),
+				}}.RoutingInfo()
+				lrp.Routes = &routes
+			})
+
+			AfterEach(func() {
+				helpers.StopProcesses(routeServiceProcess)
+			})
+
+			It("sends requests through the route service to the lrp", func() {
+				request := helpers.RouteRequest{
+					Path:   "/echo",
+					Header: http.Header{"X-Original": {"from-client"}},
+				}
+				Eventually(func() (int, error) {
+					response, err := helpers.HTTPRouteResponse(componentMaker.Addresses().Router, helpers.DefaultHost, request)
+					if err != nil {
+						return 0, err
+					}
+					return response.StatusCode, nil
+				}).Should(Equal(http.StatusOK))
+
+				requests := routeService.Requests()
+				Expect(requests).NotTo(BeEmpty())
+				lastRequest := requests[len(requests)-1]
+				Expect(lastRequest.ForwardedURL).To(HaveSuffix(helpers.DefaultHost + "/echo"))
+				Expect(lastRequest.Header.Get("X-Original")).To(Equal("from-client"))
+			})
+
+			It("lets the route service change the requests the lrp receives", func() {
+				routeService.ModifyRequests(func(r *http.Request) {
+					r.Header.Set("X-Route-Service", "was-here")
+				})
+
+				Eventually(func() (string, error) {
+					response, err := helpers.HTTPRouteResponse(componentMaker.Addresses().Router, helpers.DefaultHost, helpers.RouteRequest{Path: "/echo"})
+					if err != nil {
+						return "", err
+					}
+					return response.Header.Get("X-Echo-X-Route-Service"), nil
+				}).Should(Equal("was-here"))
+			})
+
+			It("does not reach the lrp when the route service rejects the requests", func() {
+				routeService.RejectRequests(http.StatusForbidden)
+
+				Eventually(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost)).Should(Equal(http.StatusForbidden))
+			})
+		})
+
+		Context("when internal route emitting is enabled", func() {
+			var (
+				serviceDiscovery        *servicediscovery.Controller
+				serviceDiscoveryProcess ifrit.Process
+			)
+
+			BeforeEach(func() {
+				serviceDiscovery = componentMaker.ServiceDiscovery()
+				serviceDiscoveryProcess = ginkgomon.Invoke(serviceDiscovery)
+				routeEmitterConfigs = append(routeEmitterConfigs, world.RouteEmitterInternalRoutes)
+
+				routes := cfroutes.CFRoutes{{Hostnames: []string{helpers.DefaultHost}, Port: 8080}}.RoutingInfo()
+				for key, value := range (internalroutes.InternalRoutes{{Hostname: "app.apps.internal"}}).RoutingInfo() {
+					routes[key] = value
+				}
+				lrp.Routes = &routes
+			})
+
+			AfterEach(func() {
+				helpers.StopProcesses(serviceDiscoveryProcess)
+			})
+
+			It("resolves the internal route of the lrp to its container address", func() {
+				lrps, err := bbsClient.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: processGuid})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(lrps).To(HaveLen(1))
+				containerAddress := lrps[0].ActualLRPNetInfo.InstanceAddress
+
+				Eventually(func() ([]string, error) {
+					return servicediscovery.Lookup(serviceDiscovery.DNSAddress(), "app.apps.internal")
+				}, 5*time.Second).Should(Equal([]string{containerAddress}))
+				Expect(servicediscovery.Lookup(serviceDiscovery.DNSAddress(), "0.app.apps.internal")).To(Equal([]string{containerAddress}))
+			})
+		})
+
 		Context("when tcp route emitting is enabled", func() {
 			var (
-				routingAPI        *routingapi.RoutingAPIRunner
+				routingAPI        *world.RoutingAPIRunner
 				routingAPIProcess ifrit.Process
 				sqlProcess        ifrit.Process
 			)
@@ -170,7 +413,7 @@ var _ = Describe("LocalRouteEmitter", func() {
 					tcpRoute := tcp_routes.TCPRoutes{
 						tcp_routes.TCPRoute{
 							RouterGroupGuid: routerGroupGUID,
-							ExternalPort:    1234,
+							ExternalPort:    componentMaker.TCPRouterExternalPorts()[0],
 							ContainerPort:   8080,
 						},
 					}
@@ -190,6 +433,22 @@ var _ = Describe("LocalRouteEmitter", func() {
 						return nil
 					}, 2*time.Second).Should(Succeed())
 				})
+
+				It("routes tcp traffic to the lrp through the tcp router", func() {
+					if os.Getenv("SKIP_TCP_ROUTER") == "true" {
+						Skip("SKIP_TCP_ROUTER is set")
+					}
+
+					tcpRouterProcess := ginkgomon.Invoke(componentMaker.TCPRouter(routingAPI.Config.API.ListenPort))
+					defer helpers.StopProcesses(tcpRouterProcess)
+
+					tcpRouteAddr := componentMaker.TCPRouterAddress(componentMaker.TCPRouterExternalPorts()[0])
+					Eventually(func() (helpers.AppInstance, error) {
+						return helpers.TCPRouteInstance(tcpRouteAddr)
+					}).Should(WithTransform(func(instance helpers.AppInstance) int {
+						return instance.Index
+					}, Equal(0)))
+				})
 			})
 		})
 
@@ -373,7 +632,7 @@ func evacuateARep(
 	cellBID, cellBRepAddr string,
 	cellAPort, cellBPort uint16,
 ) {
-	By("finding rep with one instance running")
+	timelineRecorder.By("finding rep with one instance running")
 	lrps, err := bbsClient.ActualLRPs(logger, models.ActualLRPFilter{ProcessGuid: processGuid})
 	Expect(err).NotTo(HaveOccurred())
 	Expect(lrps).To(HaveLen(3))
@@ -418,13 +677,13 @@ func evacuateARep(
 		Fail(fmt.Sprintf("cell id %s doesn't match either cell-a or cell-b", repWithOneInstance))
 	}
 
-	By(fmt.Sprintf("sending evacuate request to %s", repWithOneInstance))
+	timelineRecorder.By(fmt.Sprintf("sending evacuate request to %s", repWithOneInstance))
 	resp, err := httpClient.Post(fmt.Sprintf("https://127.0.0.1:%d/evacuate", evacuatingRepPort), "text/html", nil)
 	Expect(err).NotTo(HaveOccurred())
 	resp.Body.Close()
 	Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
 
-	By("waiting for the lrp to run on the new cell")
+	timelineRecorder.By("waiting for the lrp to run on the new cell")
 	Eventually(func() map[string]int {
 		lrps := helpers.RunningActualLRPs(logger, bbsClient, processGuid)
 		cellIDs := map[string]int{}
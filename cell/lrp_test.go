@@ -15,6 +15,8 @@ import (
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/inigo/fixtures"
 	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/bbsevents"
+	"code.cloudfoundry.org/inigo/helpers/bbsstate"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"code.cloudfoundry.org/routing-info/cfroutes"
@@ -119,6 +121,10 @@ var _ = Describe("LRP", func() {
 		It("eventually runs", func() {
 			Eventually(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)).Should(Equal(models.ActualLRPStateRunning))
 			Eventually(helpers.HelloWorldInstancePoller(componentMaker.Addresses().Router, helpers.DefaultHost)).Should(ConsistOf([]string{"0"}))
+
+			desiredLRP, err := bbsClient.DesiredLRPByProcessGuid(lgr, processGuid)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(desiredLRP).To(bbsstate.HaveRoutes(helpers.DefaultHost))
 		})
 
 		It("should send events as the LRP goes through its lifecycle ", func() {
@@ -128,6 +134,15 @@ var _ = Describe("LRP", func() {
 			Eventually(getEvents).Should(ContainElement(MatchActualLRPChangedEvent(processGuid, 0, models.ActualLRPStateRunning)))
 		})
 
+		It("should send instance events in lifecycle order", func() {
+			Eventually(bbsEvents).Should(bbsevents.ReceiveEventsInOrder(
+				bbsevents.DesiredLRPCreated(processGuid),
+				bbsevents.ActualLRPInstanceCreated(processGuid, 0),
+				bbsevents.ActualLRPInstanceChanged(processGuid, 0, models.ActualLRPStateUnclaimed, models.ActualLRPStateClaimed),
+				bbsevents.ActualLRPInstanceChanged(processGuid, 0, models.ActualLRPStateClaimed, models.ActualLRPStateRunning),
+			))
+		})
+
 		Context("when using a private image", func() {
 			BeforeEach(func() {
 				lrp.RootFs = os.Getenv("INIGO_PRIVATE_DOCKER_IMAGE_URI")
@@ -527,16 +542,16 @@ var _ = Describe("LRP", func() {
 			})
 
 			It("fails and sets a placement error", func() {
-				lrpFunc := func() string {
+				lrpFunc := func() *models.ActualLRP {
 					lrps, err := bbsClient.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: processGuid})
 					Expect(err).NotTo(HaveOccurred())
 					if len(lrps) == 0 {
-						return ""
+						return nil
 					}
-					return lrps[0].PlacementError
+					return lrps[0]
 				}
 
-				Eventually(lrpFunc).Should(ContainSubstring("found no compatible cell"))
+				Eventually(lrpFunc).Should(bbsstate.HavePlacementError("found no compatible cell"))
 			})
 		})
 
@@ -546,16 +561,16 @@ var _ = Describe("LRP", func() {
 			})
 
 			It("fails and sets a placement error", func() {
-				lrpFunc := func() string {
+				lrpFunc := func() *models.ActualLRP {
 					lrps, err := bbsClient.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: processGuid})
 					Expect(err).NotTo(HaveOccurred())
 					if len(lrps) == 0 {
-						return ""
+						return nil
 					}
-					return lrps[0].PlacementError
+					return lrps[0]
 				}
 
-				Eventually(lrpFunc).Should(ContainSubstring("found no compatible cell"))
+				Eventually(lrpFunc).Should(bbsstate.HavePlacementError("found no compatible cell"))
 			})
 		})
 
@@ -582,18 +597,18 @@ var _ = Describe("LRP", func() {
 	})
 
 	Context("Crashing LRPs", func() {
-		crashCount := func(guid string, index int) func() int32 {
-			return func() int32 {
+		actualLRP := func(guid string, index int) func() *models.ActualLRP {
+			return func() *models.ActualLRP {
 				i := int32(index)
 				lrps, err := bbsClient.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: guid, Index: &i})
 				Expect(err).NotTo(HaveOccurred())
 				Expect(len(lrps)).To(Equal(1))
-				return lrps[0].CrashCount
+				return lrps[0]
 			}
 		}
 
 		BeforeEach(func() {
-			By("restarting the bbs with smaller convergeRepeatInterval")
+			timelineRecorder.By("restarting the bbs with smaller convergeRepeatInterval")
 			ginkgomon.Interrupt(bbsProcess)
 			bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 				overrideConvergenceRepeatInterval,
@@ -616,11 +631,11 @@ var _ = Describe("LRP", func() {
 				testAppRecovery := func(index int) {
 					It("imediately restarts the app 3 times", func() {
 						// the bbs immediately starts it 3 times
-						Eventually(crashCount(processGuid, index)).Should(BeEquivalentTo(3))
+						Eventually(actualLRP(processGuid, index)).Should(bbsstate.HaveCrashed(3))
 						// then exponential backoff kicks in
-						Consistently(crashCount(processGuid, index), 15*time.Second).Should(BeEquivalentTo(3))
+						Consistently(actualLRP(processGuid, index), 15*time.Second).Should(bbsstate.HaveCrashed(3))
 						// eventually we cross the first backoff threshold (30 seconds)
-						Eventually(crashCount(processGuid, index), 30*time.Second).Should(BeEquivalentTo(4))
+						Eventually(actualLRP(processGuid, index), 30*time.Second).Should(bbsstate.HaveCrashed(4))
 					})
 				}
 
@@ -663,7 +678,7 @@ var _ = Describe("LRP", func() {
 				})
 
 				It("crashes the instance and restarts it", func() {
-					Eventually(crashCount(processGuid, 0)).Should(BeEquivalentTo(1))
+					Eventually(actualLRP(processGuid, 0)).Should(bbsstate.HaveCrashed(1))
 					Eventually(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)).Should(Equal(models.ActualLRPStateRunning))
 				})
 
@@ -0,0 +1,140 @@
+package cell_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	archive_helper "code.cloudfoundry.org/archiver/extractor/test_helper"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/fixtures"
+	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/world"
+	"github.com/nats-io/nats.go"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+	"github.com/tedsuo/ifrit/grouper"
+)
+
+var _ = Describe("NATS", func() {
+	var (
+		processGuid         string
+		cluster             *world.NATSCluster
+		natsConfigs         []func(*world.NATSConfig)
+		clusterSize         int
+		withRouter          bool
+		ifritRuntime        ifrit.Process
+		fileServer          ifrit.Runner
+		fileServerStaticDir string
+	)
+
+	BeforeEach(func() {
+		if runtime.GOOS == "windows" {
+			Skip(" not yet working on windows")
+		}
+
+		processGuid = helpers.GenerateGuid()
+		natsConfigs = []func(*world.NATSConfig){world.NATSAuth("nats", "nats")}
+		clusterSize = 2
+		withRouter = true
+		fileServer, fileServerStaticDir = componentMaker.FileServer()
+	})
+
+	JustBeforeEach(func() {
+		cluster = componentMaker.NATSCluster(clusterSize, natsConfigs...)
+		cluster.Start()
+
+		members := grouper.Members{
+			{"rep", componentMaker.Rep()},
+			{"auctioneer", componentMaker.Auctioneer()},
+			{"route-emitter", componentMaker.RouteEmitter(cluster.RouteEmitterConfig)},
+			{"file-server", fileServer},
+		}
+		if withRouter {
+			members = append(members, grouper.Member{Name: "router", Runner: componentMaker.Router(cluster.RouterConfig)})
+		}
+		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Kill, members))
+
+		archive_helper.CreateZipArchive(
+			filepath.Join(fileServerStaticDir, "lrp.zip"),
+			fixtures.GoServerApp(),
+		)
+	})
+
+	AfterEach(func() {
+		helpers.StopProcesses(ifritRuntime)
+		if cluster != nil {
+			cluster.Stop()
+		}
+	})
+
+	desireLRP := func() {
+		err := bbsClient.DesireLRP(lgr, createDesiredLRP(processGuid))
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(helpers.LRPStatePoller(lgr, bbsClient, processGuid, nil)).Should(Equal(models.ActualLRPStateRunning))
+	}
+
+	It("rejects clients without the credentials", func() {
+		_, err := nats.Connect("nats://" + cluster.NodeAddress(0))
+		Expect(err).To(HaveOccurred())
+
+		conn, err := nats.Connect("nats://"+cluster.NodeAddress(0), cluster.ClientOptions()...)
+		Expect(err).NotTo(HaveOccurred())
+		conn.Close()
+	})
+
+	Context("when a node of the cluster is killed", func() {
+		JustBeforeEach(func() {
+			desireLRP()
+			Eventually(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost)).Should(Equal(http.StatusOK))
+
+			cluster.KillNode(0)
+		})
+
+		It("keeps the route registered through the other node", func() {
+			// longer than the router's droplet stale threshold, so the route
+			// would be pruned if the route emitter stopped registering it
+			Consistently(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost), 15*time.Second, time.Second).Should(Equal(http.StatusOK))
+		})
+
+		It("keeps the route registered once the node is back and the other one is killed", func() {
+			cluster.StartNode(0)
+			cluster.KillNode(1)
+
+			Consistently(helpers.ResponseCodeFromHostPoller(componentMaker.Addresses().Router, helpers.DefaultHost), 15*time.Second, time.Second).Should(Equal(http.StatusOK))
+		})
+	})
+
+	Context("when the cluster requires TLS", func() {
+		var recorder *helpers.NATSRecorder
+
+		BeforeEach(func() {
+			natsConfigs = append(natsConfigs, componentMaker.NATSTLS())
+			clusterSize = 1
+			withRouter = false
+		})
+
+		JustBeforeEach(func() {
+			recorder = helpers.NewNATSRecorder(cluster.Address(), cluster.ClientOptions()...)
+		})
+
+		AfterEach(func() {
+			recorder.Close()
+		})
+
+		It("rejects clients without a certificate", func() {
+			_, err := nats.Connect("nats://"+cluster.NodeAddress(0), nats.UserInfo("nats", "nats"))
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("has the route emitter register routes over TLS", func() {
+			desireLRP()
+
+			Eventually(recorder).Should(helpers.HaveRegisteredRoute(helpers.RouteSpec{URI: helpers.DefaultHost}))
+		})
+	})
+})
@@ -9,6 +9,7 @@ import (
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/inigo/fixtures"
 	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/bbsstate"
 	"code.cloudfoundry.org/lager"
 
 	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
@@ -121,18 +122,18 @@ var _ = Describe("Placement Tags", func() {
 			})
 
 			It("fails and sets a placement error", func() {
-				lrpFunc := func() string {
+				lrpFunc := func() *models.ActualLRP {
 					lrps, err := bbsClient.ActualLRPs(lgr, models.ActualLRPFilter{ProcessGuid: guid})
 					Expect(err).NotTo(HaveOccurred())
 					if len(lrps) == 0 {
-						return ""
+						return nil
 					}
 					lgr.Info("lrp-cell-id", lager.Data{"cell-id": lrps[0].CellId})
 
-					return lrps[0].PlacementError
+					return lrps[0]
 				}
 
-				Eventually(lrpFunc).Should(ContainSubstring("found no compatible cell with placement tag"))
+				Eventually(lrpFunc).Should(bbsstate.HavePlacementError("found no compatible cell with placement tag"))
 			})
 		})
 	})
@@ -8,7 +8,6 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"time"
 
 	"code.cloudfoundry.org/archiver/compressor"
 	archive_helper "code.cloudfoundry.org/archiver/extractor/test_helper"
@@ -17,6 +16,8 @@ import (
 	"code.cloudfoundry.org/diego-ssh/routes"
 	"code.cloudfoundry.org/inigo/fixtures"
 	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/fakecf"
+	"code.cloudfoundry.org/inigo/helpers/sshhelpers"
 	"code.cloudfoundry.org/inigo/world"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
@@ -27,39 +28,55 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 )
 
 var _ = Describe("SSH", func() {
-	verifySSH := func(address, processGuid string, index int) {
-		clientConfig := &ssh.ClientConfig{
-			User:            fmt.Sprintf("diego:%s/%d", processGuid, index),
-			Auth:            []ssh.AuthMethod{ssh.Password("")},
-			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		}
+	var (
+		processGuid         string
+		fileServerStaticDir string
 
-		client, err := ssh.Dial("tcp", address, clientConfig)
-		Expect(err).NotTo(HaveOccurred())
+		ifritRuntime ifrit.Process
+		address      string
+
+		uaa *fakecf.UAA
+		cc  *fakecf.CloudController
+
+		lrp models.DesiredLRP
+	)
 
-		session, err := client.NewSession()
+	dial := func(user, password string) (*sshhelpers.Client, error) {
+		return sshhelpers.Dial(address, user, password, componentMaker.SSHConfig().HostKey.PublicKey())
+	}
+
+	verifySSH := func(processGuid string, index int) {
+		client, err := dial(sshhelpers.DiegoUser(processGuid, index), "")
 		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
 
-		output, err := session.Output("env")
+		result, err := client.Run("env")
 		Expect(err).NotTo(HaveOccurred())
+		Expect(result.ExitStatus).To(Equal(0))
 
-		Expect(string(output)).To(ContainSubstring("USER=root"))
-		Expect(string(output)).To(ContainSubstring("TEST=foobar"))
-		Expect(string(output)).To(ContainSubstring(fmt.Sprintf("INSTANCE_INDEX=%d", index)))
+		Expect(string(result.Stdout)).To(ContainSubstring("USER=root"))
+		Expect(string(result.Stdout)).To(ContainSubstring("TEST=foobar"))
+		Expect(string(result.Stdout)).To(ContainSubstring(fmt.Sprintf("INSTANCE_INDEX=%d", index)))
 	}
 
-	var (
-		processGuid         string
-		fileServerStaticDir string
+	verifyPortForwarding := func(processGuid string) {
+		client, err := dial(sshhelpers.DiegoUser(processGuid, 0), "")
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close()
 
-		ifritRuntime ifrit.Process
-		address      string
+		resp, err := client.HTTPClient().Get("http://127.0.0.1:9999/yo")
+		Expect(err).NotTo(HaveOccurred())
+		defer resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
 
-		lrp models.DesiredLRP
-	)
+		contents, err := ioutil.ReadAll(resp.Body)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(contents).To(ContainSubstring("sup dawg"))
+	}
 
 	BeforeEach(func() {
 		if runtime.GOOS == "windows" {
@@ -68,6 +85,9 @@ var _ = Describe("SSH", func() {
 		processGuid = helpers.GenerateGuid()
 		address = componentMaker.Addresses().SSHProxy
 
+		uaa = componentMaker.FakeUAA()
+		cc = componentMaker.FakeCC(uaa)
+
 		var fileServer ifrit.Runner
 		fileServer, fileServerStaticDir = componentMaker.FileServer()
 		ifritRuntime = ginkgomon.Invoke(grouper.NewParallel(os.Kill, grouper.Members{
@@ -76,7 +96,9 @@ var _ = Describe("SSH", func() {
 			{"rep", componentMaker.Rep()},
 			{"auctioneer", componentMaker.Auctioneer()},
 			{"route-emitter", componentMaker.RouteEmitter()},
-			{"ssh-proxy", componentMaker.SSHProxy()},
+			{"uaa", uaa},
+			{"cc", cc},
+			{"ssh-proxy", componentMaker.SSHProxy(componentMaker.SSHProxyCFAuth(cc, uaa))},
 		}))
 
 		tgCompressor := compressor.NewTgz()
@@ -188,53 +210,58 @@ var _ = Describe("SSH", func() {
 
 	Context("when valid process guid and index are used in the username", func() {
 		It("can ssh to appropriate app instance container", func() {
-			verifySSH(address, processGuid, 0)
-			verifySSH(address, processGuid, 1)
+			verifySSH(processGuid, 0)
+			verifySSH(processGuid, 1)
 		})
 
 		It("supports local port fowarding", func() {
-			clientConfig := &ssh.ClientConfig{
-				User:            fmt.Sprintf("diego:%s/%d", processGuid, 0),
-				Auth:            []ssh.AuthMethod{ssh.Password("")},
-				HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-			}
+			verifyPortForwarding(processGuid)
+		})
 
-			client, err := ssh.Dial("tcp", address, clientConfig)
+		It("runs interactive sessions with a pty", func() {
+			client, err := dial(sshhelpers.DiegoUser(processGuid, 0), "")
 			Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
 
-			httpClient := &http.Client{
-				Transport: &http.Transport{
-					Dial: client.Dial,
-				},
-				Timeout: 5 * time.Second,
-			}
-
-			resp, err := httpClient.Get("http://127.0.0.1:9999/yo")
+			shell, err := client.Shell("")
 			Expect(err).NotTo(HaveOccurred())
-			defer resp.Body.Close()
-			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			defer shell.Close()
+
+			Expect(shell.Send("tty && echo $TEST\n")).To(Succeed())
+			Eventually(shell.Output).Should(gbytes.Say("/dev/pts/"))
+			Eventually(shell.Output).Should(gbytes.Say("foobar"))
 
-			contents, err := ioutil.ReadAll(resp.Body)
+			Expect(shell.Send("exit 3\n")).To(Succeed())
+			Expect(shell.Wait()).To(Equal(3))
+		})
+
+		It("transfers files over sftp and scp", func() {
+			client, err := dial(sshhelpers.DiegoUser(processGuid, 0), "")
 			Expect(err).NotTo(HaveOccurred())
-			Expect(contents).To(ContainSubstring("sup dawg"))
+			defer client.Close()
+
+			Expect(client.SFTPUpload("/tmp/sftp-file", []byte("over sftp"))).To(Succeed())
+			Expect(client.SCPDownload("/tmp/sftp-file")).To(Equal([]byte("over sftp")))
+
+			Expect(client.SCPUpload("/tmp/scp-file", []byte("over scp"), 0600)).To(Succeed())
+			Expect(client.SFTPDownload("/tmp/scp-file")).To(Equal([]byte("over scp")))
 		})
 
-		Context("when invalid password is used", func() {
-			var clientConfig *ssh.ClientConfig
+		It("fails when the ssh proxy presents another host key", func() {
+			otherKey, err := ssh.ParsePrivateKey([]byte(componentMaker.SSHConfig().PrivateKeyPem))
+			Expect(err).NotTo(HaveOccurred())
 
-			BeforeEach(func() {
-				clientConfig = &ssh.ClientConfig{
-					User: "diego:" + processGuid + "/0",
-					Auth: []ssh.AuthMethod{ssh.Password("invalid:password")},
-				}
-			})
+			_, err = sshhelpers.Dial(address, sshhelpers.DiegoUser(processGuid, 0), "", otherKey.PublicKey())
+			Expect(err).To(MatchError(ContainSubstring("host key")))
+		})
 
+		Context("when invalid password is used", func() {
 			It("returns an error", func() {
 				Eventually(
 					helpers.LRPInstanceStatePoller(lgr, bbsClient, processGuid, 0, nil),
 				).Should(Equal(models.ActualLRPStateRunning))
 
-				_, err := ssh.Dial("tcp", address, clientConfig)
+				_, err := dial(sshhelpers.DiegoUser(processGuid, 0), "invalid:password")
 				Expect(err).To(HaveOccurred())
 			})
 		})
@@ -256,83 +283,100 @@ var _ = Describe("SSH", func() {
 			})
 
 			It("can ssh to appropriate app instance container", func() {
-				verifySSH(address, processGuid, 0)
-				verifySSH(address, processGuid, 1)
+				verifySSH(processGuid, 0)
+				verifySSH(processGuid, 1)
 			})
 
 			It("supports local port fowarding", func() {
-				clientConfig := &ssh.ClientConfig{
-					User:            fmt.Sprintf("diego:%s/%d", processGuid, 0),
-					Auth:            []ssh.AuthMethod{ssh.Password("")},
-					HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-				}
-
-				client, err := ssh.Dial("tcp", address, clientConfig)
-				Expect(err).NotTo(HaveOccurred())
-
-				httpClient := &http.Client{
-					Transport: &http.Transport{
-						Dial: client.Dial,
-					},
-					Timeout: 5 * time.Second,
-				}
-
-				resp, err := httpClient.Get("http://127.0.0.1:9999/yo")
-				Expect(err).NotTo(HaveOccurred())
-				defer resp.Body.Close()
-				Expect(resp.StatusCode).To(Equal(http.StatusOK))
-
-				contents, err := ioutil.ReadAll(resp.Body)
-				Expect(err).NotTo(HaveOccurred())
-				Expect(contents).To(ContainSubstring("sup dawg"))
+				verifyPortForwarding(processGuid)
 			})
 		})
 	})
 
-	Context("when non-existent index is used as part of username", func() {
-		var clientConfig *ssh.ClientConfig
+	Context("when a CF user logs in with a one-time code", func() {
+		var appGuid string
 
 		BeforeEach(func() {
-			clientConfig = &ssh.ClientConfig{
-				User: "diego:" + processGuid + "/3",
-				Auth: []ssh.AuthMethod{ssh.Password("")},
-			}
+			appGuid = helpers.GenerateGuid()
+			cc.SetApp(appGuid, fakecf.App{
+				ProcessGuid: processGuid,
+				SSHEnabled:  true,
+				Developers:  []string{"developer"},
+			})
 		})
 
-		It("returns an error", func() {
-			_, err := ssh.Dial("tcp", address, clientConfig)
-			Expect(err).To(HaveOccurred())
+		It("can ssh to appropriate app instance container", func() {
+			client, err := dial(sshhelpers.CFUser(appGuid, 1), uaa.NewCode("developer"))
+			Expect(err).NotTo(HaveOccurred())
+			defer client.Close()
+
+			result, err := client.Run("env")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.ExitStatus).To(Equal(0))
+			Expect(string(result.Stdout)).To(ContainSubstring("INSTANCE_INDEX=1"))
 		})
-	})
 
-	Context("when non-existent process guid is used as part of username", func() {
-		var clientConfig *ssh.ClientConfig
+		It("does not accept a code twice", func() {
+			code := uaa.NewCode("developer")
 
-		BeforeEach(func() {
-			clientConfig = &ssh.ClientConfig{
-				User: "diego:not-existing-process-guid/0",
-				Auth: []ssh.AuthMethod{ssh.Password("")},
-			}
+			client, err := dial(sshhelpers.CFUser(appGuid, 0), code)
+			Expect(err).NotTo(HaveOccurred())
+			client.Close()
+
+			_, err = dial(sshhelpers.CFUser(appGuid, 0), code)
+			Expect(err).To(MatchError(ContainSubstring("unable to authenticate")))
+		})
+
+		It("returns an error when the user is not a developer of the app", func() {
+			_, err := dial(sshhelpers.CFUser(appGuid, 0), uaa.NewCode("auditor"))
+			Expect(err).To(MatchError(ContainSubstring("unable to authenticate")))
+		})
+
+		It("returns an error when the app does not exist", func() {
+			_, err := dial(sshhelpers.CFUser(helpers.GenerateGuid(), 0), uaa.NewCode("developer"))
+			Expect(err).To(MatchError(ContainSubstring("unable to authenticate")))
+		})
+
+		Context("when ssh is disabled for the app", func() {
+			BeforeEach(func() {
+				cc.SetSSHEnabled(appGuid, false)
+			})
+
+			It("returns an error", func() {
+				_, err := dial(sshhelpers.CFUser(appGuid, 0), uaa.NewCode("developer"))
+				Expect(err).To(MatchError(ContainSubstring("unable to authenticate")))
+			})
 		})
 
+		Context("when the access token has expired", func() {
+			BeforeEach(func() {
+				uaa.SetTokenTTL(0)
+			})
+
+			It("returns an error", func() {
+				_, err := dial(sshhelpers.CFUser(appGuid, 0), uaa.NewCode("developer"))
+				Expect(err).To(MatchError(ContainSubstring("unable to authenticate")))
+			})
+		})
+	})
+
+	Context("when non-existent index is used as part of username", func() {
 		It("returns an error", func() {
-			_, err := ssh.Dial("tcp", address, clientConfig)
+			_, err := dial(sshhelpers.DiegoUser(processGuid, 3), "")
 			Expect(err).To(HaveOccurred())
 		})
 	})
 
-	Context("when invalid username format is used", func() {
-		var clientConfig *ssh.ClientConfig
-
-		BeforeEach(func() {
-			clientConfig = &ssh.ClientConfig{
-				User: "root",
-				Auth: []ssh.AuthMethod{ssh.Password("some-password")},
-			}
+	Context("when non-existent process guid is used as part of username", func() {
+		It("returns an error", func() {
+			_, err := dial(sshhelpers.DiegoUser("not-existing-process-guid", 0), "")
+			Expect(err).To(HaveOccurred())
 		})
+	})
 
+	Context("when invalid username format is used", func() {
 		It("returns an error", func() {
-			_, err := ssh.Dial("tcp", address, clientConfig)
+			_, err := dial("root", "some-password")
 			Expect(err).To(HaveOccurred())
 		})
 	})
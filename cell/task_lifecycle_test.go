@@ -190,7 +190,7 @@ var _ = Describe("Task Lifecycle", func() {
 
 			Context("when a converger is running", func() {
 				BeforeEach(func() {
-					By("restarting the bbs with smaller convergeRepeatInterval")
+					timelineRecorder.By("restarting the bbs with smaller convergeRepeatInterval")
 					ginkgomon.Interrupt(bbsProcess)
 					bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 						overrideConvergenceRepeatInterval,
@@ -293,7 +293,7 @@ exit 0
 
 	Context("when an auctioneer is not running", func() {
 		BeforeEach(func() {
-			By("restarting the bbs with smaller convergeRepeatInterval")
+			timelineRecorder.By("restarting the bbs with smaller convergeRepeatInterval")
 			ginkgomon.Interrupt(bbsProcess)
 			bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 				overrideConvergenceRepeatInterval,
@@ -341,7 +341,7 @@ exit 0
 
 	Context("when a very impatient converger is running", func() {
 		BeforeEach(func() {
-			By("restarting the bbs with smaller convergeRepeatInterval")
+			timelineRecorder.By("restarting the bbs with smaller convergeRepeatInterval")
 			ginkgomon.Interrupt(bbsProcess)
 			bbsProcess = ginkgomon.Invoke(componentMaker.BBS(
 				overrideConvergenceRepeatInterval,
@@ -18,6 +18,7 @@ import (
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/inigo/helpers"
+	"code.cloudfoundry.org/inigo/helpers/bbsstate"
 	"code.cloudfoundry.org/inigo/inigo_announcement_server"
 	repconfig "code.cloudfoundry.org/rep/cmd/rep/config"
 	. "github.com/onsi/ginkgo"
@@ -264,8 +265,7 @@ var _ = Describe("Tasks", func() {
 					return task.State
 				}).Should(Equal(models.Task_Completed))
 
-				Expect(task.Failed).To(BeTrue())
-				Expect(task.FailureReason).To(ContainSubstring("out of memory"))
+				Expect(task).To(bbsstate.BeCompletedTask(true, "out of memory"))
 
 				Expect(inigo_announcement_server.Announcements()).NotTo(ContainElement("after-memory-overdose"))
 			})
@@ -320,10 +320,8 @@ echo should have died by now
 					return task.State
 				}).Should(Equal(models.Task_Completed))
 
-				Expect(task.Failed).To(BeTrue())
-
 				// when sh can't open another file the exec exits 2
-				Expect(task.FailureReason).To(ContainSubstring("status 2"))
+				Expect(task).To(bbsstate.BeCompletedTask(true, "status 2"))
 			})
 		})
 
@@ -372,8 +370,7 @@ echo should have died by now
 					return task.State
 				}).Should(Equal(models.Task_Completed))
 
-				Expect(task.Failed).To(BeTrue())
-				Expect(task.FailureReason).To(ContainSubstring("exceeded 500ms timeout"))
+				Expect(task).To(bbsstate.BeCompletedTask(true, "exceeded 500ms timeout"))
 			})
 		})
 
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -26,6 +31,9 @@ func main() {
 	http.HandleFunc("/cf-instance-cert", cfInstanceCert)
 	http.HandleFunc("/cf-instance-key", cfInstanceKey)
 	http.HandleFunc("/cat", catFile)
+	http.HandleFunc("/instance", instance)
+	http.HandleFunc("/echo", echo)
+	http.HandleFunc("/websocket", websocket)
 
 	if memoryAllocated != nil {
 		someGarbage = make([]uint8, *memoryAllocated*1024*1024)
@@ -171,3 +179,54 @@ func catFile(res http.ResponseWriter, req *http.Request) {
 
 	res.Write(data)
 }
+
+func instance(res http.ResponseWriter, req *http.Request) {
+	index, _ := strconv.Atoi(os.Getenv("INSTANCE_INDEX"))
+
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(map[string]interface{}{
+		"index": index,
+		"guid":  os.Getenv("INSTANCE_GUID"),
+	})
+}
+
+// echo responds with the request body, and the request headers prefixed with
+// X-Echo-.
+func echo(res http.ResponseWriter, req *http.Request) {
+	for name, values := range req.Header {
+		for _, value := range values {
+			res.Header().Add("X-Echo-"+name, value)
+		}
+	}
+
+	io.Copy(res, req.Body)
+}
+
+// websocket accepts a WebSocket upgrade and then echoes the raw bytes of the
+// connection, frames and all.
+func websocket(res http.ResponseWriter, req *http.Request) {
+	if !strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		res.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := res.(http.Hijacker)
+	if !ok {
+		res.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	conn, buffer, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	accept := sha1.Sum([]byte(req.Header.Get("Sec-WebSocket-Key") + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	fmt.Fprintf(buffer, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(accept[:]))
+	if buffer.Flush() != nil {
+		return
+	}
+
+	io.Copy(conn, buffer)
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"code.cloudfoundry.org/inigo/helpers/fakeloggregator"
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+)
+
+// AppLogs returns a poller for the log lines that have reached the
+// loggregator stand-in with the given log guid. The ingress is passed in
+// because each spec gets its own from ComponentMaker.Loggregator.
+func AppLogs(ingress *fakeloggregator.Ingress, logGuid string) func() []fakeloggregator.LogLine {
+	return func() []fakeloggregator.LogLine {
+		return ingress.LogsFor(logGuid)
+	}
+}
+
+// LogRateLimitExceededPattern matches the line the executor logs for an app
+// instance that exceeds the rep's MaxLogLinesPerSecond.
+func LogRateLimitExceededPattern(maxLogLinesPerSecond int) string {
+	return regexp.QuoteMeta(fmt.Sprintf("app instance exceeded log rate limit (%d log-lines/sec) set by platform operator", maxLogLinesPerSecond))
+}
+
+func ContainLogLine(sourceType, pattern string) gomega.OmegaMatcher {
+	return &LogLineMatcher{
+		SourceType: sourceType,
+		Pattern:    pattern,
+		Index:      -1,
+	}
+}
+
+func ContainLogLineFromInstance(sourceType string, index int, pattern string) gomega.OmegaMatcher {
+	return &LogLineMatcher{
+		SourceType: sourceType,
+		Pattern:    pattern,
+		Index:      index,
+	}
+}
+
+// LogLineMatcher succeeds if any of the log lines has the source type and a
+// message matching the pattern. A negative Index matches any instance.
+type LogLineMatcher struct {
+	SourceType string
+	Pattern    string
+	Index      int
+}
+
+func (matcher *LogLineMatcher) Match(actual interface{}) (success bool, err error) {
+	lines, ok := actual.([]fakeloggregator.LogLine)
+	if !ok {
+		return false, fmt.Errorf("LogLineMatcher expects a []fakeloggregator.LogLine, got %s", format.Object(actual, 1))
+	}
+
+	re, err := regexp.Compile(matcher.Pattern)
+	if err != nil {
+		return false, err
+	}
+
+	for _, line := range lines {
+		if line.SourceType != matcher.SourceType {
+			continue
+		}
+		if matcher.Index >= 0 && line.InstanceId != strconv.Itoa(matcher.Index) {
+			continue
+		}
+		if re.MatchString(line.Message) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (matcher *LogLineMatcher) FailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected\n%s\nto contain a log line with\n  SourceType=%s\n  Index=%d\n  Pattern=%s", formatLogLines(actual), matcher.SourceType, matcher.Index, matcher.Pattern)
+}
+
+func (matcher *LogLineMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected\n%s\nnot to contain a log line with\n  SourceType=%s\n  Index=%d\n  Pattern=%s", formatLogLines(actual), matcher.SourceType, matcher.Index, matcher.Pattern)
+}
+
+func formatLogLines(actual interface{}) string {
+	lines, ok := actual.([]fakeloggregator.LogLine)
+	if !ok {
+		return format.Object(actual, 1)
+	}
+
+	formatted := ""
+	for _, line := range lines {
+		formatted += fmt.Sprintf("    [%s/%s] %s\n", line.SourceType, line.InstanceId, line.Message)
+	}
+	return formatted
+}
@@ -0,0 +1,109 @@
+package helpers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"code.cloudfoundry.org/inigo/helpers/componentlogs"
+	"code.cloudfoundry.org/inigo/helpers/componentstats"
+	"code.cloudfoundry.org/inigo/helpers/timeline"
+	"github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var (
+	unsafeArtifactChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+	defaultArtifactsDir     string
+	defaultArtifactsDirOnce sync.Once
+)
+
+// ArtifactsDir returns the directory debugging artifacts for the current spec
+// are written to. It lives under $ARTIFACTS_DIR, or under a temporary
+// directory when that is not set, and is named after the spec and the
+// parallel node running it.
+func ArtifactsDir() string {
+	base := os.Getenv("ARTIFACTS_DIR")
+	if base == "" {
+		defaultArtifactsDirOnce.Do(func() {
+			var err error
+			defaultArtifactsDir, err = ioutil.TempDir("", "inigo-artifacts")
+			Expect(err).NotTo(HaveOccurred())
+		})
+		base = defaultArtifactsDir
+	}
+
+	spec := unsafeArtifactChars.ReplaceAllString(ginkgo.CurrentGinkgoTestDescription().FullTestText, "_")
+	if len(spec) > 200 {
+		spec = spec[:200]
+	}
+
+	dir := filepath.Join(base, fmt.Sprintf("%s-node-%d", spec, ginkgo.GinkgoParallelNode()))
+	err := os.MkdirAll(dir, 0755)
+	Expect(err).NotTo(HaveOccurred())
+	return dir
+}
+
+// ExportComponentLogsOnFailure writes the captured component logs to the
+// artifacts directory if the current spec failed.
+func ExportComponentLogsOnFailure(store *componentlogs.Store) {
+	if !ginkgo.CurrentGinkgoTestDescription().Failed {
+		return
+	}
+
+	dir := filepath.Join(ArtifactsDir(), "component-logs")
+	err := store.Export(dir)
+	if err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "failed to export component logs: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(ginkgo.GinkgoWriter, "component logs exported to %s\n", dir)
+}
+
+// ExportTimelineOnFailure renders the timeline of the current spec into the
+// artifacts directory if the spec failed.
+func ExportTimelineOnFailure(recorder *timeline.Recorder) {
+	if !ginkgo.CurrentGinkgoTestDescription().Failed {
+		return
+	}
+
+	dir := ArtifactsDir()
+	err := recorder.Export(dir, ginkgo.CurrentGinkgoTestDescription().FullTestText)
+	if err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "failed to export timeline: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(ginkgo.GinkgoWriter, "timeline exported to %s\n", filepath.Join(dir, "timeline.html"))
+}
+
+// ReportComponentStats writes a summary of the resource usage of the
+// components during the current spec to GinkgoWriter. When
+// $COMPONENT_STATS_CSV is set every sample is also written to
+// component-stats.csv in the artifacts directory.
+func ReportComponentStats(sampler *componentstats.Sampler) {
+	fmt.Fprintf(ginkgo.GinkgoWriter, "\ncomponent resource usage:\n")
+	sampler.WriteSummary(ginkgo.GinkgoWriter)
+
+	if os.Getenv("COMPONENT_STATS_CSV") == "" {
+		return
+	}
+
+	path := filepath.Join(ArtifactsDir(), "component-stats.csv")
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "failed to export component stats: %s\n", err)
+		return
+	}
+	defer f.Close()
+
+	err = sampler.WriteCSV(f)
+	if err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "failed to export component stats: %s\n", err)
+	}
+}
@@ -0,0 +1,391 @@
+package bbsdb
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/encryption"
+	"code.cloudfoundry.org/bbs/format"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager"
+)
+
+// DB reads BBS and locket state straight out of the SQL database, bypassing
+// the BBS API. Encrypted columns are decrypted with the encryption keys the
+// BBS was configured with.
+type DB struct {
+	db         *sql.DB
+	driverName string
+	serializer format.Serializer
+	logger     lager.Logger
+}
+
+type Domain struct {
+	Domain     string
+	ExpireTime int64
+}
+
+type DesiredLRP struct {
+	ProcessGuid     string
+	Domain          string
+	LogGuid         string
+	Instances       int32
+	MemoryMb        int32
+	DiskMb          int32
+	RootFs          string
+	Routes          models.Routes
+	ModificationTag models.ModificationTag
+	RunInfo         models.DesiredLRPRunInfo
+}
+
+type ActualLRP struct {
+	ProcessGuid     string
+	Index           int32
+	Presence        models.ActualLRP_Presence
+	Domain          string
+	InstanceGuid    string
+	CellId          string
+	State           string
+	PlacementError  string
+	Since           int64
+	CrashCount      int32
+	CrashReason     string
+	ModificationTag models.ModificationTag
+	NetInfo         models.ActualLRPNetInfo
+}
+
+type Task struct {
+	TaskGuid         string
+	Domain           string
+	State            models.Task_State
+	CellId           string
+	Result           string
+	Failed           bool
+	FailureReason    string
+	CreatedAt        int64
+	UpdatedAt        int64
+	FirstCompletedAt int64
+	RejectionCount   int32
+	RejectionReason  string
+	TaskDefinition   models.TaskDefinition
+}
+
+type Lock struct {
+	Path          string
+	Owner         string
+	Value         string
+	Type          string
+	ModifiedIndex int64
+	ModifiedId    string
+	TTL           int64
+}
+
+func Open(logger lager.Logger, driverName, connectionString string, encryptionConfig encryption.EncryptionConfig) (*DB, error) {
+	key, keys, err := encryptionConfig.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	keyManager, err := encryption.NewKeyManager(key, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(driverName, connectionString)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &DB{
+		db:         db,
+		driverName: driverName,
+		serializer: newSerializer(keyManager),
+		logger:     logger.Session("bbsdb"),
+	}, nil
+}
+
+// newSerializer encodes and decodes models the way BBS stores them: as
+// protobuf, encrypted with the active key.
+func newSerializer(keyManager encryption.KeyManager) format.Serializer {
+	return format.NewSerializer(encryption.NewCryptor(keyManager, rand.Reader))
+}
+
+// scanner is a row of a query result.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+func (d *DB) Domains() ([]Domain, error) {
+	rows, err := d.query("SELECT domain, expire_time FROM domains")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []Domain{}
+	for rows.Next() {
+		var domain Domain
+		err := rows.Scan(&domain.Domain, &domain.ExpireTime)
+		if err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, rows.Err()
+}
+
+// DesiredLRPs returns the desired LRPs with the given process guids, or all
+// of them when no process guid is given.
+func (d *DB) DesiredLRPs(processGuids ...string) ([]DesiredLRP, error) {
+	query, args := withProcessGuids(`
+		SELECT process_guid, domain, log_guid, instances, memory_mb, disk_mb, rootfs, routes,
+			modification_tag_epoch, modification_tag_index, run_info
+		FROM desired_lrps`, processGuids)
+
+	rows, err := d.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lrps := []DesiredLRP{}
+	for rows.Next() {
+		lrp, err := d.scanDesiredLRP(rows)
+		if err != nil {
+			return nil, err
+		}
+		lrps = append(lrps, lrp)
+	}
+
+	return lrps, rows.Err()
+}
+
+func (d *DB) scanDesiredLRP(row scanner) (DesiredLRP, error) {
+	var lrp DesiredLRP
+	var routes, runInfo []byte
+	err := row.Scan(
+		&lrp.ProcessGuid, &lrp.Domain, &lrp.LogGuid, &lrp.Instances, &lrp.MemoryMb, &lrp.DiskMb, &lrp.RootFs, &routes,
+		&lrp.ModificationTag.Epoch, &lrp.ModificationTag.Index, &runInfo,
+	)
+	if err != nil {
+		return DesiredLRP{}, err
+	}
+
+	if len(routes) > 0 {
+		err = json.Unmarshal(routes, &lrp.Routes)
+		if err != nil {
+			return DesiredLRP{}, fmt.Errorf("decoding routes for %s: %s", lrp.ProcessGuid, err)
+		}
+	}
+
+	err = d.serializer.Unmarshal(d.logger, runInfo, &lrp.RunInfo)
+	if err != nil {
+		return DesiredLRP{}, fmt.Errorf("decoding run info for %s: %s", lrp.ProcessGuid, err)
+	}
+
+	return lrp, nil
+}
+
+// ActualLRPs returns the actual LRPs with the given process guids, or all of
+// them when no process guid is given.
+func (d *DB) ActualLRPs(processGuids ...string) ([]ActualLRP, error) {
+	query, args := withProcessGuids(`
+		SELECT process_guid, instance_index, presence, domain, instance_guid, cell_id, state,
+			placement_error, since, crash_count, crash_reason,
+			modification_tag_epoch, modification_tag_index, net_info
+		FROM actual_lrps`, processGuids)
+
+	rows, err := d.query(query+" ORDER BY process_guid, instance_index, presence", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lrps := []ActualLRP{}
+	for rows.Next() {
+		lrp, err := d.scanActualLRP(rows)
+		if err != nil {
+			return nil, err
+		}
+		lrps = append(lrps, lrp)
+	}
+
+	return lrps, rows.Err()
+}
+
+func (d *DB) scanActualLRP(row scanner) (ActualLRP, error) {
+	var lrp ActualLRP
+	var netInfo []byte
+	err := row.Scan(
+		&lrp.ProcessGuid, &lrp.Index, &lrp.Presence, &lrp.Domain, &lrp.InstanceGuid, &lrp.CellId, &lrp.State,
+		&lrp.PlacementError, &lrp.Since, &lrp.CrashCount, &lrp.CrashReason,
+		&lrp.ModificationTag.Epoch, &lrp.ModificationTag.Index, &netInfo,
+	)
+	if err != nil {
+		return ActualLRP{}, err
+	}
+
+	if len(netInfo) > 0 {
+		err = d.serializer.Unmarshal(d.logger, netInfo, &lrp.NetInfo)
+		if err != nil {
+			return ActualLRP{}, fmt.Errorf("decoding net info for %s/%d: %s", lrp.ProcessGuid, lrp.Index, err)
+		}
+	}
+
+	return lrp, nil
+}
+
+// Tasks returns the tasks with the given guids, or all of them when no guid
+// is given.
+func (d *DB) Tasks(taskGuids ...string) ([]Task, error) {
+	query := `
+		SELECT guid, domain, state, cell_id, result, failed, failure_reason,
+			created_at, updated_at, first_completed_at, rejection_count, rejection_reason, task_definition
+		FROM tasks`
+	args := []interface{}{}
+	if len(taskGuids) > 0 {
+		query += " WHERE guid IN (" + placeholders(len(taskGuids)) + ")"
+		for _, guid := range taskGuids {
+			args = append(args, guid)
+		}
+	}
+
+	rows, err := d.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []Task{}
+	for rows.Next() {
+		task, err := d.scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+func (d *DB) scanTask(row scanner) (Task, error) {
+	var task Task
+	var taskDefinition []byte
+	err := row.Scan(
+		&task.TaskGuid, &task.Domain, &task.State, &task.CellId, &task.Result, &task.Failed, &task.FailureReason,
+		&task.CreatedAt, &task.UpdatedAt, &task.FirstCompletedAt, &task.RejectionCount, &task.RejectionReason, &taskDefinition,
+	)
+	if err != nil {
+		return Task{}, err
+	}
+
+	err = d.serializer.Unmarshal(d.logger, taskDefinition, &task.TaskDefinition)
+	if err != nil {
+		return Task{}, fmt.Errorf("decoding task definition for %s: %s", task.TaskGuid, err)
+	}
+
+	return task, nil
+}
+
+// Locks returns the locks and presences held in locket.
+func (d *DB) Locks() ([]Lock, error) {
+	rows, err := d.query("SELECT path, owner, value, type, modified_index, modified_id, ttl FROM locks")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locks := []Lock{}
+	for rows.Next() {
+		var lock Lock
+		err := rows.Scan(&lock.Path, &lock.Owner, &lock.Value, &lock.Type, &lock.ModifiedIndex, &lock.ModifiedId, &lock.TTL)
+		if err != nil {
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+
+	return locks, rows.Err()
+}
+
+// OrphanedActualLRPs returns the actual LRPs that have no corresponding
+// desired LRP.
+func (d *DB) OrphanedActualLRPs() ([]ActualLRP, error) {
+	desiredLRPs, err := d.DesiredLRPs()
+	if err != nil {
+		return nil, err
+	}
+
+	actualLRPs, err := d.ActualLRPs()
+	if err != nil {
+		return nil, err
+	}
+
+	desired := map[string]bool{}
+	for _, lrp := range desiredLRPs {
+		desired[lrp.ProcessGuid] = true
+	}
+
+	orphaned := []ActualLRP{}
+	for _, lrp := range actualLRPs {
+		if !desired[lrp.ProcessGuid] {
+			orphaned = append(orphaned, lrp)
+		}
+	}
+
+	return orphaned, nil
+}
+
+func (d *DB) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.db.Query(rebind(d.driverName, query), args...)
+}
+
+func withProcessGuids(query string, processGuids []string) (string, []interface{}) {
+	args := []interface{}{}
+	if len(processGuids) == 0 {
+		return query, args
+	}
+
+	for _, guid := range processGuids {
+		args = append(args, guid)
+	}
+	return query + " WHERE process_guid IN (" + placeholders(len(processGuids)) + ")", args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// rebind replaces the ? placeholders with the numbered placeholders postgres
+// expects.
+func rebind(driverName, query string) string {
+	if driverName != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,13 @@
+package bbsdb_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBbsdb(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "BBSDB Suite")
+}
@@ -0,0 +1,149 @@
+package bbsdb
+
+import (
+	"fmt"
+	"reflect"
+
+	"code.cloudfoundry.org/bbs/encryption"
+	"code.cloudfoundry.org/bbs/format"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeRow scans its values into the destinations of a query.
+type fakeRow []interface{}
+
+func (r fakeRow) Scan(dest ...interface{}) error {
+	if len(dest) != len(r) {
+		return fmt.Errorf("expected %d destinations, got %d", len(r), len(dest))
+	}
+	for i, value := range r {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(value))
+	}
+	return nil
+}
+
+func serializerFor(encryptionConfig encryption.EncryptionConfig) format.Serializer {
+	key, keys, err := encryptionConfig.Parse()
+	Expect(err).NotTo(HaveOccurred())
+	keyManager, err := encryption.NewKeyManager(key, keys)
+	Expect(err).NotTo(HaveOccurred())
+	return newSerializer(keyManager)
+}
+
+var _ = Describe("decoding rows", func() {
+	var (
+		logger     *lagertest.TestLogger
+		serializer format.Serializer
+		db         *DB
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+
+		// the key BBS was configured with encrypts, a rotated key decrypts
+		serializer = serializerFor(encryption.EncryptionConfig{
+			ActiveKeyLabel: "old-key",
+			EncryptionKeys: map[string]string{"old-key": "old-passphrase"},
+		})
+		db = &DB{
+			serializer: serializerFor(encryption.EncryptionConfig{
+				ActiveKeyLabel: "new-key",
+				EncryptionKeys: map[string]string{"old-key": "old-passphrase", "new-key": "new-passphrase"},
+			}),
+			logger: logger,
+		}
+	})
+
+	encode := func(model format.Model) []byte {
+		encoded, err := serializer.Marshal(logger, model)
+		Expect(err).NotTo(HaveOccurred())
+		return encoded
+	}
+
+	It("decrypts and decodes the run info of a desired_lrps row", func() {
+		runInfo := models.DesiredLRPRunInfo{
+			DesiredLRPKey: models.NewDesiredLRPKey("process-guid", "domain", "log-guid"),
+			Action:        models.WrapAction(&models.RunAction{User: "vcap", Path: "ls"}),
+			Ports:         []uint32{8080},
+		}
+
+		lrp, err := db.scanDesiredLRP(fakeRow{
+			"process-guid", "domain", "log-guid", int32(2), int32(128), int32(512), "preloaded:cflinuxfs3",
+			[]byte(`{"cf-router":[{"hostnames":["app.example.com"],"port":8080}]}`),
+			"epoch", uint32(3), encode(&runInfo),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(lrp.ProcessGuid).To(Equal("process-guid"))
+		Expect(lrp.Instances).To(Equal(int32(2)))
+		Expect(lrp.ModificationTag).To(Equal(models.ModificationTag{Epoch: "epoch", Index: 3}))
+		Expect(lrp.Routes).To(HaveKey("cf-router"))
+		Expect(lrp.RunInfo.Equal(&runInfo)).To(BeTrue(), "decoded %#v", lrp.RunInfo)
+	})
+
+	It("decrypts and decodes the net info of an actual_lrps row", func() {
+		netInfo := models.ActualLRPNetInfo{
+			Address:         "10.0.0.1",
+			InstanceAddress: "10.255.0.1",
+			Ports:           []*models.PortMapping{{ContainerPort: 8080, HostPort: 61000}},
+		}
+
+		lrp, err := db.scanActualLRP(fakeRow{
+			"process-guid", int32(1), models.ActualLRP_Ordinary, "domain", "instance-guid", "cell-id", models.ActualLRPStateRunning,
+			"", int64(1000), int32(0), "",
+			"epoch", uint32(1), encode(&netInfo),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(lrp.Index).To(Equal(int32(1)))
+		Expect(lrp.Presence).To(Equal(models.ActualLRP_Ordinary))
+		Expect(lrp.State).To(Equal(models.ActualLRPStateRunning))
+		Expect(lrp.NetInfo.Equal(&netInfo)).To(BeTrue(), "decoded %#v", lrp.NetInfo)
+	})
+
+	It("leaves the net info of an unclaimed actual_lrps row empty", func() {
+		lrp, err := db.scanActualLRP(fakeRow{
+			"process-guid", int32(0), models.ActualLRP_Ordinary, "domain", "", "", models.ActualLRPStateUnclaimed,
+			"", int64(1000), int32(0), "",
+			"epoch", uint32(0), []byte{},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lrp.NetInfo).To(Equal(models.ActualLRPNetInfo{}))
+	})
+
+	It("decrypts and decodes the task definition of a tasks row", func() {
+		taskDefinition := models.TaskDefinition{
+			RootFs:     "preloaded:cflinuxfs3",
+			Action:     models.WrapAction(&models.RunAction{User: "vcap", Path: "true"}),
+			MemoryMb:   128,
+			ResultFile: "/tmp/result",
+		}
+
+		task, err := db.scanTask(fakeRow{
+			"task-guid", "domain", models.Task_Completed, "cell-id", "the result", true, "it failed",
+			int64(1), int64(2), int64(3), int32(0), "", encode(&taskDefinition),
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(task.State).To(Equal(models.Task_Completed))
+		Expect(task.Failed).To(BeTrue())
+		Expect(task.TaskDefinition.Equal(&taskDefinition)).To(BeTrue(), "decoded %#v", task.TaskDefinition)
+	})
+
+	It("fails to decrypt columns encrypted with a key it does not have", func() {
+		serializer = serializerFor(encryption.EncryptionConfig{
+			ActiveKeyLabel: "unknown-key",
+			EncryptionKeys: map[string]string{"unknown-key": "unknown-passphrase"},
+		})
+		taskDefinition := models.TaskDefinition{RootFs: "preloaded:cflinuxfs3"}
+
+		_, err := db.scanTask(fakeRow{
+			"task-guid", "domain", models.Task_Pending, "", "", false, "",
+			int64(1), int64(2), int64(0), int32(0), "", encode(&taskDefinition),
+		})
+		Expect(err).To(MatchError(ContainSubstring("decoding task definition for task-guid")))
+	})
+})
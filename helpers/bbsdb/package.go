@@ -0,0 +1 @@
+package bbsdb // import "code.cloudfoundry.org/inigo/helpers/bbsdb"
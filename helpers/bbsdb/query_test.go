@@ -0,0 +1,32 @@
+package bbsdb
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("queries", func() {
+	Describe("withProcessGuids", func() {
+		It("leaves the query alone without guids", func() {
+			query, args := withProcessGuids("SELECT * FROM actual_lrps", nil)
+			Expect(query).To(Equal("SELECT * FROM actual_lrps"))
+			Expect(args).To(BeEmpty())
+		})
+
+		It("filters by the guids", func() {
+			query, args := withProcessGuids("SELECT * FROM actual_lrps", []string{"a", "b"})
+			Expect(query).To(Equal("SELECT * FROM actual_lrps WHERE process_guid IN (?, ?)"))
+			Expect(args).To(Equal([]interface{}{"a", "b"}))
+		})
+	})
+
+	Describe("rebind", func() {
+		It("numbers the placeholders for postgres", func() {
+			Expect(rebind("postgres", "SELECT * FROM tasks WHERE task_guid IN (?, ?)")).To(Equal("SELECT * FROM tasks WHERE task_guid IN ($1, $2)"))
+		})
+
+		It("keeps the placeholders for mysql", func() {
+			Expect(rebind("mysql", "SELECT * FROM tasks WHERE task_guid IN (?, ?)")).To(Equal("SELECT * FROM tasks WHERE task_guid IN (?, ?)"))
+		})
+	})
+})
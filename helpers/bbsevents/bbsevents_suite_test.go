@@ -0,0 +1,13 @@
+package bbsevents_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBbsevents(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bbsevents Suite")
+}
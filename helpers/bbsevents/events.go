@@ -0,0 +1,151 @@
+package bbsevents
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/bbs/models"
+)
+
+// EventSpec describes an event a spec expects BBS to emit.
+type EventSpec struct {
+	Description string
+	Matches     func(models.Event) bool
+}
+
+func DesiredLRPCreated(processGuid string) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("desired_lrp_created process-guid=%s", processGuid),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.DesiredLRPCreatedEvent)
+			return ok && e.DesiredLrp.ProcessGuid == processGuid
+		},
+	}
+}
+
+func DesiredLRPChanged(processGuid string) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("desired_lrp_changed process-guid=%s", processGuid),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.DesiredLRPChangedEvent)
+			return ok && e.After.ProcessGuid == processGuid
+		},
+	}
+}
+
+func DesiredLRPRemoved(processGuid string) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("desired_lrp_removed process-guid=%s", processGuid),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.DesiredLRPRemovedEvent)
+			return ok && e.DesiredLrp.ProcessGuid == processGuid
+		},
+	}
+}
+
+func ActualLRPInstanceCreated(processGuid string, index int) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("actual_lrp_instance_created process-guid=%s index=%d", processGuid, index),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.ActualLRPInstanceCreatedEvent)
+			return ok && e.ActualLrp.ProcessGuid == processGuid && e.ActualLrp.Index == int32(index)
+		},
+	}
+}
+
+// ActualLRPInstanceChanged matches an instance moving from one state to
+// another, e.g. models.ActualLRPStateClaimed to models.ActualLRPStateRunning.
+func ActualLRPInstanceChanged(processGuid string, index int, from, to string) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("actual_lrp_instance_changed process-guid=%s index=%d %s->%s", processGuid, index, from, to),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.ActualLRPInstanceChangedEvent)
+			return ok &&
+				e.ProcessGuid == processGuid &&
+				e.Index == int32(index) &&
+				e.Before.State == from &&
+				e.After.State == to
+		},
+	}
+}
+
+func ActualLRPInstanceRemoved(processGuid string, index int) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("actual_lrp_instance_removed process-guid=%s index=%d", processGuid, index),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.ActualLRPInstanceRemovedEvent)
+			return ok && e.ActualLrp.ProcessGuid == processGuid && e.ActualLrp.Index == int32(index)
+		},
+	}
+}
+
+func ActualLRPCrashed(processGuid string, index int) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("actual_lrp_crashed process-guid=%s index=%d", processGuid, index),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.ActualLRPCrashedEvent)
+			return ok && e.ProcessGuid == processGuid && e.Index == int32(index)
+		},
+	}
+}
+
+func TaskCreated(taskGuid string) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("task_created task-guid=%s", taskGuid),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.TaskCreatedEvent)
+			return ok && e.Task.TaskGuid == taskGuid
+		},
+	}
+}
+
+func TaskChanged(taskGuid string, from, to models.Task_State) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("task_changed task-guid=%s %s->%s", taskGuid, from, to),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.TaskChangedEvent)
+			return ok && e.After.TaskGuid == taskGuid && e.Before.State == from && e.After.State == to
+		},
+	}
+}
+
+func TaskRemoved(taskGuid string) EventSpec {
+	return EventSpec{
+		Description: fmt.Sprintf("task_removed task-guid=%s", taskGuid),
+		Matches: func(event models.Event) bool {
+			e, ok := event.(*models.TaskRemovedEvent)
+			return ok && e.Task.TaskGuid == taskGuid
+		},
+	}
+}
+
+// Describe summarizes an event on a single line.
+func Describe(event models.Event) string {
+	switch e := event.(type) {
+	case *models.DesiredLRPCreatedEvent:
+		return fmt.Sprintf("%s process-guid=%s instances=%d", e.EventType(), e.DesiredLrp.ProcessGuid, e.DesiredLrp.Instances)
+	case *models.DesiredLRPChangedEvent:
+		return fmt.Sprintf("%s process-guid=%s instances=%d->%d", e.EventType(), e.After.ProcessGuid, e.Before.Instances, e.After.Instances)
+	case *models.DesiredLRPRemovedEvent:
+		return fmt.Sprintf("%s process-guid=%s", e.EventType(), e.DesiredLrp.ProcessGuid)
+	case *models.ActualLRPInstanceCreatedEvent:
+		return describeActualLRP(e.EventType(), e.ActualLrp)
+	case *models.ActualLRPInstanceChangedEvent:
+		return fmt.Sprintf("%s process-guid=%s index=%d cell=%s %s->%s", e.EventType(), e.ProcessGuid, e.Index, e.CellId, e.Before.State, e.After.State)
+	case *models.ActualLRPInstanceRemovedEvent:
+		return describeActualLRP(e.EventType(), e.ActualLrp)
+	case *models.ActualLRPCrashedEvent:
+		return fmt.Sprintf("%s process-guid=%s index=%d cell=%s crash-count=%d reason=%q", e.EventType(), e.ProcessGuid, e.Index, e.CellId, e.CrashCount, e.CrashReason)
+	case *models.TaskCreatedEvent:
+		return fmt.Sprintf("%s task-guid=%s state=%s", e.EventType(), e.Task.TaskGuid, e.Task.State)
+	case *models.TaskChangedEvent:
+		return fmt.Sprintf("%s task-guid=%s %s->%s", e.EventType(), e.After.TaskGuid, e.Before.State, e.After.State)
+	case *models.TaskRemovedEvent:
+		return fmt.Sprintf("%s task-guid=%s", e.EventType(), e.Task.TaskGuid)
+	default:
+		return fmt.Sprintf("%s key=%s", event.EventType(), event.Key())
+	}
+}
+
+func describeActualLRP(eventType string, lrp *models.ActualLRP) string {
+	return fmt.Sprintf("%s process-guid=%s index=%d cell=%s state=%s", eventType, lrp.ProcessGuid, lrp.Index, lrp.CellId, lrp.State)
+}
@@ -0,0 +1,219 @@
+package bbsevents
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/onsi/gomega/types"
+)
+
+// Expectation is one step of an expected event sequence: a single event, or
+// a group of events that may be received in any order.
+type Expectation interface {
+	eventSpecs() []EventSpec
+}
+
+func (s EventSpec) eventSpecs() []EventSpec {
+	return []EventSpec{s}
+}
+
+type unordered []EventSpec
+
+func (u unordered) eventSpecs() []EventSpec {
+	return u
+}
+
+// Unordered groups events that must all be received, in any order, at this
+// point of a sequence.
+func Unordered(specs ...EventSpec) Expectation {
+	return unordered(specs)
+}
+
+// ReceiveEventsInOrder succeeds if the events, a []models.Event or a
+// *Recorder, contain the expectations in order. Other events may be received
+// in between, e.g.
+//
+//	Eventually(recorder.Events).Should(bbsevents.ReceiveEventsInOrder(
+//		bbsevents.ActualLRPInstanceCreated(processGuid, 0),
+//		bbsevents.ActualLRPInstanceChanged(processGuid, 0, models.ActualLRPStateClaimed, models.ActualLRPStateRunning),
+//	))
+func ReceiveEventsInOrder(expectations ...Expectation) types.GomegaMatcher {
+	return &eventSequenceMatcher{expectations: expectations}
+}
+
+// ReceiveEvents succeeds if the events contain all of the specs, in any
+// order.
+func ReceiveEvents(specs ...EventSpec) types.GomegaMatcher {
+	return ReceiveEventsInOrder(Unordered(specs...))
+}
+
+type eventSequenceMatcher struct {
+	expectations []Expectation
+
+	events []models.Event
+	// streamErr is the error that ended a recorder's event stream.
+	streamErr error
+	// matched holds, for each spec of each expectation, the index of the
+	// event it matched or -1.
+	matched  [][]int
+	failedAt int
+}
+
+func (m *eventSequenceMatcher) Match(actual interface{}) (bool, error) {
+	switch a := actual.(type) {
+	case []models.Event:
+		m.events = a
+		m.streamErr = nil
+	case *Recorder:
+		m.events = a.Events()
+		m.streamErr = a.Err()
+	default:
+		return false, fmt.Errorf("ReceiveEventsInOrder expects a []models.Event or a *bbsevents.Recorder, got %T", actual)
+	}
+
+	m.matched = make([][]int, len(m.expectations))
+	m.failedAt = -1
+
+	start := 0
+	for i, expectation := range m.expectations {
+		specs := expectation.eventSpecs()
+		assignment, end, ok := matchGroup(specs, m.events, start)
+		m.matched[i] = assignment
+		if !ok {
+			m.failedAt = i
+			return false, nil
+		}
+		start = end + 1
+	}
+
+	return true, nil
+}
+
+// matchGroup finds the shortest run of events from start in which every
+// spec matches a distinct event. It returns the index of the event each spec
+// matched, and the end of the run. If there is no such run, the assignment
+// is the largest partial one found.
+func matchGroup(specs []EventSpec, events []models.Event, start int) ([]int, int, bool) {
+	for end := start + len(specs) - 1; end < len(events); end++ {
+		assignment, count := bipartiteMatch(specs, events, start, end)
+		if count == len(specs) {
+			return assignment, end, true
+		}
+	}
+
+	assignment, _ := bipartiteMatch(specs, events, start, len(events)-1)
+	return assignment, -1, false
+}
+
+// bipartiteMatch assigns specs to distinct events between start and end,
+// inclusive, maximizing the number of specs assigned.
+func bipartiteMatch(specs []EventSpec, events []models.Event, start, end int) ([]int, int) {
+	assignment := make([]int, len(specs))
+	for i := range assignment {
+		assignment[i] = -1
+	}
+	owner := map[int]int{}
+
+	var augment func(spec int, visited map[int]bool) bool
+	augment = func(spec int, visited map[int]bool) bool {
+		for event := start; event <= end; event++ {
+			if visited[event] || !specs[spec].Matches(events[event]) {
+				continue
+			}
+			visited[event] = true
+
+			other, taken := owner[event]
+			if !taken || augment(other, visited) {
+				owner[event] = spec
+				assignment[spec] = event
+				return true
+			}
+		}
+		return false
+	}
+
+	count := 0
+	for spec := range specs {
+		if augment(spec, map[int]bool{}) {
+			count++
+		}
+	}
+	return assignment, count
+}
+
+func (m *eventSequenceMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected to receive BBS events in order:\n%s\nReceived %d events:\n%s%s",
+		m.describeExpectations(), len(m.events), m.describeEvents(), m.describeStreamErr())
+}
+
+func (m *eventSequenceMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected not to receive BBS events in order:\n%s\nReceived %d events:\n%s%s",
+		m.describeExpectations(), len(m.events), m.describeEvents(), m.describeStreamErr())
+}
+
+func (m *eventSequenceMatcher) describeStreamErr() string {
+	if m.streamErr == nil {
+		return ""
+	}
+	return fmt.Sprintf("The event stream ended early: %s\n", m.streamErr)
+}
+
+func (m *eventSequenceMatcher) describeExpectations() string {
+	buffer := &bytes.Buffer{}
+
+	for i, expectation := range m.expectations {
+		specs := expectation.eventSpecs()
+
+		if _, ok := expectation.(unordered); ok {
+			fmt.Fprintf(buffer, "  %d. in any order:\n", i+1)
+			for j, spec := range specs {
+				fmt.Fprintf(buffer, "       %-14s %s\n", m.status(i, j), spec.Description)
+			}
+			continue
+		}
+
+		fmt.Fprintf(buffer, "  %d. %-14s %s\n", i+1, m.status(i, 0), specs[0].Description)
+	}
+
+	return buffer.String()
+}
+
+func (m *eventSequenceMatcher) status(expectation, spec int) string {
+	if m.failedAt != -1 && expectation > m.failedAt {
+		return "[not checked]"
+	}
+
+	event := m.matched[expectation][spec]
+	if event == -1 {
+		return "[missing]"
+	}
+	return fmt.Sprintf("[event %d]", event+1)
+}
+
+func (m *eventSequenceMatcher) describeEvents() string {
+	labels := map[int][]string{}
+	for i, assignment := range m.matched {
+		if i == m.failedAt {
+			break
+		}
+		for j, event := range assignment {
+			label := fmt.Sprintf("%d", i+1)
+			if len(assignment) > 1 {
+				label = fmt.Sprintf("%d.%d", i+1, j+1)
+			}
+			labels[event] = append(labels[event], label)
+		}
+	}
+
+	buffer := &bytes.Buffer{}
+	for i, event := range m.events {
+		fmt.Fprintf(buffer, "  %3d. %s", i+1, Describe(event))
+		if expectations, ok := labels[i]; ok {
+			fmt.Fprintf(buffer, "  <- expected %s", strings.Join(expectations, ", "))
+		}
+		fmt.Fprintln(buffer)
+	}
+	return buffer.String()
+}
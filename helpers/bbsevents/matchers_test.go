@@ -0,0 +1,109 @@
+package bbsevents_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers/bbsevents"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func instanceCreated(processGuid string, index int) models.Event {
+	return &models.ActualLRPInstanceCreatedEvent{
+		ActualLrp: &models.ActualLRP{
+			ActualLRPKey: models.NewActualLRPKey(processGuid, int32(index), "inigo"),
+			State:        models.ActualLRPStateUnclaimed,
+		},
+	}
+}
+
+func instanceChanged(processGuid string, index int, from, to string) models.Event {
+	return &models.ActualLRPInstanceChangedEvent{
+		ActualLRPKey: models.NewActualLRPKey(processGuid, int32(index), "inigo"),
+		Before:       &models.ActualLRPInfo{State: from},
+		After:        &models.ActualLRPInfo{State: to},
+	}
+}
+
+var _ = Describe("ReceiveEventsInOrder", func() {
+	var events []models.Event
+
+	BeforeEach(func() {
+		events = []models.Event{
+			models.NewDesiredLRPCreatedEvent(&models.DesiredLRP{ProcessGuid: "pg", Instances: 2}),
+			instanceCreated("pg", 0),
+			instanceCreated("pg", 1),
+			instanceChanged("pg", 1, models.ActualLRPStateUnclaimed, models.ActualLRPStateClaimed),
+			instanceChanged("pg", 0, models.ActualLRPStateUnclaimed, models.ActualLRPStateClaimed),
+			instanceChanged("pg", 0, models.ActualLRPStateClaimed, models.ActualLRPStateRunning),
+		}
+	})
+
+	It("matches events in order with other events in between", func() {
+		Expect(events).To(bbsevents.ReceiveEventsInOrder(
+			bbsevents.DesiredLRPCreated("pg"),
+			bbsevents.ActualLRPInstanceCreated("pg", 0),
+			bbsevents.ActualLRPInstanceChanged("pg", 0, models.ActualLRPStateClaimed, models.ActualLRPStateRunning),
+		))
+	})
+
+	It("does not match events out of order", func() {
+		Expect(events).NotTo(bbsevents.ReceiveEventsInOrder(
+			bbsevents.ActualLRPInstanceChanged("pg", 1, models.ActualLRPStateUnclaimed, models.ActualLRPStateClaimed),
+			bbsevents.ActualLRPInstanceCreated("pg", 0),
+		))
+	})
+
+	It("matches groups of events in any order", func() {
+		Expect(events).To(bbsevents.ReceiveEventsInOrder(
+			bbsevents.DesiredLRPCreated("pg"),
+			bbsevents.Unordered(
+				bbsevents.ActualLRPInstanceChanged("pg", 0, models.ActualLRPStateUnclaimed, models.ActualLRPStateClaimed),
+				bbsevents.ActualLRPInstanceChanged("pg", 1, models.ActualLRPStateUnclaimed, models.ActualLRPStateClaimed),
+			),
+			bbsevents.ActualLRPInstanceChanged("pg", 0, models.ActualLRPStateClaimed, models.ActualLRPStateRunning),
+		))
+
+		Expect(events).To(bbsevents.ReceiveEvents(
+			bbsevents.ActualLRPInstanceCreated("pg", 1),
+			bbsevents.ActualLRPInstanceCreated("pg", 0),
+		))
+	})
+
+	It("assigns each expected event a distinct event", func() {
+		anyCreated := bbsevents.EventSpec{
+			Description: "any instance created",
+			Matches: func(event models.Event) bool {
+				_, ok := event.(*models.ActualLRPInstanceCreatedEvent)
+				return ok
+			},
+		}
+
+		Expect(events).To(bbsevents.ReceiveEvents(anyCreated, bbsevents.ActualLRPInstanceCreated("pg", 0)))
+		Expect(events).NotTo(bbsevents.ReceiveEvents(anyCreated, anyCreated, anyCreated))
+	})
+
+	It("lists the expected and received events when it fails", func() {
+		matcher := bbsevents.ReceiveEventsInOrder(
+			bbsevents.DesiredLRPCreated("pg"),
+			bbsevents.ActualLRPInstanceChanged("pg", 1, models.ActualLRPStateClaimed, models.ActualLRPStateRunning),
+			bbsevents.ActualLRPInstanceRemoved("pg", 1),
+		)
+
+		success, err := matcher.Match(events)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(success).To(BeFalse())
+
+		message := matcher.FailureMessage(events)
+		Expect(message).To(ContainSubstring("1. [event 1]      desired_lrp_created process-guid=pg"))
+		Expect(message).To(ContainSubstring("2. [missing]      actual_lrp_instance_changed process-guid=pg index=1 CLAIMED->RUNNING"))
+		Expect(message).To(ContainSubstring("3. [not checked]  actual_lrp_instance_removed"))
+		Expect(message).To(ContainSubstring("Received 6 events:"))
+		Expect(message).To(ContainSubstring("1. desired_lrp_created process-guid=pg instances=2  <- expected 1"))
+		Expect(message).To(ContainSubstring("6. actual_lrp_instance_changed process-guid=pg index=0 cell= CLAIMED->RUNNING\n"))
+	})
+
+	It("errors on anything but events or a recorder", func() {
+		_, err := bbsevents.ReceiveEventsInOrder().Match("events")
+		Expect(err).To(HaveOccurred())
+	})
+})
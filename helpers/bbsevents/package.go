@@ -0,0 +1 @@
+package bbsevents // import "code.cloudfoundry.org/inigo/helpers/bbsevents"
@@ -0,0 +1,123 @@
+package bbsevents
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager"
+)
+
+// Recorder collects the LRP instance and task events BBS emits, in the order
+// they are received.
+type Recorder struct {
+	logger    lager.Logger
+	bbsClient bbs.Client
+
+	lock         sync.Mutex
+	events       []models.Event
+	err          error
+	listeners    []func(models.Event)
+	eventSources []events.EventSource
+	wg           sync.WaitGroup
+}
+
+func NewRecorder(logger lager.Logger, bbsClient bbs.Client) *Recorder {
+	return &Recorder{
+		logger:    logger.Session("event-recorder"),
+		bbsClient: bbsClient,
+	}
+}
+
+// OnEvent registers a function that is called with every event as it is
+// received, e.g. to add it to a timeline. It must be called before Start.
+// The function may be called from several goroutines at once.
+func (r *Recorder) OnEvent(listener func(models.Event)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.listeners = append(r.listeners, listener)
+}
+
+// Start subscribes to the instance and task event streams.
+func (r *Recorder) Start() error {
+	instanceEvents, err := r.bbsClient.SubscribeToInstanceEvents(r.logger)
+	if err != nil {
+		return err
+	}
+
+	taskEvents, err := r.bbsClient.SubscribeToTaskEvents(r.logger)
+	if err != nil {
+		instanceEvents.Close()
+		return err
+	}
+
+	r.lock.Lock()
+	r.eventSources = []events.EventSource{instanceEvents, taskEvents}
+	r.lock.Unlock()
+
+	r.wg.Add(2)
+	go r.record(instanceEvents)
+	go r.record(taskEvents)
+
+	return nil
+}
+
+// Stop closes the event streams. The events received so far are kept.
+func (r *Recorder) Stop() {
+	r.lock.Lock()
+	eventSources := r.eventSources
+	r.eventSources = nil
+	r.lock.Unlock()
+
+	for _, eventSource := range eventSources {
+		eventSource.Close()
+	}
+	r.wg.Wait()
+}
+
+// Events returns the events received so far. It can be polled, e.g.
+//
+//	Eventually(recorder.Events).Should(bbsevents.ReceiveEventsInOrder(...))
+func (r *Recorder) Events() []models.Event {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	events := make([]models.Event, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// Err returns the error that ended one of the event streams before Stop was
+// called, or nil. No further events are received from that stream.
+func (r *Recorder) Err() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.err
+}
+
+func (r *Recorder) record(eventSource events.EventSource) {
+	defer r.wg.Done()
+
+	for {
+		event, err := eventSource.Next()
+		if err != nil {
+			r.lock.Lock()
+			if r.eventSources != nil && r.err == nil {
+				r.logger.Error("event-stream-failed", err)
+				r.err = err
+			}
+			r.lock.Unlock()
+			return
+		}
+
+		r.lock.Lock()
+		r.events = append(r.events, event)
+		listeners := r.listeners
+		r.lock.Unlock()
+
+		for _, listener := range listeners {
+			listener(event)
+		}
+	}
+}
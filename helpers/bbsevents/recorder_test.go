@@ -0,0 +1,101 @@
+package bbsevents_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/bbs/events/eventfakes"
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers/bbsevents"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type streamEnd struct {
+	err error
+}
+
+// newEventSource returns an event source that emits the events sent on the
+// channel and fails with the error of a streamEnd, or with "closed" once it
+// is closed.
+func newEventSource(events chan interface{}) *eventfakes.FakeEventSource {
+	closed := make(chan struct{})
+
+	eventSource := &eventfakes.FakeEventSource{}
+	eventSource.NextStub = func() (models.Event, error) {
+		select {
+		case value := <-events:
+			if end, ok := value.(streamEnd); ok {
+				return nil, end.err
+			}
+			return value.(models.Event), nil
+		case <-closed:
+			return nil, errors.New("closed")
+		}
+	}
+	eventSource.CloseStub = func() error {
+		close(closed)
+		return nil
+	}
+	return eventSource
+}
+
+var _ = Describe("Recorder", func() {
+	var (
+		instanceEvents chan interface{}
+		taskEvents     chan interface{}
+		recorder       *bbsevents.Recorder
+		received       chan models.Event
+	)
+
+	BeforeEach(func() {
+		instanceEvents = make(chan interface{}, 10)
+		taskEvents = make(chan interface{}, 10)
+
+		bbsClient := &fake_bbs.FakeClient{}
+		bbsClient.SubscribeToInstanceEventsReturns(newEventSource(instanceEvents), nil)
+		bbsClient.SubscribeToTaskEventsReturns(newEventSource(taskEvents), nil)
+
+		received = make(chan models.Event, 10)
+		recorder = bbsevents.NewRecorder(lagertest.NewTestLogger("test"), bbsClient)
+		recorder.OnEvent(func(event models.Event) {
+			received <- event
+		})
+		Expect(recorder.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		recorder.Stop()
+	})
+
+	It("records the instance and task events and passes them on", func() {
+		instanceEvents <- instanceCreated("pg", 0)
+		Eventually(recorder.Events).Should(HaveLen(1))
+		taskEvents <- models.NewTaskCreatedEvent(&models.Task{TaskGuid: "tg"})
+
+		Eventually(recorder).Should(bbsevents.ReceiveEventsInOrder(
+			bbsevents.ActualLRPInstanceCreated("pg", 0),
+			bbsevents.TaskCreated("tg"),
+		))
+		Eventually(received).Should(Receive())
+		Eventually(received).Should(Receive())
+		Expect(recorder.Err()).NotTo(HaveOccurred())
+	})
+
+	It("keeps the error that ended a stream and reports it on failure", func() {
+		instanceEvents <- instanceCreated("pg", 0)
+		instanceEvents <- streamEnd{errors.New("connection reset")}
+		Eventually(recorder.Err).Should(MatchError("connection reset"))
+
+		matcher := bbsevents.ReceiveEvents(bbsevents.ActualLRPInstanceRemoved("pg", 0))
+		Expect(matcher.Match(recorder)).To(BeFalse())
+		Expect(matcher.FailureMessage(recorder)).To(ContainSubstring("The event stream ended early: connection reset"))
+		Expect(recorder.Events()).To(HaveLen(1))
+	})
+
+	It("does not keep the error of streams closed by Stop", func() {
+		recorder.Stop()
+		Expect(recorder.Err()).NotTo(HaveOccurred())
+	})
+})
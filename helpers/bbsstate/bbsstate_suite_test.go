@@ -0,0 +1,13 @@
+package bbsstate_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestBbsstate(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Bbsstate Suite")
+}
@@ -0,0 +1,238 @@
+package bbsstate
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/routing-info/cfroutes"
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+)
+
+// BeRunningOn succeeds if an ActualLRP is running on the given cell.
+func BeRunningOn(cellID string) gomega.OmegaMatcher {
+	return &stateMatcher{
+		description: "to be running on a cell",
+		expected:    fields("State", models.ActualLRPStateRunning, "CellId", cellID),
+		match: func(actual interface{}) ([]string, bool, error) {
+			lrp, err := toActualLRP("BeRunningOn", actual)
+			if err != nil {
+				return nil, false, err
+			}
+			return fields("State", lrp.State, "CellId", lrp.CellId),
+				lrp.State == models.ActualLRPStateRunning && lrp.CellId == cellID, nil
+		},
+	}
+}
+
+// HavePresence succeeds if an ActualLRP has the given presence, e.g.
+// models.ActualLRP_Evacuating.
+func HavePresence(presence models.ActualLRP_Presence) gomega.OmegaMatcher {
+	return &stateMatcher{
+		description: "to have presence",
+		expected:    fields("Presence", presence.String()),
+		match: func(actual interface{}) ([]string, bool, error) {
+			lrp, err := toActualLRP("HavePresence", actual)
+			if err != nil {
+				return nil, false, err
+			}
+			return fields("Presence", lrp.Presence.String(), "State", lrp.State, "CellId", lrp.CellId),
+				lrp.Presence == presence, nil
+		},
+	}
+}
+
+// HaveCrashed succeeds if an ActualLRP has crashed exactly the given number
+// of times.
+func HaveCrashed(times int) gomega.OmegaMatcher {
+	return &stateMatcher{
+		description: "to have crashed",
+		expected:    fields("CrashCount", fmt.Sprint(times)),
+		match: func(actual interface{}) ([]string, bool, error) {
+			lrp, err := toActualLRP("HaveCrashed", actual)
+			if err != nil {
+				return nil, false, err
+			}
+			return fields("CrashCount", fmt.Sprint(lrp.CrashCount), "CrashReason", lrp.CrashReason, "State", lrp.State),
+				lrp.CrashCount == int32(times), nil
+		},
+	}
+}
+
+// HavePlacementError succeeds if an ActualLRP could not be placed, with a
+// placement error containing substr.
+func HavePlacementError(substr string) gomega.OmegaMatcher {
+	return &stateMatcher{
+		description: "to have a placement error",
+		expected:    fields("PlacementError", fmt.Sprintf("containing %q", substr)),
+		match: func(actual interface{}) ([]string, bool, error) {
+			lrp, err := toActualLRP("HavePlacementError", actual)
+			if err != nil {
+				return nil, false, err
+			}
+			return fields("PlacementError", fmt.Sprintf("%q", lrp.PlacementError), "State", lrp.State),
+				lrp.PlacementError != "" && strings.Contains(lrp.PlacementError, substr), nil
+		},
+	}
+}
+
+// BeCompletedTask succeeds if a Task has completed, failed or not, with a
+// failure reason containing reason.
+func BeCompletedTask(failed bool, reason string) gomega.OmegaMatcher {
+	return &stateMatcher{
+		description: "to be a completed task",
+		expected:    fields("State", models.Task_Completed.String(), "Failed", fmt.Sprint(failed), "FailureReason", fmt.Sprintf("containing %q", reason)),
+		match: func(actual interface{}) ([]string, bool, error) {
+			var task *models.Task
+			switch a := actual.(type) {
+			case *models.Task:
+				task = a
+			case models.Task:
+				task = &a
+			}
+			if task == nil {
+				return nil, false, fmt.Errorf("BeCompletedTask expects a *models.Task, got\n%s", format.Object(actual, 1))
+			}
+
+			return fields("State", task.State.String(), "Failed", fmt.Sprint(task.Failed), "FailureReason", fmt.Sprintf("%q", task.FailureReason)),
+				task.State == models.Task_Completed && task.Failed == failed && strings.Contains(task.FailureReason, reason), nil
+		},
+	}
+}
+
+// HaveRoutes succeeds if a DesiredLRP, or its routes, have CF routes for all
+// of the given hostnames.
+func HaveRoutes(hostnames ...string) gomega.OmegaMatcher {
+	return &stateMatcher{
+		description: "to have routes",
+		expected:    fields("Hostnames", strings.Join(hostnames, ", ")),
+		match: func(actual interface{}) ([]string, bool, error) {
+			routes, err := toRoutes(actual)
+			if err != nil {
+				return nil, false, err
+			}
+
+			var cfRoutes cfroutes.CFRoutes
+			if routes != nil {
+				cfRoutes, err = cfroutes.CFRoutesFromRoutingInfo(*routes)
+				if err != nil {
+					return nil, false, err
+				}
+			}
+
+			routed := map[string]bool{}
+			actualHostnames := []string{}
+			for _, route := range cfRoutes {
+				for _, hostname := range route.Hostnames {
+					routed[hostname] = true
+					actualHostnames = append(actualHostnames, fmt.Sprintf("%s:%d", hostname, route.Port))
+				}
+			}
+
+			for _, hostname := range hostnames {
+				if !routed[hostname] {
+					return fields("Hostnames", strings.Join(actualHostnames, ", ")), false, nil
+				}
+			}
+			return fields("Hostnames", strings.Join(actualHostnames, ", ")), true, nil
+		},
+	}
+}
+
+// stateMatcher matches a BBS model by a few of its fields, and reports the
+// expected and actual values of those fields.
+type stateMatcher struct {
+	description string
+	expected    []string
+	match       func(actual interface{}) ([]string, bool, error)
+
+	actual []string
+}
+
+func (matcher *stateMatcher) Match(actual interface{}) (success bool, err error) {
+	matcher.actual, success, err = matcher.match(actual)
+	return success, err
+}
+
+func (matcher *stateMatcher) FailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected\n%s\n%s with\n%s\nbut it has\n%s", describeModel(actual), matcher.description, strings.Join(matcher.expected, "\n"), strings.Join(matcher.actual, "\n"))
+}
+
+func (matcher *stateMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected\n%s\nnot %s with\n%s\nbut it has\n%s", describeModel(actual), matcher.description, strings.Join(matcher.expected, "\n"), strings.Join(matcher.actual, "\n"))
+}
+
+func toActualLRP(matcher string, actual interface{}) (*models.ActualLRP, error) {
+	switch a := actual.(type) {
+	case *models.ActualLRP:
+		if a != nil {
+			return a, nil
+		}
+	case models.ActualLRP:
+		return &a, nil
+	}
+	return nil, fmt.Errorf("%s expects a *models.ActualLRP, got\n%s", matcher, format.Object(actual, 1))
+}
+
+// toRoutes returns the routes of a DesiredLRP or its scheduling info, which
+// may be nil.
+func toRoutes(actual interface{}) (*models.Routes, error) {
+	switch a := actual.(type) {
+	case *models.DesiredLRP:
+		if a != nil {
+			return a.Routes, nil
+		}
+	case models.DesiredLRP:
+		return a.Routes, nil
+	case *models.DesiredLRPSchedulingInfo:
+		if a != nil {
+			return &a.Routes, nil
+		}
+	case models.Routes:
+		return &a, nil
+	case *models.Routes:
+		return a, nil
+	}
+	return nil, fmt.Errorf("HaveRoutes expects a *models.DesiredLRP or models.Routes, got\n%s", format.Object(actual, 1))
+}
+
+// describeModel identifies the LRP or task being matched without dumping
+// the whole model. Nil models are formatted as they are.
+func describeModel(actual interface{}) string {
+	switch a := actual.(type) {
+	case *models.ActualLRP:
+		if a != nil {
+			return fmt.Sprintf("  ActualLRP ProcessGuid=%s Index=%d InstanceGuid=%s", a.ProcessGuid, a.Index, a.InstanceGuid)
+		}
+	case models.ActualLRP:
+		return describeModel(&a)
+	case *models.Task:
+		if a != nil {
+			return fmt.Sprintf("  Task TaskGuid=%s", a.TaskGuid)
+		}
+	case models.Task:
+		return describeModel(&a)
+	case *models.DesiredLRP:
+		if a != nil {
+			return fmt.Sprintf("  DesiredLRP ProcessGuid=%s", a.ProcessGuid)
+		}
+	case models.DesiredLRP:
+		return describeModel(&a)
+	case *models.DesiredLRPSchedulingInfo:
+		if a != nil {
+			return fmt.Sprintf("  DesiredLRPSchedulingInfo ProcessGuid=%s", a.ProcessGuid)
+		}
+	}
+	return format.Object(actual, 1)
+}
+
+// fields formats name, value pairs one per line, indented like
+// helpers.ActualLRPCrashedEventMatcher's failure messages.
+func fields(namesAndValues ...string) []string {
+	lines := []string{}
+	for i := 0; i+1 < len(namesAndValues); i += 2 {
+		lines = append(lines, fmt.Sprintf("  %s=%s", namesAndValues[i], namesAndValues[i+1]))
+	}
+	return lines
+}
@@ -0,0 +1,110 @@
+package bbsstate_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers/bbsstate"
+	"code.cloudfoundry.org/routing-info/cfroutes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BeRunningOn", func() {
+	var lrp *models.ActualLRP
+
+	BeforeEach(func() {
+		lrp = &models.ActualLRP{
+			ActualLRPKey:         models.NewActualLRPKey("pg", 1, "inigo"),
+			ActualLRPInstanceKey: models.NewActualLRPInstanceKey("ig", "cell-a"),
+			State:                models.ActualLRPStateRunning,
+		}
+	})
+
+	It("matches an LRP running on the cell", func() {
+		Expect(lrp).To(bbsstate.BeRunningOn("cell-a"))
+		Expect(*lrp).To(bbsstate.BeRunningOn("cell-a"))
+	})
+
+	It("reports the state and cell of an LRP running elsewhere", func() {
+		matcher := bbsstate.BeRunningOn("cell-b")
+		Expect(matcher.Match(lrp)).To(BeFalse())
+		Expect(matcher.FailureMessage(lrp)).To(Equal(
+			"Expected\n  ActualLRP ProcessGuid=pg Index=1 InstanceGuid=ig\nto be running on a cell with\n  State=RUNNING\n  CellId=cell-b\nbut it has\n  State=RUNNING\n  CellId=cell-a",
+		))
+	})
+
+	It("reports the LRP when negated", func() {
+		matcher := bbsstate.BeRunningOn("cell-a")
+		Expect(matcher.Match(lrp)).To(BeTrue())
+		Expect(matcher.NegatedFailureMessage(lrp)).To(Equal(
+			"Expected\n  ActualLRP ProcessGuid=pg Index=1 InstanceGuid=ig\nnot to be running on a cell with\n  State=RUNNING\n  CellId=cell-a\nbut it has\n  State=RUNNING\n  CellId=cell-a",
+		))
+	})
+
+	It("fails on a nil LRP", func() {
+		var nilLRP *models.ActualLRP
+		_, err := bbsstate.BeRunningOn("cell-a").Match(nilLRP)
+		Expect(err).To(MatchError(ContainSubstring("BeRunningOn expects a *models.ActualLRP")))
+	})
+})
+
+var _ = Describe("BeCompletedTask", func() {
+	It("reports the state and failure of a task", func() {
+		task := &models.Task{TaskGuid: "tg", State: models.Task_Completed, Failed: true, FailureReason: "exit status 2"}
+
+		matcher := bbsstate.BeCompletedTask(false, "")
+		Expect(matcher.Match(task)).To(BeFalse())
+		Expect(matcher.FailureMessage(task)).To(Equal(
+			"Expected\n  Task TaskGuid=tg\nto be a completed task with\n  State=Completed\n  Failed=false\n  FailureReason=containing \"\"\nbut it has\n  State=Completed\n  Failed=true\n  FailureReason=\"exit status 2\"",
+		))
+	})
+
+	It("fails on a nil task", func() {
+		var nilTask *models.Task
+		_, err := bbsstate.BeCompletedTask(true, "").Match(nilTask)
+		Expect(err).To(MatchError(ContainSubstring("BeCompletedTask expects a *models.Task")))
+	})
+})
+
+var _ = Describe("HaveRoutes", func() {
+	var desiredLRP *models.DesiredLRP
+
+	BeforeEach(func() {
+		routes := cfroutes.CFRoutes{{Hostnames: []string{"a.example.com", "b.example.com"}, Port: 8080}}.RoutingInfo()
+		desiredLRP = &models.DesiredLRP{ProcessGuid: "pg", Routes: &routes}
+	})
+
+	It("matches a desired LRP with routes for the hostnames", func() {
+		Expect(desiredLRP).To(bbsstate.HaveRoutes("a.example.com", "b.example.com"))
+		Expect(*desiredLRP.Routes).To(bbsstate.HaveRoutes("b.example.com"))
+	})
+
+	It("reports the routed hostnames", func() {
+		matcher := bbsstate.HaveRoutes("c.example.com")
+		Expect(matcher.Match(desiredLRP)).To(BeFalse())
+		Expect(matcher.FailureMessage(desiredLRP)).To(Equal(
+			"Expected\n  DesiredLRP ProcessGuid=pg\nto have routes with\n  Hostnames=c.example.com\nbut it has\n  Hostnames=a.example.com:8080, b.example.com:8080",
+		))
+	})
+
+	It("reports the routed hostnames when negated", func() {
+		matcher := bbsstate.HaveRoutes("a.example.com")
+		Expect(matcher.Match(desiredLRP)).To(BeTrue())
+		Expect(matcher.NegatedFailureMessage(desiredLRP)).To(Equal(
+			"Expected\n  DesiredLRP ProcessGuid=pg\nnot to have routes with\n  Hostnames=a.example.com\nbut it has\n  Hostnames=a.example.com:8080, b.example.com:8080",
+		))
+	})
+
+	It("does not match a desired LRP without routes", func() {
+		Expect(&models.DesiredLRP{ProcessGuid: "pg"}).NotTo(bbsstate.HaveRoutes("a.example.com"))
+	})
+
+	It("fails on a nil desired LRP or scheduling info", func() {
+		var nilLRP *models.DesiredLRP
+		_, err := bbsstate.HaveRoutes("a.example.com").Match(nilLRP)
+		Expect(err).To(MatchError(ContainSubstring("HaveRoutes expects a *models.DesiredLRP")))
+
+		var nilSchedulingInfo *models.DesiredLRPSchedulingInfo
+		_, err = bbsstate.HaveRoutes("a.example.com").Match(nilSchedulingInfo)
+		Expect(err).To(MatchError(ContainSubstring("HaveRoutes expects a *models.DesiredLRP")))
+	})
+})
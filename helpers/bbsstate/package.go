@@ -0,0 +1 @@
+package bbsstate // import "code.cloudfoundry.org/inigo/helpers/bbsstate"
@@ -0,0 +1,260 @@
+package helpers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/inigo/helpers/bbsdb"
+	"code.cloudfoundry.org/inigo/world"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/rep"
+	"github.com/onsi/ginkgo"
+)
+
+// ClusterState is a snapshot of everything the components know about the
+// cluster. Components that cannot be reached are recorded in Errors rather
+// than failing the snapshot.
+type ClusterState struct {
+	Domains     []string
+	DesiredLRPs []*models.DesiredLRP
+	ActualLRPs  []*models.ActualLRP
+	Tasks       []*models.Task
+	Cells       []*models.CellPresence
+	Locks       []bbsdb.Lock
+	Containers  []ContainerState
+	RepStates   map[string]rep.CellState
+	Routes      json.RawMessage
+	Errors      []string
+}
+
+type ContainerState struct {
+	Handle string
+	Info   garden.ContainerInfo
+}
+
+// SnapshotClusterState collects the state of the BBS, locket, Garden, every
+// registered rep and the router.
+func SnapshotClusterState(logger lager.Logger, componentMaker world.ComponentMaker) ClusterState {
+	logger = logger.Session("snapshot-cluster-state")
+	state := ClusterState{RepStates: map[string]rep.CellState{}}
+
+	recordError := func(what string, err error) {
+		state.Errors = append(state.Errors, fmt.Sprintf("%s: %s", what, err))
+	}
+
+	bbsClient := componentMaker.BBSClient()
+
+	var err error
+	state.Domains, err = bbsClient.Domains(logger)
+	if err != nil {
+		recordError("bbs domains", err)
+	}
+
+	state.DesiredLRPs, err = bbsClient.DesiredLRPs(logger, models.DesiredLRPFilter{})
+	if err != nil {
+		recordError("bbs desired lrps", err)
+	}
+
+	state.ActualLRPs, err = bbsClient.ActualLRPs(logger, models.ActualLRPFilter{})
+	if err != nil {
+		recordError("bbs actual lrps", err)
+	}
+
+	state.Tasks, err = bbsClient.Tasks(logger)
+	if err != nil {
+		recordError("bbs tasks", err)
+	}
+
+	state.Cells, err = bbsClient.Cells(logger)
+	if err != nil {
+		recordError("bbs cells", err)
+	}
+
+	dbDriverName, _ := world.DBInfo()
+	db, err := bbsdb.Open(logger, dbDriverName, componentMaker.SQLConnectionString(), world.DefaultEncryptionConfig())
+	if err != nil {
+		recordError("locket locks", err)
+	} else {
+		state.Locks, err = db.Locks()
+		if err != nil {
+			recordError("locket locks", err)
+		}
+		db.Close()
+	}
+
+	containers, err := componentMaker.GardenClient().Containers(nil)
+	if err != nil {
+		recordError("garden containers", err)
+	}
+	for _, container := range containers {
+		info, err := container.Info()
+		if err != nil {
+			recordError("garden container "+container.Handle(), err)
+		}
+		state.Containers = append(state.Containers, ContainerState{Handle: container.Handle(), Info: info})
+	}
+
+	repClientFactory := componentMaker.RepClientFactory()
+	for _, cell := range state.Cells {
+		client, err := repClientFactory.CreateClient(cell.RepAddress, cell.RepUrl)
+		if err != nil {
+			recordError("rep state "+cell.CellId, err)
+			continue
+		}
+
+		cellState, err := client.State(logger)
+		if err != nil {
+			recordError("rep state "+cell.CellId, err)
+			continue
+		}
+		state.RepStates[cell.CellId] = cellState
+	}
+
+	state.Routes, err = fetchRouteTable(componentMaker.RouterStatusURL() + "/routes")
+	if err != nil {
+		recordError("router routes", err)
+	}
+
+	return state
+}
+
+func fetchRouteTable(url string) (json.RawMessage, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(body), nil
+}
+
+// Write stores each part of the snapshot as a JSON file in dir.
+func (state ClusterState) Write(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	files := map[string]interface{}{
+		"bbs-domains.json":       state.Domains,
+		"bbs-desired-lrps.json":  state.DesiredLRPs,
+		"bbs-actual-lrps.json":   state.ActualLRPs,
+		"bbs-tasks.json":         state.Tasks,
+		"bbs-cells.json":         state.Cells,
+		"locket-locks.json":      state.Locks,
+		"garden-containers.json": state.Containers,
+		"rep-states.json":        state.RepStates,
+		"errors.json":            state.Errors,
+	}
+	if state.Routes != nil {
+		files["router-routes.json"] = state.Routes
+	}
+
+	for name, contents := range files {
+		payload, err := json.MarshalIndent(contents, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		err = ioutil.WriteFile(filepath.Join(dir, name), payload, 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteSummary writes a short human readable overview of the snapshot.
+func (state ClusterState) WriteSummary(w io.Writer) {
+	actualStates := map[string]int{}
+	for _, lrp := range state.ActualLRPs {
+		actualStates[lrp.State]++
+	}
+
+	taskStates := map[string]int{}
+	for _, task := range state.Tasks {
+		taskStates[task.State.String()]++
+	}
+
+	fmt.Fprintf(w, "  domains:       %s\n", strings.Join(state.Domains, ", "))
+	fmt.Fprintf(w, "  desired lrps:  %d\n", len(state.DesiredLRPs))
+	fmt.Fprintf(w, "  actual lrps:   %d %s\n", len(state.ActualLRPs), formatCounts(actualStates))
+	fmt.Fprintf(w, "  tasks:         %d %s\n", len(state.Tasks), formatCounts(taskStates))
+	fmt.Fprintf(w, "  cells:         %d\n", len(state.Cells))
+	fmt.Fprintf(w, "  locks:         %d\n", len(state.Locks))
+	fmt.Fprintf(w, "  containers:    %d\n", len(state.Containers))
+
+	for _, lrp := range state.ActualLRPs {
+		if lrp.State == models.ActualLRPStateRunning {
+			continue
+		}
+		fmt.Fprintf(w, "  actual lrp %s/%d is %s on %q (placement error: %q, crash reason: %q)\n",
+			lrp.ProcessGuid, lrp.Index, lrp.State, lrp.CellId, lrp.PlacementError, lrp.CrashReason)
+	}
+
+	for _, err := range state.Errors {
+		fmt.Fprintf(w, "  error: %s\n", err)
+	}
+}
+
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", key, counts[key])
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// DumpClusterStateOnFailure snapshots the cluster into the artifacts
+// directory if the current spec failed, and summarizes it in the spec's
+// output. Register it in a JustAfterEach so that it runs before the spec's
+// AfterEach blocks stop the components.
+func DumpClusterStateOnFailure(logger lager.Logger, componentMaker world.ComponentMaker) {
+	if !ginkgo.CurrentGinkgoTestDescription().Failed {
+		return
+	}
+
+	dir := filepath.Join(ArtifactsDir(), "cluster-state")
+	state := SnapshotClusterState(logger, componentMaker)
+
+	fmt.Fprintf(ginkgo.GinkgoWriter, "\ncluster state at failure:\n")
+	state.WriteSummary(ginkgo.GinkgoWriter)
+
+	err := state.Write(dir)
+	if err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "failed to write cluster state: %s\n", err)
+		return
+	}
+
+	fmt.Fprintf(ginkgo.GinkgoWriter, "cluster state written to %s\n", dir)
+}
@@ -0,0 +1,330 @@
+package componentlogs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/gomega/gbytes"
+)
+
+// LogLine is a single lager log line written by a component.
+type LogLine struct {
+	Component string                 `json:"component"`
+	Timestamp time.Time              `json:"timestamp"`
+	Source    string                 `json:"source"`
+	Message   string                 `json:"message"`
+	LogLevel  string                 `json:"log_level"`
+	Data      map[string]interface{} `json:"data"`
+	Raw       string                 `json:"-"`
+}
+
+type Lines []LogLine
+
+// Filter selects log lines in a Where query.
+type Filter func(LogLine) bool
+
+const (
+	Stdout = "stdout"
+	Stderr = "stderr"
+)
+
+// StderrProvider is implemented by runners that expose their stderr, such as
+// ginkgomon.Runner.
+type StderrProvider interface {
+	Err() *gbytes.Buffer
+}
+
+type capturedBuffer struct {
+	component string
+	pid       int
+	stream    string
+	buffer    *gbytes.Buffer
+}
+
+// Store collects the output of components so that their lager lines can be
+// queried. Lines that are not lager JSON are ignored by queries but kept in
+// exports.
+type Store struct {
+	lock    sync.Mutex
+	buffers []capturedBuffer
+}
+
+func NewStore() *Store {
+	return &Store{}
+}
+
+// Capture starts collecting the output of a started component, whose
+// process is pid. The ginkgomon runners block in Buffer until their process
+// is spawned, so call it from within the runner's Run once the process is
+// ready.
+func (s *Store) Capture(component string, pid int, provider gbytes.BufferProvider) {
+	s.capture(component, pid, Stdout, provider.Buffer())
+}
+
+// CaptureStderr is like Capture for the stderr of a component.
+func (s *Store) CaptureStderr(component string, pid int, provider StderrProvider) {
+	s.capture(component, pid, Stderr, provider.Err())
+}
+
+func (s *Store) capture(component string, pid int, stream string, buffer *gbytes.Buffer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.buffers = append(s.buffers, capturedBuffer{component: component, pid: pid, stream: stream, buffer: buffer})
+}
+
+// Reset forgets every component that has started so far. Components that
+// are started afterwards are still captured.
+func (s *Store) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.buffers = nil
+}
+
+// Components returns the names of the components captured so far.
+func (s *Store) Components() []string {
+	seen := map[string]bool{}
+	components := []string{}
+	for _, captured := range s.captured() {
+		if !seen[captured.component] {
+			seen[captured.component] = true
+			components = append(components, captured.component)
+		}
+	}
+	sort.Strings(components)
+	return components
+}
+
+// Output is the raw output of one started component.
+type Output struct {
+	Component string
+	Pid       int
+	Stream    string
+	Contents  []byte
+}
+
+// Outputs returns the raw output of every started component, in the order
+// they started.
+func (s *Store) Outputs() []Output {
+	captured := s.captured()
+	outputs := make([]Output, len(captured))
+	for i, c := range captured {
+		outputs[i] = Output{Component: c.component, Pid: c.pid, Stream: c.stream, Contents: c.buffer.Contents()}
+	}
+	return outputs
+}
+
+// Logs returns the lager lines of the named components, or of every
+// component when none is named, ordered by timestamp.
+func (s *Store) Logs(components ...string) Lines {
+	wanted := map[string]bool{}
+	for _, component := range components {
+		wanted[component] = true
+	}
+
+	lines := Lines{}
+	for _, captured := range s.captured() {
+		if len(wanted) > 0 && !wanted[captured.component] {
+			continue
+		}
+		lines = append(lines, parseLines(captured.component, captured.buffer.Contents())...)
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Timestamp.Before(lines[j].Timestamp)
+	})
+	return lines
+}
+
+// Export writes the raw stdout of every component to <component>.log, its
+// stderr to <component>.stderr.log and all lager lines, merged in timestamp
+// order, to components.log in dir.
+func (s *Store) Export(dir string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, captured := range s.captured() {
+		name := captured.component + ".log"
+		if captured.stream == Stderr {
+			name = captured.component + ".stderr.log"
+		}
+
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(captured.buffer.Contents())
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(filepath.Join(dir, "components.log"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, line := range s.Logs() {
+		_, err := fmt.Fprintf(f, "[%s] %s\n", line.Component, line.Raw)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) captured() []capturedBuffer {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	captured := make([]capturedBuffer, len(s.buffers))
+	copy(captured, s.buffers)
+	return captured
+}
+
+// Where returns the lines matching every filter.
+func (l Lines) Where(filters ...Filter) Lines {
+	matching := Lines{}
+LINES:
+	for _, line := range l {
+		for _, filter := range filters {
+			if !filter(line) {
+				continue LINES
+			}
+		}
+		matching = append(matching, line)
+	}
+	return matching
+}
+
+// Messages returns the message of every line, for use with matchers such as
+// ContainElement.
+func (l Lines) Messages() []string {
+	messages := make([]string, len(l))
+	for i, line := range l {
+		messages[i] = line.Message
+	}
+	return messages
+}
+
+func Component(component string) Filter {
+	return func(line LogLine) bool {
+		return line.Component == component
+	}
+}
+
+func Message(message string) Filter {
+	return func(line LogLine) bool {
+		return line.Message == message
+	}
+}
+
+func MessageContaining(substring string) Filter {
+	return func(line LogLine) bool {
+		return strings.Contains(line.Message, substring)
+	}
+}
+
+func LogLevel(level string) Filter {
+	return func(line LogLine) bool {
+		return line.LogLevel == level
+	}
+}
+
+// Data matches lines whose data has value at key. Nested keys are separated
+// by dots, and values are compared by their printed form so that numbers
+// decoded from JSON compare equal to integers.
+func Data(key string, value interface{}) Filter {
+	path := strings.Split(key, ".")
+	return func(line LogLine) bool {
+		var current interface{} = line.Data
+		for _, k := range path {
+			m, ok := current.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			current, ok = m[k]
+			if !ok {
+				return false
+			}
+		}
+		return fmt.Sprint(current) == fmt.Sprint(value)
+	}
+}
+
+func Since(t time.Time) Filter {
+	return func(line LogLine) bool {
+		return !line.Timestamp.Before(t)
+	}
+}
+
+type lagerLine struct {
+	Timestamp string                 `json:"timestamp"`
+	Source    string                 `json:"source"`
+	Message   string                 `json:"message"`
+	LogLevel  *int                   `json:"log_level"`
+	Level     string                 `json:"level"`
+	Data      map[string]interface{} `json:"data"`
+}
+
+var logLevels = []string{"debug", "info", "error", "fatal"}
+
+func parseLines(component string, contents []byte) Lines {
+	lines := Lines{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		raw := scanner.Bytes()
+		if len(raw) == 0 || raw[0] != '{' {
+			continue
+		}
+
+		var parsed lagerLine
+		if json.Unmarshal(raw, &parsed) != nil || parsed.Message == "" {
+			continue
+		}
+
+		level := parsed.Level
+		if parsed.LogLevel != nil && *parsed.LogLevel >= 0 && *parsed.LogLevel < len(logLevels) {
+			level = logLevels[*parsed.LogLevel]
+		}
+
+		lines = append(lines, LogLine{
+			Component: component,
+			Timestamp: parseTimestamp(parsed.Timestamp),
+			Source:    parsed.Source,
+			Message:   parsed.Message,
+			LogLevel:  level,
+			Data:      parsed.Data,
+			Raw:       string(raw),
+		})
+	}
+	return lines
+}
+
+// parseTimestamp understands both the unix epoch and RFC3339 formats lager
+// can be configured to write.
+func parseTimestamp(timestamp string) time.Time {
+	if seconds, err := strconv.ParseFloat(timestamp, 64); err == nil {
+		whole := int64(seconds)
+		return time.Unix(whole, int64((seconds-float64(whole))*1e9))
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		return t
+	}
+
+	return time.Time{}
+}
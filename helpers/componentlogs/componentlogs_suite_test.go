@@ -0,0 +1,13 @@
+package componentlogs_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestComponentlogs(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Componentlogs Suite")
+}
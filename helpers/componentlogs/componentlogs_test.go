@@ -0,0 +1,130 @@
+package componentlogs_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/componentlogs"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+type bufferProvider struct {
+	buffer *gbytes.Buffer
+}
+
+func (p bufferProvider) Buffer() *gbytes.Buffer {
+	return p.buffer
+}
+
+func (p bufferProvider) Err() *gbytes.Buffer {
+	return p.buffer
+}
+
+var _ = Describe("Store", func() {
+	var (
+		store  *componentlogs.Store
+		repOut *gbytes.Buffer
+		bbsOut *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		store = componentlogs.NewStore()
+		repOut = gbytes.NewBuffer()
+		bbsOut = gbytes.NewBuffer()
+
+		repOut.Write([]byte(`{"timestamp":"1500000002.000000000","source":"rep","message":"rep.started","log_level":1,"data":{"cell-id":"cell-0"}}` + "\n"))
+		repOut.Write([]byte("not a lager line\n"))
+		bbsOut.Write([]byte(`{"timestamp":"2017-07-14T02:40:01.000000000Z","level":"error","source":"bbs","message":"bbs.failed","data":{"error":"boom","lrp":{"index":3}}}` + "\n"))
+
+		store.Capture("rep", 100, bufferProvider{repOut})
+		store.Capture("bbs", 200, bufferProvider{bbsOut})
+		Expect(store.Components()).To(ConsistOf("rep", "bbs"))
+	})
+
+	Describe("Logs", func() {
+		It("returns the lager lines of every component in timestamp order", func() {
+			lines := store.Logs()
+			Expect(lines.Messages()).To(Equal([]string{"bbs.failed", "rep.started"}))
+			Expect(lines[0].Component).To(Equal("bbs"))
+			Expect(lines[0].LogLevel).To(Equal("error"))
+			Expect(lines[0].Timestamp).To(Equal(time.Unix(1500000001, 0).UTC()))
+			Expect(lines[1].LogLevel).To(Equal("info"))
+			Expect(lines[1].Timestamp.Equal(time.Unix(1500000002, 0))).To(BeTrue())
+		})
+
+		It("returns only the lines of the named components", func() {
+			Expect(store.Logs("rep").Messages()).To(Equal([]string{"rep.started"}))
+		})
+
+		It("includes lines written after the component was captured", func() {
+			repOut.Write([]byte(`{"timestamp":"1500000003.000000000","source":"rep","message":"rep.exited","log_level":1,"data":{}}` + "\n"))
+			Expect(store.Logs("rep").Messages()).To(Equal([]string{"rep.started", "rep.exited"}))
+		})
+	})
+
+	Describe("Where", func() {
+		It("filters by message and data", func() {
+			lines := store.Logs()
+			Expect(lines.Where(componentlogs.Message("rep.started"))).To(HaveLen(1))
+			Expect(lines.Where(componentlogs.MessageContaining("failed"), componentlogs.LogLevel("error"))).To(HaveLen(1))
+			Expect(lines.Where(componentlogs.Data("cell-id", "cell-0"))).To(HaveLen(1))
+			Expect(lines.Where(componentlogs.Data("lrp.index", 3))).To(HaveLen(1))
+			Expect(lines.Where(componentlogs.Data("lrp.index", 4))).To(BeEmpty())
+			Expect(lines.Where(componentlogs.Component("bbs"), componentlogs.Since(time.Unix(1500000002, 0)))).To(BeEmpty())
+		})
+	})
+
+	Describe("CaptureStderr", func() {
+		It("keeps stderr apart from stdout", func() {
+			repErr := gbytes.NewBuffer()
+			repErr.Write([]byte("SIGQUIT: quit\n"))
+			store.CaptureStderr("rep", 100, bufferProvider{repErr})
+
+			outputs := store.Outputs()
+			Expect(outputs).To(HaveLen(3))
+			Expect(outputs[2].Component).To(Equal("rep"))
+			Expect(outputs[2].Pid).To(Equal(100))
+			Expect(outputs[2].Stream).To(Equal(componentlogs.Stderr))
+			Expect(string(outputs[2].Contents)).To(Equal("SIGQUIT: quit\n"))
+			Expect(store.Logs("rep").Messages()).To(Equal([]string{"rep.started"}))
+		})
+	})
+
+	Describe("Reset", func() {
+		It("forgets the components captured so far", func() {
+			store.Reset()
+			Expect(store.Logs()).To(BeEmpty())
+			Expect(store.Components()).To(BeEmpty())
+		})
+	})
+
+	Describe("Export", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "componentlogs")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("writes the raw output of each component and the merged lager lines", func() {
+			Expect(store.Export(dir)).To(Succeed())
+
+			repLog, err := ioutil.ReadFile(filepath.Join(dir, "rep.log"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(repLog)).To(ContainSubstring("not a lager line"))
+
+			merged, err := ioutil.ReadFile(filepath.Join(dir, "components.log"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(merged)).To(MatchRegexp(`(?s)^\[bbs\] .*bbs\.failed.*\n\[rep\] .*rep\.started`))
+		})
+	})
+})
@@ -0,0 +1 @@
+package componentlogs // import "code.cloudfoundry.org/inigo/helpers/componentlogs"
@@ -0,0 +1,13 @@
+package componentstats_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestComponentstats(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Componentstats Suite")
+}
@@ -0,0 +1 @@
+package componentstats // import "code.cloudfoundry.org/inigo/helpers/componentstats"
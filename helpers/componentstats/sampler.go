@@ -0,0 +1,437 @@
+package componentstats
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/procstat"
+	"github.com/onsi/gomega"
+)
+
+const MB = 1024 * 1024
+
+// goroutineCountTimeout bounds how long a sample waits for the goroutine
+// count of a component, whatever the sampling interval.
+const goroutineCountTimeout = 2 * time.Second
+
+// Sample is the resource usage of one component process at one point in
+// time. Goroutines is -1 for components without a debug server.
+type Sample struct {
+	Time       time.Time
+	Component  string
+	Pid        int
+	CPUPercent float64
+	RSSBytes   uint64
+	OpenFDs    int
+	Goroutines int
+}
+
+// Summary aggregates the samples of one component.
+type Summary struct {
+	Component      string
+	Samples        int
+	PeakRSSBytes   uint64
+	RSSGrowthBytes int64
+	MeanCPUPercent float64
+	PeakCPUPercent float64
+	PeakOpenFDs    int
+	PeakGoroutines int
+}
+
+type process struct {
+	component    string
+	pid          int
+	debugAddress string
+	stopping     bool
+
+	lastCPUSeconds float64
+	lastSampledAt  time.Time
+}
+
+// Sampler periodically records the CPU, memory, file descriptor and
+// goroutine usage of the component processes it tracks. Usage is read from
+// /proc, so samples are only taken on Linux.
+type Sampler struct {
+	interval   time.Duration
+	httpClient *http.Client
+	sampling   sync.Mutex
+
+	lock      sync.Mutex
+	processes map[int]*process
+	samples   []Sample
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewSampler(interval time.Duration) *Sampler {
+	return &Sampler{
+		interval:   interval,
+		httpClient: &http.Client{Timeout: goroutineCountTimeout},
+		processes:  map[int]*process{},
+	}
+}
+
+// TrackPid starts sampling the process of a started component. The debug
+// address is used to count goroutines and may be empty.
+func (s *Sampler) TrackPid(component string, pid int, debugAddress string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.processes[pid] = &process{component: component, pid: pid, debugAddress: debugAddress}
+}
+
+// MarkStopping records that the process of a component has been asked to
+// stop.
+func (s *Sampler) MarkStopping(pid int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if p, ok := s.processes[pid]; ok {
+		p.stopping = true
+	}
+}
+
+// Process is a component process tracked by the sampler.
+type Process struct {
+	Component    string
+	Pid          int
+	DebugAddress string
+}
+
+// Processes returns the processes that were running when they were last
+// sampled.
+func (s *Sampler) Processes() []Process {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	processes := make([]Process, 0, len(s.processes))
+	for _, p := range s.processes {
+		processes = append(processes, Process{Component: p.component, Pid: p.pid, DebugAddress: p.debugAddress})
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].Pid < processes[j].Pid })
+	return processes
+}
+
+// Stopping returns the processes that have been asked to stop but are still
+// running.
+func (s *Sampler) Stopping() []Process {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	processes := []Process{}
+	for _, p := range s.processes {
+		if !p.stopping {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join("/proc", strconv.Itoa(p.pid))); err != nil {
+			continue
+		}
+		processes = append(processes, Process{Component: p.component, Pid: p.pid, DebugAddress: p.debugAddress})
+	}
+	sort.Slice(processes, func(i, j int) bool { return processes[i].Pid < processes[j].Pid })
+	return processes
+}
+
+// Start samples every tracked process at the sampler's interval until Stop
+// is called.
+func (s *Sampler) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.SampleNow()
+			case <-stop:
+				return
+			}
+		}
+	}(s.stop, s.done)
+}
+
+func (s *Sampler) Stop() {
+	s.lock.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.lock.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// SampleNow takes a sample of every tracked process. Processes that have
+// exited are no longer tracked.
+func (s *Sampler) SampleNow() {
+	s.sampling.Lock()
+	defer s.sampling.Unlock()
+
+	s.lock.Lock()
+	processes := make([]*process, 0, len(s.processes))
+	for _, p := range s.processes {
+		processes = append(processes, p)
+	}
+	s.lock.Unlock()
+
+	samples := []Sample{}
+	sampled := []*process{}
+	exited := []int{}
+	for _, p := range processes {
+		sample, err := s.sample(p)
+		if err != nil {
+			exited = append(exited, p.pid)
+			continue
+		}
+		samples = append(samples, sample)
+		sampled = append(sampled, p)
+	}
+
+	// the goroutine counts are fetched concurrently, so that a slow debug
+	// server only delays its own count
+	wg := sync.WaitGroup{}
+	for i, p := range sampled {
+		wg.Add(1)
+		go func(sample *Sample, debugAddress string) {
+			defer wg.Done()
+			sample.Goroutines = s.countGoroutines(debugAddress)
+		}(&samples[i], p.debugAddress)
+	}
+	wg.Wait()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.samples = append(s.samples, samples...)
+	for _, pid := range exited {
+		delete(s.processes, pid)
+	}
+}
+
+// Reset discards the samples taken so far. The tracked processes are kept.
+func (s *Sampler) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.samples = nil
+}
+
+func (s *Sampler) Samples() []Sample {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	samples := make([]Sample, len(s.samples))
+	copy(samples, s.samples)
+	return samples
+}
+
+// Summaries aggregates the samples taken since the last Reset by component.
+func (s *Sampler) Summaries() []Summary {
+	byComponent := map[string]*Summary{}
+	firstRSS := map[string]uint64{}
+	components := []string{}
+
+	for _, sample := range s.Samples() {
+		summary, ok := byComponent[sample.Component]
+		if !ok {
+			summary = &Summary{Component: sample.Component, PeakGoroutines: -1}
+			byComponent[sample.Component] = summary
+			firstRSS[sample.Component] = sample.RSSBytes
+			components = append(components, sample.Component)
+		}
+
+		summary.Samples++
+		summary.MeanCPUPercent += sample.CPUPercent
+		summary.RSSGrowthBytes = int64(sample.RSSBytes) - int64(firstRSS[sample.Component])
+		if sample.RSSBytes > summary.PeakRSSBytes {
+			summary.PeakRSSBytes = sample.RSSBytes
+		}
+		if sample.CPUPercent > summary.PeakCPUPercent {
+			summary.PeakCPUPercent = sample.CPUPercent
+		}
+		if sample.OpenFDs > summary.PeakOpenFDs {
+			summary.PeakOpenFDs = sample.OpenFDs
+		}
+		if sample.Goroutines > summary.PeakGoroutines {
+			summary.PeakGoroutines = sample.Goroutines
+		}
+	}
+
+	sort.Strings(components)
+	summaries := make([]Summary, len(components))
+	for i, component := range components {
+		summary := byComponent[component]
+		summary.MeanCPUPercent /= float64(summary.Samples)
+		summaries[i] = *summary
+	}
+	return summaries
+}
+
+// PeakRSS samples the tracked processes and returns the highest resident
+// set size of the named component since the last Reset.
+func (s *Sampler) PeakRSS(component string) uint64 {
+	s.SampleNow()
+
+	for _, summary := range s.Summaries() {
+		if summary.Component == component {
+			return summary.PeakRSSBytes
+		}
+	}
+	return 0
+}
+
+// ComponentRSS makes an assertion about the peak resident set size, in
+// bytes, of the named component during the current spec, e.g.
+//
+//	sampler.ComponentRSS("bbs").Should(BeNumerically("<", 200*componentstats.MB))
+func (s *Sampler) ComponentRSS(component string) gomega.Assertion {
+	return gomega.ExpectWithOffset(1, s.PeakRSS(component))
+}
+
+func (s *Sampler) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "%-20s %8s %12s %12s %8s %8s %8s %10s\n", "component", "samples", "peak rss", "rss growth", "cpu avg", "cpu max", "fds", "goroutines")
+	for _, summary := range s.Summaries() {
+		fmt.Fprintf(w, "%-20s %8d %10.1fMB %10.1fMB %7.1f%% %7.1f%% %8d %10d\n",
+			summary.Component,
+			summary.Samples,
+			float64(summary.PeakRSSBytes)/MB,
+			float64(summary.RSSGrowthBytes)/MB,
+			summary.MeanCPUPercent,
+			summary.PeakCPUPercent,
+			summary.PeakOpenFDs,
+			summary.PeakGoroutines,
+		)
+	}
+}
+
+func (s *Sampler) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	err := writer.Write([]string{"time", "component", "pid", "cpu_percent", "rss_bytes", "open_fds", "goroutines"})
+	if err != nil {
+		return err
+	}
+
+	for _, sample := range s.Samples() {
+		err := writer.Write([]string{
+			sample.Time.Format(time.RFC3339Nano),
+			sample.Component,
+			strconv.Itoa(sample.Pid),
+			strconv.FormatFloat(sample.CPUPercent, 'f', 2, 64),
+			strconv.FormatUint(sample.RSSBytes, 10),
+			strconv.Itoa(sample.OpenFDs),
+			strconv.Itoa(sample.Goroutines),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (s *Sampler) sample(p *process) (Sample, error) {
+	now := time.Now()
+	procDir := filepath.Join("/proc", strconv.Itoa(p.pid))
+
+	stat, err := procstat.Read(p.pid)
+	if err != nil {
+		return Sample{}, err
+	}
+	cpuSeconds := stat.CPUSeconds()
+
+	rss, err := readRSS(filepath.Join(procDir, "status"))
+	if err != nil {
+		return Sample{}, err
+	}
+
+	fds, err := ioutil.ReadDir(filepath.Join(procDir, "fd"))
+	if err != nil {
+		return Sample{}, err
+	}
+
+	sample := Sample{
+		Time:      now,
+		Component: p.component,
+		Pid:       p.pid,
+		RSSBytes:  rss,
+		OpenFDs:   len(fds),
+	}
+
+	if !p.lastSampledAt.IsZero() {
+		elapsed := now.Sub(p.lastSampledAt).Seconds()
+		if elapsed > 0 {
+			sample.CPUPercent = 100 * (cpuSeconds - p.lastCPUSeconds) / elapsed
+		}
+	}
+	p.lastCPUSeconds = cpuSeconds
+	p.lastSampledAt = now
+
+	return sample, nil
+}
+
+func readRSS(path string) (uint64, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmRSS:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb * 1024, nil
+		}
+	}
+
+	// kernel threads and zombies have no VmRSS
+	return 0, nil
+}
+
+// countGoroutines reads the goroutine count from the header of the text
+// goroutine profile, "goroutine profile: total N".
+func (s *Sampler) countGoroutines(debugAddress string) int {
+	if debugAddress == "" {
+		return -1
+	}
+
+	resp, err := s.httpClient.Get(fmt.Sprintf("http://%s/debug/pprof/goroutine?debug=1", debugAddress))
+	if err != nil {
+		return -1
+	}
+	defer resp.Body.Close()
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		return -1
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "goroutine profile: total")))
+	if err != nil {
+		return -1
+	}
+	return count
+}
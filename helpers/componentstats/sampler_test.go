@@ -0,0 +1,167 @@
+package componentstats_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/componentstats"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sampler", func() {
+	var sampler *componentstats.Sampler
+
+	BeforeEach(func() {
+		if runtime.GOOS != "linux" {
+			Skip("sampling reads /proc")
+		}
+
+		sampler = componentstats.NewSampler(10 * time.Millisecond)
+	})
+
+	AfterEach(func() {
+		sampler.Stop()
+	})
+
+	Context("when the component has a debug server", func() {
+		var debugServer *httptest.Server
+
+		BeforeEach(func() {
+			debugServer = httptest.NewServer(pprof.Handler("goroutine"))
+			sampler.TrackPid("self", os.Getpid(), strings.TrimPrefix(debugServer.URL, "http://"))
+		})
+
+		AfterEach(func() {
+			debugServer.Close()
+		})
+
+		It("records the usage of the process", func() {
+			sampler.SampleNow()
+			sampler.SampleNow()
+
+			samples := sampler.Samples()
+			Expect(samples).To(HaveLen(2))
+			Expect(samples[1].Component).To(Equal("self"))
+			Expect(samples[1].Pid).To(Equal(os.Getpid()))
+			Expect(samples[1].RSSBytes).To(BeNumerically(">", 0))
+			Expect(samples[1].OpenFDs).To(BeNumerically(">", 0))
+			Expect(samples[1].Goroutines).To(BeNumerically(">", 0))
+		})
+
+		It("samples periodically once started", func() {
+			sampler.Start()
+			Eventually(func() int { return len(sampler.Samples()) }).Should(BeNumerically(">=", 3))
+		})
+
+		It("summarizes the samples by component", func() {
+			sampler.SampleNow()
+
+			summaries := sampler.Summaries()
+			Expect(summaries).To(HaveLen(1))
+			Expect(summaries[0].Component).To(Equal("self"))
+			Expect(summaries[0].Samples).To(Equal(1))
+			Expect(summaries[0].PeakRSSBytes).To(BeNumerically(">", 0))
+
+			summary := &bytes.Buffer{}
+			sampler.WriteSummary(summary)
+			Expect(summary.String()).To(ContainSubstring("self"))
+
+			csv := &bytes.Buffer{}
+			Expect(sampler.WriteCSV(csv)).To(Succeed())
+			Expect(strings.Split(strings.TrimSpace(csv.String()), "\n")).To(HaveLen(2))
+		})
+
+		It("asserts on the peak RSS of a component", func() {
+			sampler.ComponentRSS("self").Should(BeNumerically("<", 10*1024*componentstats.MB))
+			sampler.ComponentRSS("self").Should(BeNumerically(">", 0))
+		})
+
+		It("forgets the samples on Reset", func() {
+			sampler.SampleNow()
+			sampler.Reset()
+			Expect(sampler.Samples()).To(BeEmpty())
+		})
+	})
+
+	Context("when the process has exited", func() {
+		It("stops tracking it", func() {
+			cmd := exec.Command("true")
+			Expect(cmd.Run()).To(Succeed())
+
+			sampler.TrackPid("exited", cmd.Process.Pid, "")
+			sampler.SampleNow()
+			Expect(sampler.Samples()).To(BeEmpty())
+		})
+	})
+
+	Describe("Stopping", func() {
+		It("returns the processes asked to stop that are still running", func() {
+			cmd := exec.Command("true")
+			Expect(cmd.Run()).To(Succeed())
+
+			sampler.TrackPid("self", os.Getpid(), "")
+			sampler.TrackPid("parent", os.Getppid(), "")
+			sampler.TrackPid("exited", cmd.Process.Pid, "")
+			sampler.MarkStopping(os.Getpid())
+			sampler.MarkStopping(cmd.Process.Pid)
+
+			Expect(sampler.Stopping()).To(Equal([]componentstats.Process{
+				{Component: "self", Pid: os.Getpid()},
+			}))
+		})
+	})
+
+	Context("when the debug servers are slow", func() {
+		var slowServers []*httptest.Server
+
+		BeforeEach(func() {
+			slowServers = nil
+			for _, pid := range []int{os.Getpid(), os.Getppid()} {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					time.Sleep(500 * time.Millisecond)
+					w.Write([]byte("goroutine profile: total 7\n"))
+				}))
+				slowServers = append(slowServers, server)
+				sampler.TrackPid("slow", pid, strings.TrimPrefix(server.URL, "http://"))
+			}
+		})
+
+		AfterEach(func() {
+			for _, server := range slowServers {
+				server.Close()
+			}
+		})
+
+		It("waits for them concurrently", func() {
+			start := time.Now()
+			sampler.SampleNow()
+			Expect(time.Since(start)).To(BeNumerically("<", 900*time.Millisecond))
+
+			samples := sampler.Samples()
+			Expect(samples).To(HaveLen(2))
+			Expect(samples[0].Goroutines).To(Equal(7))
+			Expect(samples[1].Goroutines).To(Equal(7))
+		})
+
+		It("does not time out with a short sampling interval", func() {
+			sampler.SampleNow()
+			Expect(sampler.Samples()[0].Goroutines).To(Equal(7))
+		})
+	})
+
+	Context("when the component has no debug server", func() {
+		It("reports the goroutine count as unknown", func() {
+			sampler.TrackPid("self", os.Getpid(), "")
+			sampler.SampleNow()
+			Expect(sampler.Samples()[0].Goroutines).To(Equal(-1))
+		})
+	})
+})
@@ -0,0 +1,119 @@
+package fakecf
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// App is an app as far as the ssh access check is concerned.
+type App struct {
+	// ProcessGuid is the guid of the app's LRP.
+	ProcessGuid string
+	SSHEnabled  bool
+	// Developers are the users allowed to ssh to the app.
+	Developers []string
+}
+
+// CloudController is a stand-in for the internal Cloud Controller endpoint
+// the ssh proxy asks whether a user may ssh to an app, and which LRP the
+// app is. It checks access tokens with uaa.
+type CloudController struct {
+	address string
+	uaa     *UAA
+
+	lock sync.Mutex
+	apps map[string]App
+}
+
+var sshAccessPath = regexp.MustCompile(`^/internal/apps/([^/]+)/ssh_access/(\d+)$`)
+
+// NewCloudController creates a Cloud Controller listening without TLS on
+// address.
+func NewCloudController(address string, uaa *UAA) *CloudController {
+	return &CloudController{
+		address: address,
+		uaa:     uaa,
+		apps:    map[string]App{},
+	}
+}
+
+// URL is the API URL of the Cloud Controller.
+func (cc *CloudController) URL() string {
+	return "http://" + cc.address
+}
+
+func (cc *CloudController) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	return serve(cc.address, nil, cc, signals, ready)
+}
+
+// SetApp adds or replaces the app with appGuid.
+func (cc *CloudController) SetApp(appGuid string, app App) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	cc.apps[appGuid] = app
+}
+
+// SetSSHEnabled enables or disables ssh for the app with appGuid.
+func (cc *CloudController) SetSSHEnabled(appGuid string, enabled bool) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+
+	app := cc.apps[appGuid]
+	app.SSHEnabled = enabled
+	cc.apps[appGuid] = app
+}
+
+// RemoveApp deletes the app with appGuid.
+func (cc *CloudController) RemoveApp(appGuid string) {
+	cc.lock.Lock()
+	defer cc.lock.Unlock()
+	delete(cc.apps, appGuid)
+}
+
+func (cc *CloudController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	match := sshAccessPath.FindStringSubmatch(r.URL.Path)
+	if r.Method != "GET" || match == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, ok := cc.uaa.User(bearerToken(r))
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, ccError(1000, "CF-InvalidAuthToken", "Invalid Auth Token"))
+		return
+	}
+
+	cc.lock.Lock()
+	app, ok := cc.apps[match[1]]
+	cc.lock.Unlock()
+
+	switch {
+	case !ok:
+		writeJSON(w, http.StatusNotFound, ccError(100004, "CF-AppNotFound", "The app could not be found: "+match[1]))
+	case !contains(app.Developers, user):
+		writeJSON(w, http.StatusForbidden, ccError(10003, "CF-NotAuthorized", "You are not authorized to perform the requested action"))
+	case !app.SSHEnabled:
+		writeJSON(w, http.StatusBadRequest, ccError(1002, "CF-InvalidRequest", "SSH is disabled for the app"))
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"process_guid": app.ProcessGuid})
+	}
+}
+
+func ccError(code int, errorCode, description string) map[string]interface{} {
+	return map[string]interface{}{
+		"code":        code,
+		"error_code":  errorCode,
+		"description": description,
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package fakecf_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/inigo/helpers/fakecf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloudController", func() {
+	var (
+		uaa *fakecf.UAA
+		cc  *fakecf.CloudController
+	)
+
+	BeforeEach(func() {
+		certs := filepath.Join("..", "..", "fixtures", "certs", "metron")
+
+		var err error
+		uaa, err = fakecf.NewUAA(
+			"127.0.0.1:0",
+			filepath.Join(certs, "metron.crt"),
+			filepath.Join(certs, "metron.key"),
+			"ssh-proxy",
+			"ssh-proxy-secret",
+		)
+		Expect(err).NotTo(HaveOccurred())
+
+		cc = fakecf.NewCloudController("127.0.0.1:0", uaa)
+		cc.SetApp("app-guid", fakecf.App{
+			ProcessGuid: "process-guid",
+			SSHEnabled:  true,
+			Developers:  []string{"alice"},
+		})
+	})
+
+	tokenFor := func(user string) string {
+		form := url.Values{"grant_type": {"authorization_code"}, "code": {uaa.NewCode(user)}}
+		request := httptest.NewRequest("POST", uaa.TokenURL(), strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth(uaa.ClientID(), uaa.ClientSecret())
+		recorder := httptest.NewRecorder()
+		uaa.ServeHTTP(recorder, request)
+
+		var body struct {
+			AccessToken string `json:"access_token"`
+		}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+		return body.AccessToken
+	}
+
+	sshAccess := func(appGuid, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", cc.URL()+"/internal/apps/"+appGuid+"/ssh_access/0", nil)
+		if token != "" {
+			request.Header.Set("Authorization", "bearer "+token)
+		}
+		recorder := httptest.NewRecorder()
+		cc.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	It("returns the process guid of apps the user may ssh to", func() {
+		response := sshAccess("app-guid", tokenFor("alice"))
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(response.Body.String()).To(MatchJSON(`{"process_guid":"process-guid"}`))
+	})
+
+	It("rejects requests without a valid token", func() {
+		Expect(sshAccess("app-guid", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(sshAccess("app-guid", "bogus").Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("rejects expired tokens", func() {
+		token := tokenFor("alice")
+		uaa.ExpireTokens()
+		Expect(sshAccess("app-guid", token).Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("returns not found for unknown apps", func() {
+		Expect(sshAccess("unknown-guid", tokenFor("alice")).Code).To(Equal(http.StatusNotFound))
+
+		cc.RemoveApp("app-guid")
+		Expect(sshAccess("app-guid", tokenFor("alice")).Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("forbids users that are not developers of the app", func() {
+		Expect(sshAccess("app-guid", tokenFor("mallory")).Code).To(Equal(http.StatusForbidden))
+	})
+
+	It("rejects apps with ssh disabled", func() {
+		cc.SetSSHEnabled("app-guid", false)
+		response := sshAccess("app-guid", tokenFor("alice"))
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("CF-InvalidRequest"))
+	})
+})
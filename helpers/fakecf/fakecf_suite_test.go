@@ -0,0 +1,13 @@
+package fakecf_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFakecf(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fakecf Suite")
+}
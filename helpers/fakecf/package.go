@@ -0,0 +1 @@
+package fakecf // import "code.cloudfoundry.org/inigo/helpers/fakecf"
@@ -0,0 +1,46 @@
+package fakecf
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"os"
+)
+
+// serve runs handler on address until signalled, with TLS when tlsConfig is
+// set.
+func serve(address string, tlsConfig *tls.Config, handler http.Handler, signals <-chan os.Signal, ready chan<- struct{}) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	server := &http.Server{Handler: handler}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	close(ready)
+
+	select {
+	case <-signals:
+		return server.Close()
+	case err := <-errCh:
+		return err
+	}
+}
+
+func randomToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,170 @@
+package fakecf
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UAA is a stand-in for the UAA endpoint the ssh proxy exchanges the
+// one-time codes users log in with for access tokens.
+type UAA struct {
+	address      string
+	tlsConfig    *tls.Config
+	clientID     string
+	clientSecret string
+
+	lock     sync.Mutex
+	codes    map[string]string
+	tokens   map[string]token
+	tokenTTL time.Duration
+}
+
+type token struct {
+	user      string
+	expiresAt time.Time
+}
+
+// NewUAA creates a UAA listening with TLS on address that only lets the
+// client with clientID and clientSecret, i.e. the ssh proxy, exchange codes.
+func NewUAA(address, serverCert, serverKey, clientID, clientSecret string) (*UAA, error) {
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UAA{
+		address:      address,
+		tlsConfig:    &tls.Config{Certificates: []tls.Certificate{cert}},
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		codes:        map[string]string{},
+		tokens:       map[string]token{},
+		tokenTTL:     time.Hour,
+	}, nil
+}
+
+// TokenURL is the URL codes are exchanged at.
+func (u *UAA) TokenURL() string {
+	return "https://" + u.address + "/oauth/token"
+}
+
+func (u *UAA) ClientID() string {
+	return u.clientID
+}
+
+func (u *UAA) ClientSecret() string {
+	return u.clientSecret
+}
+
+func (u *UAA) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	return serve(u.address, u.tlsConfig, u, signals, ready)
+}
+
+// NewCode returns a one-time code for user, as `cf ssh-code` does. Use it as
+// the password of a CF ssh user.
+func (u *UAA) NewCode(user string) string {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	code := randomToken()
+	u.codes[code] = user
+	return code
+}
+
+// SetTokenTTL changes how long the access tokens issued from now on are
+// valid. A TTL of 0 or less issues tokens that have already expired.
+func (u *UAA) SetTokenTTL(ttl time.Duration) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.tokenTTL = ttl
+}
+
+// ExpireTokens expires every access token issued so far.
+func (u *UAA) ExpireTokens() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	for accessToken, t := range u.tokens {
+		t.expiresAt = time.Time{}
+		u.tokens[accessToken] = t
+	}
+}
+
+// User returns the user accessToken was issued to, if it is valid and has
+// not expired.
+func (u *UAA) User(accessToken string) (string, bool) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+
+	t, ok := u.tokens[accessToken]
+	if !ok || !time.Now().Before(t.expiresAt) {
+		return "", false
+	}
+	return t.user, true
+}
+
+func (u *UAA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.URL.Path != "/oauth/token" {
+		http.NotFound(w, r)
+		return
+	}
+
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok || clientID != u.clientID || clientSecret != u.clientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	u.lock.Lock()
+	code := r.PostForm.Get("code")
+	user, ok := u.codes[code]
+	delete(u.codes, code)
+	var accessToken string
+	if ok {
+		accessToken = randomToken()
+		u.tokens[accessToken] = token{user: user, expiresAt: time.Now().Add(u.tokenTTL)}
+	}
+	ttl := u.tokenTTL
+	u.lock.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "bearer",
+		"expires_in":   int(ttl.Seconds()),
+	})
+}
+
+// bearerToken returns the access token of an `Authorization: bearer`
+// header.
+func bearerToken(r *http.Request) string {
+	parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return ""
+	}
+	return parts[1]
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,108 @@
+package fakecf_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/fakecf"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("UAA", func() {
+	var uaa *fakecf.UAA
+
+	BeforeEach(func() {
+		certs := filepath.Join("..", "..", "fixtures", "certs", "metron")
+
+		var err error
+		uaa, err = fakecf.NewUAA(
+			"127.0.0.1:0",
+			filepath.Join(certs, "metron.crt"),
+			filepath.Join(certs, "metron.key"),
+			"ssh-proxy",
+			"ssh-proxy-secret",
+		)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	exchange := func(code, clientID, clientSecret string) *httptest.ResponseRecorder {
+		form := url.Values{"grant_type": {"authorization_code"}, "code": {code}}
+		request := httptest.NewRequest("POST", uaa.TokenURL(), strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth(clientID, clientSecret)
+		recorder := httptest.NewRecorder()
+		uaa.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	accessToken := func(response *httptest.ResponseRecorder) string {
+		var body struct {
+			AccessToken string `json:"access_token"`
+			TokenType   string `json:"token_type"`
+		}
+		Expect(json.Unmarshal(response.Body.Bytes(), &body)).To(Succeed())
+		Expect(body.TokenType).To(Equal("bearer"))
+		return body.AccessToken
+	}
+
+	It("exchanges codes for access tokens of the user", func() {
+		response := exchange(uaa.NewCode("alice"), uaa.ClientID(), uaa.ClientSecret())
+		Expect(response.Code).To(Equal(http.StatusOK))
+
+		user, ok := uaa.User(accessToken(response))
+		Expect(ok).To(BeTrue())
+		Expect(user).To(Equal("alice"))
+	})
+
+	It("only exchanges a code once", func() {
+		code := uaa.NewCode("alice")
+		Expect(exchange(code, uaa.ClientID(), uaa.ClientSecret()).Code).To(Equal(http.StatusOK))
+
+		response := exchange(code, uaa.ClientID(), uaa.ClientSecret())
+		Expect(response.Code).To(Equal(http.StatusBadRequest))
+		Expect(response.Body.String()).To(ContainSubstring("invalid_grant"))
+	})
+
+	It("rejects unknown codes", func() {
+		Expect(exchange("bogus", uaa.ClientID(), uaa.ClientSecret()).Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("rejects clients with the wrong credentials", func() {
+		Expect(exchange(uaa.NewCode("alice"), uaa.ClientID(), "wrong").Code).To(Equal(http.StatusUnauthorized))
+	})
+
+	It("does not accept unknown tokens", func() {
+		_, ok := uaa.User("bogus")
+		Expect(ok).To(BeFalse())
+	})
+
+	Context("when the token TTL is not positive", func() {
+		BeforeEach(func() {
+			uaa.SetTokenTTL(0)
+		})
+
+		It("issues tokens that have already expired", func() {
+			response := exchange(uaa.NewCode("alice"), uaa.ClientID(), uaa.ClientSecret())
+			Expect(response.Code).To(Equal(http.StatusOK))
+
+			_, ok := uaa.User(accessToken(response))
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	It("expires the tokens issued so far", func() {
+		uaa.SetTokenTTL(time.Hour)
+		token := accessToken(exchange(uaa.NewCode("alice"), uaa.ClientID(), uaa.ClientSecret()))
+
+		uaa.ExpireTokens()
+
+		_, ok := uaa.User(token)
+		Expect(ok).To(BeFalse())
+	})
+})
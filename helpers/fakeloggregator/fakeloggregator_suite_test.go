@@ -0,0 +1,13 @@
+package fakeloggregator_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestFakeloggregator(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Fakeloggregator Suite")
+}
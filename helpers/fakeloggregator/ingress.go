@@ -0,0 +1,221 @@
+package fakeloggregator
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// LogLine is a single app or component log message received by the ingress
+// server.
+type LogLine struct {
+	Timestamp  time.Time
+	SourceId   string
+	InstanceId string
+	SourceType string
+	Stream     loggregator_v2.Log_Type
+	Message    string
+}
+
+// Ingress is a stand-in for the loggregator agent. It accepts envelopes over
+// the v2 ingress API and records them so they can be queried by tests.
+type Ingress struct {
+	address   string
+	tlsConfig *tls.Config
+
+	lock        sync.Mutex
+	envelopes   []*loggregator_v2.Envelope
+	subscribers map[chan *loggregator_v2.Envelope]struct{}
+}
+
+func New(address, serverCert, serverKey, caCert string) (*Ingress, error) {
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		return nil, err
+	}
+
+	caCertBytes, err := ioutil.ReadFile(caCert)
+	if err != nil {
+		return nil, err
+	}
+
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCertBytes) {
+		return nil, errors.New("failed to parse loggregator CA")
+	}
+
+	return &Ingress{
+		address:     address,
+		subscribers: map[chan *loggregator_v2.Envelope]struct{}{},
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+			ClientCAs:    caCertPool,
+			RootCAs:      caCertPool,
+		},
+	}, nil
+}
+
+func (i *Ingress) Address() string {
+	return i.address
+}
+
+func (i *Ingress) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	listener, err := net.Listen("tcp4", i.address)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(i.tlsConfig)))
+	loggregator_v2.RegisterIngressServer(server, i)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(listener)
+	}()
+
+	close(ready)
+
+	select {
+	case <-signals:
+		server.Stop()
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+func (i *Ingress) Sender(stream loggregator_v2.Ingress_SenderServer) error {
+	for {
+		envelope, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		i.record(envelope)
+	}
+}
+
+func (i *Ingress) BatchSender(stream loggregator_v2.Ingress_BatchSenderServer) error {
+	for {
+		batch, err := stream.Recv()
+		if err != nil {
+			return nil
+		}
+		i.record(batch.GetBatch()...)
+	}
+}
+
+func (i *Ingress) Send(_ context.Context, batch *loggregator_v2.EnvelopeBatch) (*loggregator_v2.SendResponse, error) {
+	i.record(batch.GetBatch()...)
+	return &loggregator_v2.SendResponse{}, nil
+}
+
+func (i *Ingress) record(envelopes ...*loggregator_v2.Envelope) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.envelopes = append(i.envelopes, envelopes...)
+	for subscriber := range i.subscribers {
+		for _, envelope := range envelopes {
+			select {
+			case subscriber <- envelope:
+			default:
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel that receives every envelope recorded from now
+// on, until unsubscribe is called. Envelopes are dropped for a subscriber
+// that falls more than 1024 envelopes behind.
+func (i *Ingress) Subscribe() (envelopes <-chan *loggregator_v2.Envelope, unsubscribe func()) {
+	subscriber := make(chan *loggregator_v2.Envelope, 1024)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.subscribers[subscriber] = struct{}{}
+
+	return subscriber, func() {
+		i.lock.Lock()
+		defer i.lock.Unlock()
+		delete(i.subscribers, subscriber)
+	}
+}
+
+// Envelopes returns every envelope received so far, in the order they were
+// received.
+func (i *Ingress) Envelopes() []*loggregator_v2.Envelope {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	envelopes := make([]*loggregator_v2.Envelope, len(i.envelopes))
+	copy(envelopes, i.envelopes)
+	return envelopes
+}
+
+// Reset discards every envelope received so far.
+func (i *Ingress) Reset() {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	i.envelopes = nil
+}
+
+// LogsFor returns the log lines whose source id is the given log guid.
+func (i *Ingress) LogsFor(logGuid string) []LogLine {
+	lines := []LogLine{}
+	for _, envelope := range i.Envelopes() {
+		log := envelope.GetLog()
+		if log == nil || envelope.GetSourceId() != logGuid {
+			continue
+		}
+
+		lines = append(lines, LogLine{
+			Timestamp:  time.Unix(0, envelope.GetTimestamp()),
+			SourceId:   envelope.GetSourceId(),
+			InstanceId: envelope.GetInstanceId(),
+			SourceType: envelope.GetTags()["source_type"],
+			Stream:     log.GetType(),
+			Message:    string(log.GetPayload()),
+		})
+	}
+	return lines
+}
+
+// CounterValue returns the current value of the named counter emitted by
+// source, accumulating deltas for envelopes that do not carry a total.
+func (i *Ingress) CounterValue(name, source string) uint64 {
+	var value uint64
+	for _, envelope := range i.Envelopes() {
+		counter := envelope.GetCounter()
+		if counter == nil || counter.GetName() != name || envelope.GetSourceId() != source {
+			continue
+		}
+
+		if counter.GetTotal() != 0 {
+			value = counter.GetTotal()
+		} else {
+			value += counter.GetDelta()
+		}
+	}
+	return value
+}
+
+// GaugeSeries returns every value reported for the named gauge metric, from
+// any source, in the order they were received.
+func (i *Ingress) GaugeSeries(name string) []float64 {
+	series := []float64{}
+	for _, envelope := range i.Envelopes() {
+		if value, ok := envelope.GetGauge().GetMetrics()[name]; ok {
+			series = append(series, value.GetValue())
+		}
+	}
+	return series
+}
@@ -0,0 +1,216 @@
+package fakeloggregator_test
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/fakeloggregator"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+)
+
+var _ = Describe("Ingress", func() {
+	var ingress *fakeloggregator.Ingress
+
+	send := func(envelopes ...*loggregator_v2.Envelope) {
+		_, err := ingress.Send(context.Background(), &loggregator_v2.EnvelopeBatch{Batch: envelopes})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		certs := filepath.Join("..", "..", "fixtures", "certs", "metron")
+
+		var err error
+		ingress, err = fakeloggregator.New(
+			"127.0.0.1:0",
+			filepath.Join(certs, "metron.crt"),
+			filepath.Join(certs, "metron.key"),
+			filepath.Join(certs, "CA.crt"),
+		)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("LogsFor", func() {
+		BeforeEach(func() {
+			send(
+				&loggregator_v2.Envelope{
+					SourceId:   "log-guid",
+					InstanceId: "1",
+					Tags:       map[string]string{"source_type": "CELL"},
+					Message:    &loggregator_v2.Envelope_Log{Log: &loggregator_v2.Log{Payload: []byte("Creating container")}},
+				},
+				&loggregator_v2.Envelope{
+					SourceId: "other-log-guid",
+					Message:  &loggregator_v2.Envelope_Log{Log: &loggregator_v2.Log{Payload: []byte("hello")}},
+				},
+				&loggregator_v2.Envelope{
+					SourceId: "log-guid",
+					Message:  &loggregator_v2.Envelope_Counter{Counter: &loggregator_v2.Counter{Name: "not-a-log"}},
+				},
+			)
+		})
+
+		It("returns only the log lines for the log guid", func() {
+			logs := ingress.LogsFor("log-guid")
+			Expect(logs).To(HaveLen(1))
+			Expect(logs[0].Message).To(Equal("Creating container"))
+			Expect(logs[0].SourceType).To(Equal("CELL"))
+			Expect(logs[0].InstanceId).To(Equal("1"))
+		})
+
+		It("forgets the logs when reset", func() {
+			ingress.Reset()
+			Expect(ingress.LogsFor("log-guid")).To(BeEmpty())
+		})
+	})
+
+	Describe("CounterValue", func() {
+		It("accumulates the deltas for the counter from the source", func() {
+			send(
+				&loggregator_v2.Envelope{
+					SourceId: "bbs",
+					Message:  &loggregator_v2.Envelope_Counter{Counter: &loggregator_v2.Counter{Name: "RequestCount", Delta: 2}},
+				},
+				&loggregator_v2.Envelope{
+					SourceId: "rep-0",
+					Message:  &loggregator_v2.Envelope_Counter{Counter: &loggregator_v2.Counter{Name: "RequestCount", Delta: 5}},
+				},
+				&loggregator_v2.Envelope{
+					SourceId: "bbs",
+					Message:  &loggregator_v2.Envelope_Counter{Counter: &loggregator_v2.Counter{Name: "RequestCount", Delta: 3}},
+				},
+			)
+
+			Expect(ingress.CounterValue("RequestCount", "bbs")).To(BeEquivalentTo(5))
+		})
+
+		It("uses the total when the envelope carries one", func() {
+			send(
+				&loggregator_v2.Envelope{
+					SourceId: "bbs",
+					Message:  &loggregator_v2.Envelope_Counter{Counter: &loggregator_v2.Counter{Name: "RequestCount", Total: 42}},
+				},
+			)
+
+			Expect(ingress.CounterValue("RequestCount", "bbs")).To(BeEquivalentTo(42))
+		})
+	})
+
+	Describe("GaugeSeries", func() {
+		It("returns the values of the gauge in the order they were received", func() {
+			gauge := func(value float64) *loggregator_v2.Envelope {
+				return &loggregator_v2.Envelope{
+					Message: &loggregator_v2.Envelope_Gauge{Gauge: &loggregator_v2.Gauge{
+						Metrics: map[string]*loggregator_v2.GaugeValue{"memory": {Value: value}},
+					}},
+				}
+			}
+
+			send(gauge(3), gauge(1), gauge(2))
+
+			Expect(ingress.GaugeSeries("memory")).To(Equal([]float64{3, 1, 2}))
+			Expect(ingress.GaugeSeries("cpu")).To(BeEmpty())
+		})
+	})
+
+	Describe("Subscribe", func() {
+		It("receives the envelopes recorded until unsubscribing", func() {
+			send(&loggregator_v2.Envelope{SourceId: "before"})
+
+			envelopes, unsubscribe := ingress.Subscribe()
+			send(&loggregator_v2.Envelope{SourceId: "during"})
+			unsubscribe()
+			send(&loggregator_v2.Envelope{SourceId: "after"})
+
+			var envelope *loggregator_v2.Envelope
+			Expect(envelopes).To(Receive(&envelope))
+			Expect(envelope.GetSourceId()).To(Equal("during"))
+			Expect(envelopes).NotTo(Receive())
+		})
+	})
+
+	Describe("TLS", func() {
+		var (
+			depotDir   string
+			clientCert tls.Certificate
+			process    ifrit.Process
+		)
+
+		BeforeEach(func() {
+			var err error
+			depotDir, err = ioutil.TempDir("", "fakeloggregator-certs")
+			Expect(err).NotTo(HaveOccurred())
+
+			ca, err := certauthority.NewCertAuthority(depotDir, "loggregator-ca")
+			Expect(err).NotTo(HaveOccurred())
+			_, caCert := ca.CAAndKey()
+
+			serverKey, serverCert, err := ca.GenerateSelfSignedCertAndKey("metron", nil, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			clientKey, clientCertFile, err := ca.GenerateSelfSignedCertAndKey("client", nil, false)
+			Expect(err).NotTo(HaveOccurred())
+			clientCert, err = tls.LoadX509KeyPair(clientCertFile, clientKey)
+			Expect(err).NotTo(HaveOccurred())
+
+			listener, err := net.Listen("tcp4", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			address := listener.Addr().String()
+			Expect(listener.Close()).To(Succeed())
+
+			ingress, err = fakeloggregator.New(address, serverCert, serverKey, caCert)
+			Expect(err).NotTo(HaveOccurred())
+
+			process = ginkgomon.Invoke(ingress)
+		})
+
+		AfterEach(func() {
+			ginkgomon.Kill(process)
+			os.RemoveAll(depotDir)
+		})
+
+		dial := func(certificates ...tls.Certificate) error {
+			conn, err := tls.Dial("tcp4", ingress.Address(), &tls.Config{
+				Certificates:       certificates,
+				InsecureSkipVerify: true,
+				NextProtos:         []string{"h2"},
+				// with TLS 1.2 the client learns that its certificate was
+				// rejected during the handshake rather than on the next read
+				MaxVersion: tls.VersionTLS12,
+			})
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}
+
+		It("accepts clients with a certificate signed by the CA", func() {
+			Expect(dial(clientCert)).To(Succeed())
+		})
+
+		It("rejects clients without a certificate", func() {
+			Expect(dial()).NotTo(Succeed())
+		})
+
+		It("rejects clients with a certificate signed by another CA", func() {
+			otherDepotDir, err := ioutil.TempDir(depotDir, "other")
+			Expect(err).NotTo(HaveOccurred())
+			otherCA, err := certauthority.NewCertAuthority(otherDepotDir, "other-ca")
+			Expect(err).NotTo(HaveOccurred())
+			otherKey, otherCertFile, err := otherCA.GenerateSelfSignedCertAndKey("client", nil, false)
+			Expect(err).NotTo(HaveOccurred())
+			otherCert, err := tls.LoadX509KeyPair(otherCertFile, otherKey)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(dial(otherCert)).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1 @@
+package fakeloggregator // import "code.cloudfoundry.org/inigo/helpers/fakeloggregator"
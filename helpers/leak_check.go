@@ -0,0 +1,133 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+
+	"code.cloudfoundry.org/inigo/helpers/leakcheck"
+	"code.cloudfoundry.org/inigo/world"
+	"github.com/onsi/ginkgo"
+)
+
+// NewLeakChecker returns a checker for what specs using the component maker
+// may leave behind: processes, listeners on the component addresses and the
+// given port ranges, grootfs images, volman mounts and directories created
+// by world.TempDir. It must be created before any component is started,
+// and fails if $LEAK_CHECK is not a known mode.
+func NewLeakChecker(maker world.ComponentMaker, portRanges ...leakcheck.PortRange) *leakcheck.Checker {
+	leakCheckMode()
+
+	probes := []leakcheck.Probe{
+		leakcheck.Paths("temp dirs", world.TempDirs),
+	}
+
+	for _, store := range maker.GrootFSStorePaths() {
+		probes = append(probes, leakcheck.DirEntries("grootfs images", filepath.Join(store, "images")))
+	}
+
+	volmanMounts := filepath.Join(maker.VolmanDriverConfigDir(), "_mounts")
+	probes = append(probes, leakcheck.DirEntries("volman mounts", volmanMounts))
+
+	if runtime.GOOS == "linux" {
+		probes = append(probes,
+			leakcheck.Processes(),
+			leakcheck.ListeningPorts(append(portRanges, addressPorts(maker.Addresses())...)...),
+			leakcheck.MountPoints("volman mounts", maker.VolmanDriverConfigDir()),
+		)
+	}
+
+	return leakcheck.NewChecker(probes...)
+}
+
+// addressPorts returns the ports of the component addresses, skipping those
+// that are not host:port pairs such as the SQL connection string.
+func addressPorts(addresses world.ComponentAddresses) []leakcheck.PortRange {
+	ranges := []leakcheck.PortRange{}
+
+	value := reflect.ValueOf(addresses)
+	for i := 0; i < value.NumField(); i++ {
+		address, ok := value.Field(i).Interface().(string)
+		if !ok {
+			continue
+		}
+
+		_, portString, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+		port, err := strconv.ParseUint(portString, 10, 16)
+		if err != nil {
+			continue
+		}
+		ranges = append(ranges, leakcheck.PortRange{From: uint16(port), To: uint16(port)})
+	}
+
+	return ranges
+}
+
+// leakCheckMode is set with $LEAK_CHECK to "warn" (the default), "fail" or
+// "off". Any other value fails the current spec or setup node.
+func leakCheckMode() string {
+	mode := os.Getenv("LEAK_CHECK")
+	switch mode {
+	case "":
+		return "warn"
+	case "warn", "fail", "off":
+		return mode
+	default:
+		ginkgo.Fail(fmt.Sprintf("unknown $LEAK_CHECK %q, expected warn, fail or off", mode), 2)
+		return ""
+	}
+}
+
+// SnapshotLeaks records what exists before the current spec runs.
+func SnapshotLeaks(checker *leakcheck.Checker) {
+	if leakCheckMode() == "off" {
+		return
+	}
+
+	err := checker.Snapshot()
+	if err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "leak check snapshot incomplete: %s\n", err)
+	}
+}
+
+// CheckForLeaks reports what the current spec left behind, failing the spec
+// when $LEAK_CHECK is "fail" and otherwise writing the report to stderr so
+// that it is visible for passing specs too. The leaks are removed in both
+// modes so that they do not hold on to ports or disk needed by later specs.
+func CheckForLeaks(checker *leakcheck.Checker) {
+	mode := leakCheckMode()
+	if mode == "off" {
+		return
+	}
+
+	leaks, err := checker.Check()
+	if err != nil {
+		fmt.Fprintf(ginkgo.GinkgoWriter, "leak check incomplete: %s\n", err)
+	}
+	if len(leaks) == 0 {
+		return
+	}
+
+	report := &bytes.Buffer{}
+	fmt.Fprintf(report, "%s leaked %d resources:\n", ginkgo.CurrentGinkgoTestDescription().FullTestText, len(leaks))
+	leakcheck.WriteReport(report, leaks)
+
+	err = checker.Remove(leaks)
+	if err != nil {
+		fmt.Fprintf(report, "failed to remove leaks: %s\n", err)
+	}
+
+	if mode == "fail" {
+		ginkgo.Fail(report.String(), 1)
+	}
+
+	fmt.Fprint(os.Stderr, report.String())
+}
@@ -0,0 +1,156 @@
+package leakcheck
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Resource is something a spec may leave behind, such as a process or a
+// listening port. ID identifies the resource across snapshots and Detail
+// describes it in reports.
+type Resource struct {
+	ID     string
+	Detail string
+}
+
+// Probe lists the resources of one kind that currently exist. Remove, if
+// set, gets rid of a leaked resource so that it does not affect later specs.
+type Probe struct {
+	Kind   string
+	List   func() ([]Resource, error)
+	Remove func(Resource) error
+}
+
+// Leak is a resource that exists now but did not when the checker took its
+// snapshot.
+type Leak struct {
+	Kind string
+	Resource
+}
+
+// Checker finds the resources a spec leaves behind by comparing what its
+// probes list after the spec with what they listed before it.
+type Checker struct {
+	probes []Probe
+
+	lock     sync.Mutex
+	snapshot map[string]map[string]bool
+}
+
+func NewChecker(probes ...Probe) *Checker {
+	return &Checker{probes: probes}
+}
+
+// Snapshot records the resources that currently exist. Probes that fail are
+// skipped by the next Check.
+func (c *Checker) Snapshot() error {
+	snapshot := map[string]map[string]bool{}
+	errs := []string{}
+
+	for _, probe := range c.probes {
+		resources, err := probe.List()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", probe.Kind, err))
+			continue
+		}
+
+		ids := map[string]bool{}
+		for _, resource := range resources {
+			ids[resource.ID] = true
+		}
+		snapshot[probe.Kind] = ids
+	}
+
+	c.lock.Lock()
+	c.snapshot = snapshot
+	c.lock.Unlock()
+
+	return joinErrors(errs)
+}
+
+// Check returns the resources that exist now but did not when Snapshot was
+// last called, sorted by kind and ID.
+func (c *Checker) Check() ([]Leak, error) {
+	c.lock.Lock()
+	snapshot := c.snapshot
+	c.lock.Unlock()
+
+	if snapshot == nil {
+		return nil, errors.New("no snapshot was taken")
+	}
+
+	leaks := []Leak{}
+	errs := []string{}
+
+	for _, probe := range c.probes {
+		before, ok := snapshot[probe.Kind]
+		if !ok {
+			continue
+		}
+
+		resources, err := probe.List()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", probe.Kind, err))
+			continue
+		}
+
+		for _, resource := range resources {
+			if !before[resource.ID] {
+				leaks = append(leaks, Leak{Kind: probe.Kind, Resource: resource})
+			}
+		}
+	}
+
+	sort.SliceStable(leaks, func(i, j int) bool {
+		if leaks[i].Kind != leaks[j].Kind {
+			return leaks[i].Kind < leaks[j].Kind
+		}
+		return leaks[i].ID < leaks[j].ID
+	})
+
+	return leaks, joinErrors(errs)
+}
+
+// Remove gets rid of the leaks whose probe knows how to, e.g. by killing
+// leaked processes.
+func (c *Checker) Remove(leaks []Leak) error {
+	errs := []string{}
+
+	for _, leak := range leaks {
+		for _, probe := range c.probes {
+			if probe.Kind != leak.Kind || probe.Remove == nil {
+				continue
+			}
+
+			err := probe.Remove(leak.Resource)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s %s: %s", leak.Kind, leak.ID, err))
+			}
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// WriteReport lists the leaks grouped by kind.
+func WriteReport(w io.Writer, leaks []Leak) {
+	kind := ""
+	for _, leak := range leaks {
+		if leak.Kind != kind {
+			kind = leak.Kind
+			fmt.Fprintf(w, "%s:\n", kind)
+		}
+		fmt.Fprintf(w, "  %s\n", leak.Detail)
+	}
+}
+
+func joinErrors(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
@@ -0,0 +1,13 @@
+package leakcheck_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLeakcheck(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Leakcheck Suite")
+}
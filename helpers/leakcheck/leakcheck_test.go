@@ -0,0 +1,205 @@
+package leakcheck_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+
+	"code.cloudfoundry.org/inigo/helpers/leakcheck"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Checker", func() {
+	var (
+		resources []leakcheck.Resource
+		listErr   error
+		removed   []leakcheck.Resource
+		checker   *leakcheck.Checker
+	)
+
+	BeforeEach(func() {
+		resources = []leakcheck.Resource{{ID: "a", Detail: "resource a"}}
+		listErr = nil
+		removed = nil
+
+		checker = leakcheck.NewChecker(leakcheck.Probe{
+			Kind: "things",
+			List: func() ([]leakcheck.Resource, error) {
+				return resources, listErr
+			},
+			Remove: func(resource leakcheck.Resource) error {
+				removed = append(removed, resource)
+				return nil
+			},
+		})
+	})
+
+	It("reports the resources that appeared since the snapshot", func() {
+		Expect(checker.Snapshot()).To(Succeed())
+		resources = []leakcheck.Resource{{ID: "b", Detail: "resource b"}}
+
+		leaks, err := checker.Check()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(leaks).To(Equal([]leakcheck.Leak{
+			{Kind: "things", Resource: leakcheck.Resource{ID: "b", Detail: "resource b"}},
+		}))
+
+		report := &bytes.Buffer{}
+		leakcheck.WriteReport(report, leaks)
+		Expect(report.String()).To(Equal("things:\n  resource b\n"))
+	})
+
+	It("removes leaks with the probe that found them", func() {
+		Expect(checker.Snapshot()).To(Succeed())
+		resources = append(resources, leakcheck.Resource{ID: "b"})
+
+		leaks, err := checker.Check()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checker.Remove(leaks)).To(Succeed())
+		Expect(removed).To(Equal([]leakcheck.Resource{{ID: "b"}}))
+	})
+
+	It("fails to check without a snapshot", func() {
+		_, err := checker.Check()
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("when a probe fails", func() {
+		It("skips the probe", func() {
+			listErr = errors.New("boom")
+			Expect(checker.Snapshot()).To(MatchError("things: boom"))
+
+			listErr = nil
+			resources = append(resources, leakcheck.Resource{ID: "b"})
+			Expect(checker.Check()).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Probes", func() {
+	BeforeEach(func() {
+		if runtime.GOOS != "linux" {
+			Skip("probes read /proc")
+		}
+	})
+
+	Describe("Processes", func() {
+		var checker *leakcheck.Checker
+
+		BeforeEach(func() {
+			checker = leakcheck.NewChecker(leakcheck.Processes())
+			Expect(checker.Snapshot()).To(Succeed())
+		})
+
+		It("finds started processes and kills them", func() {
+			cmd := exec.Command("sleep", "60")
+			Expect(cmd.Start()).To(Succeed())
+
+			leaks, err := checker.Check()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leaks).To(HaveLen(1))
+			Expect(leaks[0].Detail).To(ContainSubstring("pid " + strconv.Itoa(cmd.Process.Pid)))
+			Expect(leaks[0].Detail).To(ContainSubstring("sleep 60"))
+
+			Expect(checker.Remove(leaks)).To(Succeed())
+			Expect(cmd.Wait()).To(HaveOccurred())
+			Expect(checker.Check()).To(BeEmpty())
+		})
+
+		It("finds processes that were reparented", func() {
+			cmd := exec.Command("sh", "-c", "sleep 60 >/dev/null 2>&1 &")
+			Expect(cmd.Run()).To(Succeed())
+
+			leaks, err := checker.Check()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leaks).To(HaveLen(1))
+			Expect(leaks[0].Detail).To(ContainSubstring("sleep 60"))
+
+			Expect(checker.Remove(leaks)).To(Succeed())
+			Eventually(checker.Check).Should(BeEmpty())
+		})
+	})
+
+	Describe("ListeningPorts", func() {
+		It("finds new listeners in the range", func() {
+			checker := leakcheck.NewChecker(leakcheck.ListeningPorts(leakcheck.PortRange{From: 1, To: 65535}))
+			Expect(checker.Snapshot()).To(Succeed())
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+
+			leaks, err := checker.Check()
+			Expect(err).NotTo(HaveOccurred())
+
+			ids := []string{}
+			for _, leak := range leaks {
+				ids = append(ids, leak.ID)
+			}
+			Expect(ids).To(ContainElement(listener.Addr().String()))
+		})
+
+		It("ignores listeners outside the range", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			defer listener.Close()
+			port := uint16(listener.Addr().(*net.TCPAddr).Port)
+
+			probe := leakcheck.ListeningPorts(leakcheck.PortRange{From: port + 1, To: port + 1})
+			resources, err := probe.List()
+			Expect(err).NotTo(HaveOccurred())
+			for _, resource := range resources {
+				Expect(resource.ID).NotTo(Equal(listener.Addr().String()))
+			}
+		})
+	})
+
+	Describe("DirEntries", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "leakcheck")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("finds new entries", func() {
+			checker := leakcheck.NewChecker(leakcheck.DirEntries("images", dir))
+			Expect(checker.Snapshot()).To(Succeed())
+
+			Expect(os.Mkdir(filepath.Join(dir, "image"), 0755)).To(Succeed())
+
+			leaks, err := checker.Check()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(leaks).To(HaveLen(1))
+			Expect(leaks[0].ID).To(Equal(filepath.Join(dir, "image")))
+		})
+
+		It("treats a missing dir as empty", func() {
+			resources, err := leakcheck.DirEntries("images", filepath.Join(dir, "missing")).List()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(BeEmpty())
+		})
+	})
+
+	Describe("MountPoints", func() {
+		It("lists only mount points below the dir", func() {
+			resources, err := leakcheck.MountPoints("mounts", "/").List()
+			Expect(err).NotTo(HaveOccurred())
+			for _, resource := range resources {
+				Expect(resource.ID).NotTo(Equal("/"))
+			}
+		})
+	})
+})
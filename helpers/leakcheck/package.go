@@ -0,0 +1 @@
+package leakcheck // import "code.cloudfoundry.org/inigo/helpers/leakcheck"
@@ -0,0 +1,347 @@
+package leakcheck
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/inigo/helpers/procstat"
+)
+
+// ProcessTagEnv is set in the environment of the test process by Processes.
+// Descendants inherit it, so they can be recognized even after they have
+// been reparented to init.
+const ProcessTagEnv = "INIGO_LEAKCHECK_PID"
+
+// Processes lists the processes started by the test process, excluding
+// zombies. Leaked processes are removed by killing them.
+func Processes() Probe {
+	self := os.Getpid()
+	os.Setenv(ProcessTagEnv, strconv.Itoa(self))
+
+	return Probe{
+		Kind: "processes",
+		List: func() ([]Resource, error) {
+			return listProcesses(self)
+		},
+		Remove: func(resource Resource) error {
+			pid, err := strconv.Atoi(strings.SplitN(resource.ID, "-", 2)[0])
+			if err != nil {
+				return err
+			}
+			process, err := os.FindProcess(pid)
+			if err != nil {
+				return err
+			}
+			return process.Kill()
+		},
+	}
+}
+
+func listProcesses(self int) ([]Resource, error) {
+	all, err := procstat.All()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[int]procstat.Stat{}
+	children := map[int][]int{}
+	for _, stat := range all {
+		stats[stat.Pid] = stat
+		children[stat.PPid] = append(children[stat.PPid], stat.Pid)
+	}
+
+	started := map[int]bool{}
+	queue := children[self]
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		started[pid] = true
+		queue = append(queue, children[pid]...)
+	}
+
+	tag := []byte(ProcessTagEnv + "=" + strconv.Itoa(self) + "\x00")
+	for pid := range stats {
+		if pid == self || started[pid] {
+			continue
+		}
+		environ, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "environ"))
+		if err == nil && bytes.Contains(append([]byte{0}, environ...), append([]byte{0}, tag...)) {
+			started[pid] = true
+		}
+	}
+
+	resources := []Resource{}
+	for pid := range started {
+		stat := stats[pid]
+		if stat.State == "Z" {
+			continue
+		}
+
+		resources = append(resources, Resource{
+			ID:     fmt.Sprintf("%d-%d", pid, stat.StartTime),
+			Detail: fmt.Sprintf("pid %d (ppid %d, state %s): %s", pid, stat.PPid, stat.State, readCmdline(pid)),
+		})
+	}
+	return resources, nil
+}
+
+func readCmdline(pid int) string {
+	contents, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return "<unknown>"
+	}
+	return strings.TrimSpace(strings.Replace(string(contents), "\x00", " ", -1))
+}
+
+// PortRange is an inclusive range of TCP ports.
+type PortRange struct {
+	From, To uint16
+}
+
+func (r PortRange) contains(port uint16) bool {
+	return port >= r.From && port <= r.To
+}
+
+// ListeningPorts lists the TCP ports in the given ranges that something
+// listens on, along with the process listening.
+func ListeningPorts(ranges ...PortRange) Probe {
+	return Probe{
+		Kind: "listening ports",
+		List: func() ([]Resource, error) {
+			return listListeningPorts(ranges)
+		},
+	}
+}
+
+const tcpListen = "0A"
+
+func listListeningPorts(ranges []PortRange) ([]Resource, error) {
+	type socket struct {
+		address string
+		inode   string
+	}
+
+	sockets := []socket{}
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		contents, err := ioutil.ReadFile(table)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(contents))
+		scanner.Scan() // header
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 10 || fields[3] != tcpListen {
+				continue
+			}
+
+			ip, port, err := parseProcNetAddress(fields[1])
+			if err != nil {
+				return nil, err
+			}
+
+			for _, r := range ranges {
+				if r.contains(port) {
+					sockets = append(sockets, socket{
+						address: net.JoinHostPort(ip.String(), strconv.Itoa(int(port))),
+						inode:   fields[9],
+					})
+					break
+				}
+			}
+		}
+	}
+
+	if len(sockets) == 0 {
+		return []Resource{}, nil
+	}
+
+	owners := socketOwners()
+	resources := make([]Resource, len(sockets))
+	for i, s := range sockets {
+		owner, ok := owners[s.inode]
+		if !ok {
+			owner = "unknown process"
+		}
+		resources[i] = Resource{
+			ID:     s.address,
+			Detail: fmt.Sprintf("%s (%s)", s.address, owner),
+		}
+	}
+	return resources, nil
+}
+
+// parseProcNetAddress parses the hex encoded "address:port" of
+// /proc/net/tcp. The address is stored as native endian 32 bit words.
+func parseProcNetAddress(s string) (net.IP, uint16, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("malformed address %q", s)
+	}
+
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil || len(raw)%4 != 0 {
+		return nil, 0, fmt.Errorf("malformed address %q", s)
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("malformed address %q", s)
+	}
+
+	return ip, uint16(port), nil
+}
+
+// socketOwners maps socket inodes to a description of the process holding
+// them open.
+func socketOwners() map[string]string {
+	owners := map[string]string{}
+
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return owners
+	}
+
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			owners[inode] = fmt.Sprintf("pid %d: %s", pid, readCmdline(pid))
+		}
+	}
+
+	return owners
+}
+
+// DirEntries lists the entries of dir, e.g. the images of a grootfs store. A
+// missing dir has no entries.
+func DirEntries(kind, dir string) Probe {
+	return Probe{
+		Kind: kind,
+		List: func() ([]Resource, error) {
+			entries, err := ioutil.ReadDir(dir)
+			if os.IsNotExist(err) {
+				return []Resource{}, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			resources := make([]Resource, len(entries))
+			for i, entry := range entries {
+				path := filepath.Join(dir, entry.Name())
+				resources[i] = Resource{
+					ID:     path,
+					Detail: fmt.Sprintf("%s (%s, modified %s)", path, entry.Mode(), entry.ModTime().Format("15:04:05.000")),
+				}
+			}
+			return resources, nil
+		},
+	}
+}
+
+// MountPoints lists the mount points below dir.
+func MountPoints(kind, dir string) Probe {
+	return Probe{
+		Kind: kind,
+		List: func() ([]Resource, error) {
+			return listMountPoints(filepath.Clean(dir))
+		},
+	}
+}
+
+func listMountPoints(dir string) ([]Resource, error) {
+	contents, err := ioutil.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []Resource{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for scanner.Scan() {
+		// id parent major:minor root mountpoint options [optional...] - fstype source superoptions
+		line := strings.SplitN(scanner.Text(), " - ", 2)
+		fields := strings.Fields(line[0])
+		if len(fields) < 5 {
+			continue
+		}
+
+		mountPoint := unescapeMountInfo(fields[4])
+		if !strings.HasPrefix(mountPoint, dir+string(filepath.Separator)) {
+			continue
+		}
+
+		detail := mountPoint
+		if len(line) == 2 {
+			if fs := strings.Fields(line[1]); len(fs) >= 2 {
+				detail = fmt.Sprintf("%s (%s from %s)", mountPoint, fs[0], fs[1])
+			}
+		}
+		resources = append(resources, Resource{ID: mountPoint, Detail: detail})
+	}
+
+	return resources, scanner.Err()
+}
+
+// unescapeMountInfo decodes the octal escapes mountinfo uses for spaces,
+// tabs, newlines and backslashes.
+func unescapeMountInfo(s string) string {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				out.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		out.WriteByte(s[i])
+	}
+	return out.String()
+}
+
+// Paths lists the paths returned by list, e.g. the directories created by
+// world.TempDir that still exist.
+func Paths(kind string, list func() []string) Probe {
+	return Probe{
+		Kind: kind,
+		List: func() ([]Resource, error) {
+			paths := list()
+			resources := make([]Resource, len(paths))
+			for i, path := range paths {
+				resources[i] = Resource{ID: path, Detail: path}
+			}
+			return resources, nil
+		},
+	}
+}
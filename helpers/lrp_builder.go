@@ -0,0 +1,243 @@
+package helpers
+
+import (
+	"encoding/json"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/world"
+	"code.cloudfoundry.org/routing-info/cfroutes"
+	"code.cloudfoundry.org/routing-info/internalroutes"
+	"code.cloudfoundry.org/routing-info/tcp_routes"
+	. "github.com/onsi/gomega"
+)
+
+// LRPBuilder builds a DesiredLRP for the inigo domain. It starts out as the
+// default LRP: one instance of the go-server fixture downloaded from the file
+// server, listening and routed on port 8080 and monitored with nc, e.g.
+//
+//	lrp := helpers.NewLRP(componentMaker.Addresses()).
+//		WithInstances(2).
+//		WithTCPRoutes(tcp_routes.TCPRoute{RouterGroupGuid: guid, ExternalPort: 1234, ContainerPort: 8080}).
+//		Build()
+type LRPBuilder struct {
+	lrp            *models.DesiredLRP
+	httpRoutes     cfroutes.CFRoutes
+	tcpRoutes      tcp_routes.TCPRoutes
+	internalRoutes internalroutes.InternalRoutes
+}
+
+func NewLRP(addresses world.ComponentAddresses) *LRPBuilder {
+	return &LRPBuilder{
+		lrp: &models.DesiredLRP{
+			ProcessGuid: GenerateGuid(),
+			Domain:      defaultDomain,
+			RootFs:      defaultPreloadedRootFS,
+			Instances:   1,
+
+			LogGuid: defaultLogGuid,
+
+			Ports: defaultPorts,
+
+			Setup:   defaultSetup(addresses),
+			Action:  models.WrapAction(defaultAction),
+			Monitor: models.WrapAction(defaultMonitor),
+		},
+		httpRoutes: cfroutes.CFRoutes{{Hostnames: []string{DefaultHost}, Port: 8080}},
+	}
+}
+
+func (b *LRPBuilder) WithProcessGuid(processGuid string) *LRPBuilder {
+	b.lrp.ProcessGuid = processGuid
+	return b
+}
+
+func (b *LRPBuilder) WithLogGuid(logGuid string) *LRPBuilder {
+	b.lrp.LogGuid = logGuid
+	return b
+}
+
+func (b *LRPBuilder) WithRootFS(rootfs string) *LRPBuilder {
+	b.lrp.RootFs = rootfs
+	return b
+}
+
+// WithImageCredentials sets the credentials used to pull a docker rootfs.
+func (b *LRPBuilder) WithImageCredentials(username, password string) *LRPBuilder {
+	b.lrp.ImageUsername = username
+	b.lrp.ImagePassword = password
+	return b
+}
+
+func (b *LRPBuilder) WithInstances(instances int) *LRPBuilder {
+	b.lrp.Instances = int32(instances)
+	return b
+}
+
+// WithHTTPRoutes replaces the routes registered with the HTTP router.
+func (b *LRPBuilder) WithHTTPRoutes(routes ...cfroutes.CFRoute) *LRPBuilder {
+	b.httpRoutes = routes
+	return b
+}
+
+// WithTCPRoutes replaces the routes registered with the TCP router.
+func (b *LRPBuilder) WithTCPRoutes(routes ...tcp_routes.TCPRoute) *LRPBuilder {
+	b.tcpRoutes = routes
+	return b
+}
+
+// WithInternalRoutes replaces the hostnames registered for service
+// discovery.
+func (b *LRPBuilder) WithInternalRoutes(hostnames ...string) *LRPBuilder {
+	b.internalRoutes = nil
+	for _, hostname := range hostnames {
+		b.internalRoutes = append(b.internalRoutes, internalroutes.InternalRoute{Hostname: hostname})
+	}
+	return b
+}
+
+func (b *LRPBuilder) WithPorts(ports ...uint32) *LRPBuilder {
+	b.lrp.Ports = ports
+	return b
+}
+
+// WithSetup replaces the setup action. A nil action removes it.
+func (b *LRPBuilder) WithSetup(action models.ActionInterface) *LRPBuilder {
+	b.lrp.Setup = wrapAction(action)
+	return b
+}
+
+func (b *LRPBuilder) WithAction(action models.ActionInterface) *LRPBuilder {
+	b.lrp.Action = wrapAction(action)
+	return b
+}
+
+// WithMonitor replaces the monitor action. A nil action removes it.
+func (b *LRPBuilder) WithMonitor(action models.ActionInterface) *LRPBuilder {
+	b.lrp.Monitor = wrapAction(action)
+	return b
+}
+
+// WithDeclarativeTCPCheck adds a declarative check that connects to port.
+// It is only used by reps with declarative healthchecks enabled; remove
+// the monitor with WithMonitor(nil) to rely on it alone.
+func (b *LRPBuilder) WithDeclarativeTCPCheck(port uint32) *LRPBuilder {
+	return b.withCheck(&models.Check{TcpCheck: &models.TCPCheck{Port: port}})
+}
+
+// WithDeclarativeHTTPCheck adds a declarative check that requests path on
+// port.
+func (b *LRPBuilder) WithDeclarativeHTTPCheck(port uint32, path string) *LRPBuilder {
+	return b.withCheck(&models.Check{HttpCheck: &models.HTTPCheck{Port: port, Path: path}})
+}
+
+func (b *LRPBuilder) withCheck(check *models.Check) *LRPBuilder {
+	if b.lrp.CheckDefinition == nil {
+		b.lrp.CheckDefinition = &models.CheckDefinition{}
+	}
+	b.lrp.CheckDefinition.Checks = append(b.lrp.CheckDefinition.Checks, check)
+	return b
+}
+
+func (b *LRPBuilder) WithStartTimeout(timeout time.Duration) *LRPBuilder {
+	b.lrp.StartTimeoutMs = int64(timeout / time.Millisecond)
+	return b
+}
+
+// WithSidecar adds a process that runs alongside the action with its own
+// resource limits.
+func (b *LRPBuilder) WithSidecar(action models.ActionInterface, memoryMB, diskMB int) *LRPBuilder {
+	b.lrp.Sidecars = append(b.lrp.Sidecars, &models.Sidecar{
+		Action:   wrapAction(action),
+		MemoryMb: int32(memoryMB),
+		DiskMb:   int32(diskMB),
+	})
+	return b
+}
+
+func (b *LRPBuilder) WithVolumeMount(mount *models.VolumeMount) *LRPBuilder {
+	b.lrp.VolumeMounts = append(b.lrp.VolumeMounts, mount)
+	return b
+}
+
+func (b *LRPBuilder) WithResources(memoryMB, diskMB int) *LRPBuilder {
+	b.lrp.MemoryMb = int32(memoryMB)
+	b.lrp.DiskMb = int32(diskMB)
+	return b
+}
+
+func (b *LRPBuilder) WithMaxPids(maxPids int) *LRPBuilder {
+	b.lrp.MaxPids = int32(maxPids)
+	return b
+}
+
+func (b *LRPBuilder) WithPrivileged(privileged bool) *LRPBuilder {
+	b.lrp.Privileged = privileged
+	return b
+}
+
+func (b *LRPBuilder) WithPlacementTags(tags ...string) *LRPBuilder {
+	b.lrp.PlacementTags = tags
+	return b
+}
+
+// WithMetricTag adds a metric tag with a static value.
+func (b *LRPBuilder) WithMetricTag(name, value string) *LRPBuilder {
+	return b.withMetricTag(name, &models.MetricTagValue{Static: value})
+}
+
+// WithDynamicMetricTag adds a metric tag whose value is filled in per
+// instance, e.g. models.MetricTagDynamicValueIndex.
+func (b *LRPBuilder) WithDynamicMetricTag(name string, value models.MetricTagValue_DynamicValue) *LRPBuilder {
+	return b.withMetricTag(name, &models.MetricTagValue{Dynamic: value})
+}
+
+func (b *LRPBuilder) withMetricTag(name string, value *models.MetricTagValue) *LRPBuilder {
+	if b.lrp.MetricTags == nil {
+		b.lrp.MetricTags = map[string]*models.MetricTagValue{}
+	}
+	b.lrp.MetricTags[name] = value
+	return b
+}
+
+// WithCertificateProperties sets the properties, such as the organizational
+// units, of the instance identity certificates.
+func (b *LRPBuilder) WithCertificateProperties(properties *models.CertificateProperties) *LRPBuilder {
+	b.lrp.CertificateProperties = properties
+	return b
+}
+
+// Build returns the LRP after checking that BBS would accept it.
+func (b *LRPBuilder) Build() *models.DesiredLRP {
+	routes := models.Routes{}
+	if len(b.httpRoutes) > 0 {
+		setRoutes(routes, cfroutes.CF_ROUTER, b.httpRoutes)
+	}
+	if len(b.tcpRoutes) > 0 {
+		setRoutes(routes, tcp_routes.TCP_ROUTER, b.tcpRoutes)
+	}
+	if len(b.internalRoutes) > 0 {
+		setRoutes(routes, internalroutes.INTERNAL_ROUTER, b.internalRoutes)
+	}
+
+	lrp := *b.lrp
+	lrp.Routes = &routes
+
+	ExpectWithOffset(1, lrp.Validate()).To(Succeed())
+	return &lrp
+}
+
+func setRoutes(routes models.Routes, router string, info interface{}) {
+	data, err := json.Marshal(info)
+	Expect(err).NotTo(HaveOccurred())
+
+	raw := json.RawMessage(data)
+	routes[router] = &raw
+}
+
+func wrapAction(action models.ActionInterface) *models.Action {
+	if action == nil {
+		return nil
+	}
+	return models.WrapAction(action)
+}
@@ -0,0 +1,345 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/servicediscovery"
+	"github.com/nats-io/nats.go"
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+)
+
+const (
+	RouterRegisterSubject   = "router.register"
+	RouterUnregisterSubject = "router.unregister"
+	RouterGreetSubject      = "router.greet"
+	RouterStartSubject      = "router.start"
+
+	ServiceDiscoveryRegisterSubject   = servicediscovery.RegisterSubject
+	ServiceDiscoveryUnregisterSubject = servicediscovery.UnregisterSubject
+)
+
+// RouteRegistration is the payload of a route register or unregister
+// message.
+type RouteRegistration = servicediscovery.RegistryMessage
+
+// NATSMessage is a message received by a NATSRecorder. Registration is only
+// set for register and unregister messages.
+type NATSMessage struct {
+	Subject      string
+	Data         []byte
+	Registration *RouteRegistration
+	ReceivedAt   time.Time
+}
+
+// NATSRecorder records the routing messages published on NATS by the route
+// emitter and the router, in the order they were published.
+type NATSRecorder struct {
+	conn          *nats.Conn
+	subscriptions []*nats.Subscription
+	messages      chan *nats.Msg
+	done          chan struct{}
+
+	lock     sync.Mutex
+	received []NATSMessage
+}
+
+// NewNATSRecorder subscribes to the router.* and service-discovery.*
+// subjects on the NATS server at natsAddress, e.g.
+// componentMaker.Addresses().NATS, or on any of the comma separated servers
+// of a cluster with its ClientOptions. Close it when done.
+func NewNATSRecorder(natsAddress string, options ...nats.Option) *NATSRecorder {
+	urls := []string{}
+	for _, address := range strings.Split(natsAddress, ",") {
+		urls = append(urls, "nats://"+address)
+	}
+
+	conn, err := nats.Connect(strings.Join(urls, ","), options...)
+	gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+	recorder := &NATSRecorder{
+		conn:     conn,
+		messages: make(chan *nats.Msg, 1024),
+		done:     make(chan struct{}),
+	}
+
+	// Both subscriptions deliver to the same channel from the connection's
+	// read loop, which keeps messages across subjects in order.
+	for _, subject := range []string{"router.*", "service-discovery.*"} {
+		subscription, err := conn.ChanSubscribe(subject, recorder.messages)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		recorder.subscriptions = append(recorder.subscriptions, subscription)
+	}
+	gomega.Expect(conn.Flush()).To(gomega.Succeed())
+
+	go recorder.record()
+	return recorder
+}
+
+func (r *NATSRecorder) record() {
+	defer close(r.done)
+
+	for msg := range r.messages {
+		message := NATSMessage{
+			Subject:    msg.Subject,
+			Data:       msg.Data,
+			ReceivedAt: time.Now(),
+		}
+
+		switch msg.Subject {
+		case RouterRegisterSubject, RouterUnregisterSubject, ServiceDiscoveryRegisterSubject, ServiceDiscoveryUnregisterSubject:
+			registration := &RouteRegistration{}
+			if err := json.Unmarshal(msg.Data, registration); err == nil {
+				message.Registration = registration
+			}
+		}
+
+		r.lock.Lock()
+		r.received = append(r.received, message)
+		r.lock.Unlock()
+	}
+}
+
+// Close unsubscribes and disconnects from NATS. The messages received so far
+// are kept.
+func (r *NATSRecorder) Close() {
+	for _, subscription := range r.subscriptions {
+		subscription.Unsubscribe()
+	}
+	r.conn.Close()
+	close(r.messages)
+	<-r.done
+}
+
+// Messages returns every message received so far.
+func (r *NATSRecorder) Messages() []NATSMessage {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	messages := make([]NATSMessage, len(r.received))
+	copy(messages, r.received)
+	return messages
+}
+
+// MessagesOn returns the messages received on subject.
+func (r *NATSRecorder) MessagesOn(subject string) []NATSMessage {
+	messages := []NATSMessage{}
+	for _, message := range r.Messages() {
+		if message.Subject == subject {
+			messages = append(messages, message)
+		}
+	}
+	return messages
+}
+
+// Registrations returns the payloads of the messages received on subject,
+// e.g. RouterRegisterSubject.
+func (r *NATSRecorder) Registrations(subject string) []RouteRegistration {
+	registrations := []RouteRegistration{}
+	for _, message := range r.MessagesOn(subject) {
+		if message.Registration != nil {
+			registrations = append(registrations, *message.Registration)
+		}
+	}
+	return registrations
+}
+
+// Reset discards every message received so far.
+func (r *NATSRecorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.received = nil
+}
+
+// RouteSpec describes a route registration. Zero fields are not checked;
+// Tags only has to be a subset of the registration's tags.
+type RouteSpec struct {
+	URI                  string
+	Host                 string
+	Port                 uint32
+	TLSPort              uint32
+	PrivateInstanceId    string
+	PrivateInstanceIndex string
+	RouteServiceURL      string
+	TTL                  time.Duration
+	Tags                 map[string]string
+}
+
+// Matches reports whether registration has the fields set in the spec.
+func (s RouteSpec) Matches(registration RouteRegistration) bool {
+	if s.URI != "" && !containsString(registration.URIs, s.URI) {
+		return false
+	}
+	if s.Host != "" && registration.Host != s.Host {
+		return false
+	}
+	if s.Port != 0 && registration.Port != s.Port {
+		return false
+	}
+	if s.TLSPort != 0 && registration.TLSPort != s.TLSPort {
+		return false
+	}
+	if s.PrivateInstanceId != "" && registration.PrivateInstanceId != s.PrivateInstanceId {
+		return false
+	}
+	if s.PrivateInstanceIndex != "" && registration.PrivateInstanceIndex != s.PrivateInstanceIndex {
+		return false
+	}
+	if s.RouteServiceURL != "" && registration.RouteServiceURL != s.RouteServiceURL {
+		return false
+	}
+	if s.TTL != 0 && time.Duration(registration.StaleThresholdInSeconds)*time.Second != s.TTL {
+		return false
+	}
+	for name, value := range s.Tags {
+		if registration.Tags[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (s RouteSpec) String() string {
+	parts := []string{}
+	add := func(name string, value interface{}, set bool) {
+		if set {
+			parts = append(parts, fmt.Sprintf("%s=%v", name, value))
+		}
+	}
+	add("URI", s.URI, s.URI != "")
+	add("Host", s.Host, s.Host != "")
+	add("Port", s.Port, s.Port != 0)
+	add("TLSPort", s.TLSPort, s.TLSPort != 0)
+	add("PrivateInstanceId", s.PrivateInstanceId, s.PrivateInstanceId != "")
+	add("PrivateInstanceIndex", s.PrivateInstanceIndex, s.PrivateInstanceIndex != "")
+	add("RouteServiceURL", s.RouteServiceURL, s.RouteServiceURL != "")
+	add("TTL", s.TTL, s.TTL != 0)
+	if len(s.Tags) > 0 {
+		add("Tags", sortedTags(s.Tags), true)
+	}
+	if len(parts) == 0 {
+		return "any route"
+	}
+	return strings.Join(parts, " ")
+}
+
+// HaveRegisteredRoute succeeds if a *NATSRecorder or []NATSMessage has a
+// router.register message matching spec, e.g.
+//
+//	Eventually(recorder).Should(helpers.HaveRegisteredRoute(helpers.RouteSpec{
+//		URI:     helpers.DefaultHost,
+//		Port:    61001,
+//		TLSPort: 61002,
+//	}))
+func HaveRegisteredRoute(spec RouteSpec) gomega.OmegaMatcher {
+	return &routeMessageMatcher{subject: RouterRegisterSubject, spec: spec}
+}
+
+// HaveUnregisteredRoute succeeds if there is a router.unregister message
+// matching spec.
+func HaveUnregisteredRoute(spec RouteSpec) gomega.OmegaMatcher {
+	return &routeMessageMatcher{subject: RouterUnregisterSubject, spec: spec}
+}
+
+// HaveRegisteredInternalRoute succeeds if there is a
+// service-discovery.register message matching spec.
+func HaveRegisteredInternalRoute(spec RouteSpec) gomega.OmegaMatcher {
+	return &routeMessageMatcher{subject: ServiceDiscoveryRegisterSubject, spec: spec}
+}
+
+type routeMessageMatcher struct {
+	subject string
+	spec    RouteSpec
+
+	registrations []RouteRegistration
+}
+
+func (m *routeMessageMatcher) Match(actual interface{}) (bool, error) {
+	var messages []NATSMessage
+	switch a := actual.(type) {
+	case *NATSRecorder:
+		messages = a.Messages()
+	case []NATSMessage:
+		messages = a
+	default:
+		return false, fmt.Errorf("expected a *helpers.NATSRecorder or []helpers.NATSMessage, got\n%s", format.Object(actual, 1))
+	}
+
+	m.registrations = nil
+	for _, message := range messages {
+		if message.Subject == m.subject && message.Registration != nil {
+			m.registrations = append(m.registrations, *message.Registration)
+		}
+	}
+
+	for _, registration := range m.registrations {
+		if m.spec.Matches(registration) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *routeMessageMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected a %s message with\n  %s\n%s", m.subject, m.spec, m.describeRegistrations())
+}
+
+func (m *routeMessageMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected no %s message with\n  %s\n%s", m.subject, m.spec, m.describeRegistrations())
+}
+
+func (m *routeMessageMatcher) describeRegistrations() string {
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "Received %d %s messages:\n", len(m.registrations), m.subject)
+	for i, registration := range m.registrations {
+		fmt.Fprintf(buffer, "  %3d. %s\n", i+1, describeRegistration(registration))
+	}
+	return buffer.String()
+}
+
+func describeRegistration(registration RouteRegistration) string {
+	description := fmt.Sprintf("URIs=%s Host=%s Port=%d", strings.Join(registration.URIs, ","), registration.Host, registration.Port)
+	if registration.TLSPort != 0 {
+		description += fmt.Sprintf(" TLSPort=%d", registration.TLSPort)
+	}
+	if registration.PrivateInstanceId != "" {
+		description += fmt.Sprintf(" PrivateInstanceId=%s", registration.PrivateInstanceId)
+	}
+	if registration.PrivateInstanceIndex != "" {
+		description += fmt.Sprintf(" PrivateInstanceIndex=%s", registration.PrivateInstanceIndex)
+	}
+	if registration.RouteServiceURL != "" {
+		description += fmt.Sprintf(" RouteServiceURL=%s", registration.RouteServiceURL)
+	}
+	if registration.StaleThresholdInSeconds != 0 {
+		description += fmt.Sprintf(" TTL=%s", time.Duration(registration.StaleThresholdInSeconds)*time.Second)
+	}
+	if len(registration.Tags) > 0 {
+		description += fmt.Sprintf(" Tags=%s", sortedTags(registration.Tags))
+	}
+	return description
+}
+
+func sortedTags(tags map[string]string) string {
+	pairs := []string{}
+	for name, value := range tags {
+		pairs = append(pairs, name+":"+value)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
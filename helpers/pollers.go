@@ -1,7 +1,8 @@
 package helpers
 
 import (
-	"strings"
+	"context"
+	"fmt"
 
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/bbs/models"
@@ -11,7 +12,7 @@ import (
 )
 
 func filteredActualLRPs(logger lager.Logger, client bbs.InternalClient, processGuid string, filter func(lrp *models.ActualLRP) bool) []models.ActualLRP {
-	lrps, err := client.ActualLRPs(logger, models.ActualLRPFilter{ProcessGuid: processGuid})
+	lrps, err := actualLRPs(context.Background(), logger, client, models.ActualLRPFilter{ProcessGuid: processGuid})
 	Expect(err).NotTo(HaveOccurred())
 
 	startedLRPs := make([]models.ActualLRP, 0, len(lrps))
@@ -36,77 +37,185 @@ func RunningActualLRPs(logger lager.Logger, client bbs.InternalClient, processGu
 	})
 }
 
-func TaskStatePoller(logger lager.Logger, client bbs.InternalClient, taskGuid string, task *models.Task) func() models.Task_State {
-	return func() models.Task_State {
-		rTask, err := client.TaskByGuid(logger, taskGuid)
-		Expect(err).NotTo(HaveOccurred())
+// The pollers below return an error, rather than failing the spec, when BBS
+// can't be reached, so that Eventually retries them. Once ctx is done they
+// stop calling BBS and return ctx.Err(), but the vendored gomega has no way
+// for a poller to stop Eventually early: it keeps polling until its own
+// timeout, so give it one no longer than ctx's.
 
-		if task != nil {
-			*task = *rTask
-		}
+func TaskStatePoller(logger lager.Logger, client bbs.InternalClient, taskGuid string, task *models.Task) func() (models.Task_State, error) {
+	return TaskStatePollerContext(context.Background(), logger, client, taskGuid, task)
+}
 
-		return rTask.State
+func TaskStatePollerContext(ctx context.Context, logger lager.Logger, client bbs.InternalClient, taskGuid string, task *models.Task) func() (models.Task_State, error) {
+	return func() (models.Task_State, error) {
+		rTask, err := taskByGuid(ctx, logger, client, taskGuid, task)
+		if err != nil {
+			return models.Task_Invalid, err
+		}
+		return rTask.State, nil
 	}
 }
 
-func TaskFailedPoller(logger lager.Logger, client bbs.InternalClient, taskGuid string, task *models.Task) func() bool {
-	return func() bool {
-		rTask, err := client.TaskByGuid(logger, taskGuid)
-		Expect(err).NotTo(HaveOccurred())
+func TaskFailedPoller(logger lager.Logger, client bbs.InternalClient, taskGuid string, task *models.Task) func() (bool, error) {
+	return TaskFailedPollerContext(context.Background(), logger, client, taskGuid, task)
+}
 
-		if task != nil {
-			*task = *rTask
+func TaskFailedPollerContext(ctx context.Context, logger lager.Logger, client bbs.InternalClient, taskGuid string, task *models.Task) func() (bool, error) {
+	return func() (bool, error) {
+		rTask, err := taskByGuid(ctx, logger, client, taskGuid, task)
+		if err != nil {
+			return false, err
 		}
+		return rTask.Failed, nil
+	}
+}
 
-		return rTask.Failed
+func taskByGuid(ctx context.Context, logger lager.Logger, client bbs.InternalClient, taskGuid string, task *models.Task) (*models.Task, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+
+	rTask, err := client.TaskByGuid(logger, taskGuid)
+	if err != nil {
+		return nil, err
+	}
+
+	if task != nil {
+		*task = *rTask
+	}
+	return rTask, nil
 }
 
-func LRPStatePoller(logger lager.Logger, client bbs.InternalClient, processGuid string, lrp *models.ActualLRP) func() string {
-	return func() string {
-		var foundLRP *models.ActualLRP
+// LRPStatePoller polls the state of the first instance of an LRP, or "" if
+// it has no instances yet.
+func LRPStatePoller(logger lager.Logger, client bbs.InternalClient, processGuid string, lrp *models.ActualLRP) func() (string, error) {
+	return LRPStatePollerContext(context.Background(), logger, client, processGuid, lrp)
+}
 
-		lrps, err := client.ActualLRPs(logger, models.ActualLRPFilter{ProcessGuid: processGuid})
-		if err != nil && strings.Contains(err.Error(), "Invalid Response with status code: 404") {
-			lrpGroups, err := client.ActualLRPGroupsByProcessGuid(logger, processGuid)
-			Expect(err).NotTo(HaveOccurred())
-			if len(lrpGroups) == 0 {
-				return ""
-			}
-			foundLRP, _, err = lrpGroups[0].Resolve()
-			Expect(err).NotTo(HaveOccurred())
-		} else {
-			Expect(err).NotTo(HaveOccurred())
-			if len(lrps) == 0 {
-				return ""
-			}
-			foundLRP = lrps[0]
+func LRPStatePollerContext(ctx context.Context, logger lager.Logger, client bbs.InternalClient, processGuid string, lrp *models.ActualLRP) func() (string, error) {
+	return func() (string, error) {
+		lrps, err := actualLRPs(ctx, logger, client, models.ActualLRPFilter{ProcessGuid: processGuid})
+		if err != nil {
+			return "", err
+		}
+		if len(lrps) == 0 {
+			return "", nil
 		}
+
 		if lrp != nil {
-			*lrp = *foundLRP
+			*lrp = *lrps[0]
 		}
-		return foundLRP.State
+		return lrps[0].State, nil
 	}
 }
 
-func LRPInstanceStatePoller(logger lager.Logger, client bbs.InternalClient, processGuid string, index int, lrp *models.ActualLRP) func() string {
-	return func() string {
+func LRPInstanceStatePoller(logger lager.Logger, client bbs.InternalClient, processGuid string, index int, lrp *models.ActualLRP) func() (string, error) {
+	return LRPInstanceStatePollerContext(context.Background(), logger, client, processGuid, index, lrp)
+}
+
+func LRPInstanceStatePollerContext(ctx context.Context, logger lager.Logger, client bbs.InternalClient, processGuid string, index int, lrp *models.ActualLRP) func() (string, error) {
+	return func() (string, error) {
 		i := int32(index)
-		var foundLRP *models.ActualLRP
-		lrps, err := client.ActualLRPs(logger, models.ActualLRPFilter{ProcessGuid: processGuid, Index: &i})
-		if err != nil && strings.Contains(err.Error(), "Invalid Response with status code: 404") {
-			lrpGroup, err := client.ActualLRPGroupByProcessGuidAndIndex(logger, processGuid, index)
-			Expect(err).NotTo(HaveOccurred())
-			foundLRP, _, err = lrpGroup.Resolve()
-			Expect(err).NotTo(HaveOccurred())
-		} else {
-			Expect(err).NotTo(HaveOccurred())
-			Expect(lrps).To(HaveLen(1))
-			foundLRP = lrps[0]
+		lrps, err := actualLRPs(ctx, logger, client, models.ActualLRPFilter{ProcessGuid: processGuid, Index: &i})
+		if err != nil {
+			return "", err
+		}
+		if len(lrps) != 1 {
+			return "", fmt.Errorf("expected 1 instance of %s at index %d, found %d", processGuid, index, len(lrps))
 		}
+
 		if lrp != nil {
-			*lrp = *foundLRP
+			*lrp = *lrps[0]
+		}
+		return lrps[0].State, nil
+	}
+}
+
+// LRPStatesPoller polls the state of the first instance of each LRP at once,
+// keyed by process guid, e.g.
+//
+//	Eventually(helpers.LRPStatesPoller(ctx, logger, client, guid1, guid2)).Should(Equal(map[string]string{
+//		guid1: models.ActualLRPStateRunning,
+//		guid2: models.ActualLRPStateRunning,
+//	}))
+//
+// LRPs without instances have the state "".
+func LRPStatesPoller(ctx context.Context, logger lager.Logger, client bbs.InternalClient, processGuids ...string) func() (map[string]string, error) {
+	return func() (map[string]string, error) {
+		lrps, err := actualLRPs(ctx, logger, client, models.ActualLRPFilter{})
+		if err != nil {
+			return nil, err
+		}
+
+		states := make(map[string]string, len(processGuids))
+		for _, processGuid := range processGuids {
+			states[processGuid] = ""
+		}
+
+		for _, lrp := range lrps {
+			state, ok := states[lrp.ProcessGuid]
+			if ok && state == "" {
+				states[lrp.ProcessGuid] = lrp.State
+			}
+		}
+		return states, nil
+	}
+}
+
+// actualLRPsEndpointProber is implemented by BBS clients that can tell
+// whether their BBS serves the ActualLRPs endpoint, such as the
+// world.BBSClient returned by ComponentMaker.BBSClient.
+type actualLRPsEndpointProber interface {
+	HasActualLRPsEndpoint() (bool, error)
+}
+
+// actualLRPs lists ActualLRPs, resolving ActualLRPGroups instead on a BBS
+// that predates the ActualLRPs endpoint. Clients that can't tell are
+// assumed to talk to a BBS with the endpoint.
+func actualLRPs(ctx context.Context, logger lager.Logger, client bbs.InternalClient, filter models.ActualLRPFilter) ([]*models.ActualLRP, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if prober, ok := client.(actualLRPsEndpointProber); ok {
+		found, err := prober.HasActualLRPsEndpoint()
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return actualLRPsFromGroups(logger, client, filter)
+		}
+	}
+
+	return client.ActualLRPs(logger, filter)
+}
+
+func actualLRPsFromGroups(logger lager.Logger, client bbs.InternalClient, filter models.ActualLRPFilter) ([]*models.ActualLRP, error) {
+	groupFilter := models.ActualLRPGroupFilter{Domain: filter.Domain, CellID: filter.CellID}
+	var groups []*models.ActualLRPGroup
+	var err error
+	switch {
+	case filter.ProcessGuid != "" && filter.Index != nil:
+		var group *models.ActualLRPGroup
+		group, err = client.ActualLRPGroupByProcessGuidAndIndex(logger, filter.ProcessGuid, int(*filter.Index))
+		groups = []*models.ActualLRPGroup{group}
+	case filter.ProcessGuid != "":
+		groups, err = client.ActualLRPGroupsByProcessGuid(logger, filter.ProcessGuid)
+	default:
+		groups, err = client.ActualLRPGroups(logger, groupFilter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lrps := make([]*models.ActualLRP, 0, len(groups))
+	for _, group := range groups {
+		lrp, _, err := group.Resolve()
+		if err != nil {
+			return nil, err
 		}
-		return foundLRP.State
+		lrps = append(lrps, lrp)
 	}
+	return lrps, nil
 }
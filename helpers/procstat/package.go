@@ -0,0 +1 @@
+package procstat // import "code.cloudfoundry.org/inigo/helpers/procstat"
@@ -0,0 +1,118 @@
+package procstat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ClockTicksPerSecond is USER_HZ, which is 100 on every platform the suites
+// run on.
+const ClockTicksPerSecond = 100
+
+// Stat is the part of /proc/<pid>/stat that the helpers use. Times are in
+// clock ticks.
+type Stat struct {
+	Pid       int
+	PPid      int
+	State     string
+	UTime     uint64
+	STime     uint64
+	StartTime uint64
+}
+
+// CPUSeconds returns the user and system time of the process.
+func (s Stat) CPUSeconds() float64 {
+	return float64(s.UTime+s.STime) / ClockTicksPerSecond
+}
+
+// Read reads the stat of a running process.
+func Read(pid int) (Stat, error) {
+	path := filepath.Join("/proc", strconv.Itoa(pid), "stat")
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	stat, err := Parse(contents)
+	if err != nil {
+		return Stat{}, fmt.Errorf("malformed %s: %s", path, err)
+	}
+	return stat, nil
+}
+
+// All reads the stat of every running process. Processes that exit while
+// /proc is read are left out.
+func All() ([]Stat, error) {
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	stats := []Stat{}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		stat, err := Read(pid)
+		if err != nil {
+			continue
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// Parse parses the contents of a /proc/<pid>/stat file.
+func Parse(contents []byte) (Stat, error) {
+	// the command name is in parentheses and may contain spaces
+	stat := string(contents)
+	open := strings.Index(stat, " (")
+	end := strings.LastIndex(stat, ")")
+	if open < 0 || end < open {
+		return Stat{}, fmt.Errorf("no command name")
+	}
+
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 20 {
+		return Stat{}, fmt.Errorf("expected at least 22 fields, got %d", len(fields)+2)
+	}
+
+	pid, err := strconv.Atoi(stat[:open])
+	if err != nil {
+		return Stat{}, err
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return Stat{}, err
+	}
+
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	startTime, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return Stat{}, err
+	}
+
+	return Stat{
+		Pid:       pid,
+		PPid:      ppid,
+		State:     fields[0],
+		UTime:     utime,
+		STime:     stime,
+		StartTime: startTime,
+	}, nil
+}
@@ -0,0 +1,57 @@
+package procstat_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/inigo/helpers/procstat"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Parse", func() {
+	It("parses a command name with spaces and parentheses", func() {
+		stat, err := procstat.Parse([]byte("1234 (my (weird) cmd) S 1 1234 1234 0 -1 4194560 100 0 0 0 250 50 0 0 20 0 1 0 98765 1000000 200 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stat).To(Equal(procstat.Stat{
+			Pid:       1234,
+			PPid:      1,
+			State:     "S",
+			UTime:     250,
+			STime:     50,
+			StartTime: 98765,
+		}))
+		Expect(stat.CPUSeconds()).To(Equal(3.0))
+	})
+
+	It("fails on a truncated stat", func() {
+		_, err := procstat.Parse([]byte("1234 (cmd) S 1 1234"))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Read", func() {
+	It("reads the stat of the test process", func() {
+		stat, err := procstat.Read(os.Getpid())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stat.Pid).To(Equal(os.Getpid()))
+		Expect(stat.PPid).To(Equal(os.Getppid()))
+	})
+
+	It("fails for a process that does not exist", func() {
+		_, err := procstat.Read(-1)
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("All", func() {
+	It("includes the test process", func() {
+		stats, err := procstat.All()
+		Expect(err).NotTo(HaveOccurred())
+
+		pids := []int{}
+		for _, stat := range stats {
+			pids = append(pids, stat.Pid)
+		}
+		Expect(pids).To(ContainElement(os.Getpid()))
+	})
+})
@@ -1,12 +1,25 @@
 package helpers
 
 import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"sort"
 	"strings"
+	"time"
 
+	"code.cloudfoundry.org/inigo/helpers/routedistribution"
 	. "github.com/onsi/gomega"
 )
 
@@ -33,28 +46,12 @@ func ResponseCodeFromHostPoller(routerAddr string, host string, pathElements ...
 }
 
 func ResponseBodyAndStatusCodeFromHost(routerAddr string, host string, pathElements ...string) ([]byte, int, error) {
-	request := &http.Request{
-		URL: &url.URL{
-			Scheme: "http",
-			Host:   routerAddr,
-			Path:   "/" + strings.Join(pathElements, "/"),
-		},
-
-		Host: host,
-	}
-
-	response, err := http.DefaultClient.Do(request)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer response.Body.Close()
-
-	contents, err := ioutil.ReadAll(response.Body)
+	response, err := HTTPRouteResponse(routerAddr, host, RouteRequest{Path: "/" + strings.Join(pathElements, "/")})
 	if err != nil {
 		return nil, 0, err
 	}
 
-	return contents, response.StatusCode, nil
+	return response.Body, response.StatusCode, nil
 }
 
 func HelloWorldInstancePoller(routerAddr, host string) func() []string {
@@ -67,12 +64,12 @@ func HelloWorldInstancePoller(routerAddr, host string) func() []string {
 			}
 			if status == http.StatusNotFound {
 				//Ignore 404s as they are coming from the router, but make sure...
-				Expect(body).To(MatchRegexp(`Requested route \('.*'\) does not exist`), "Got a 404, but it wasn't from the router!")
+				Expect(body).To(MatchRegexp(routedistribution.RouterNotFoundBody.String()), "Got a 404, but it wasn't from the router!")
 				continue
 			}
 			if status == http.StatusBadGateway {
 				//Ignore 502s as they are coming from the router, but make sure...
-				Expect(body).To(ContainSubstring("Registered endpoint failed to handle the request"), "Got a 502, but it wasn't from the router!")
+				Expect(body).To(ContainSubstring(string(routedistribution.RouterBadGatewayBody)), "Got a 502, but it wasn't from the router!")
 				continue
 			}
 			respondingIndicesHash[string(body)] = true
@@ -85,3 +82,260 @@ func HelloWorldInstancePoller(routerAddr, host string) func() []string {
 		return respondingIndices
 	}
 }
+
+// RouteRequest is a request for an app, sent through the router.
+type RouteRequest struct {
+	// Method defaults to GET.
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+type RouteResponse struct {
+	StatusCode int
+	// Proto is the protocol the router responded with, e.g. "HTTP/2.0".
+	Proto  string
+	Header http.Header
+	Body   []byte
+}
+
+// HTTPRouteResponse sends a request for host to the router's HTTP port.
+func HTTPRouteResponse(routerAddr, host string, request RouteRequest) (*RouteResponse, error) {
+	return routeResponse(http.DefaultClient, "http", routerAddr, host, request)
+}
+
+// HTTPSRouteResponse sends a request for host to the router's TLS port,
+// trusting the router's certificate if it is signed by caCertFile.
+func HTTPSRouteResponse(routerTLSAddr, caCertFile, host string, request RouteRequest) (*RouteResponse, error) {
+	tlsConfig, err := RouterTLSConfig(caCertFile, host)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	defer transport.CloseIdleConnections()
+
+	return routeResponse(&http.Client{Transport: transport}, "https", routerTLSAddr, host, request)
+}
+
+// HTTP2RouteResponse is HTTPSRouteResponse negotiating HTTP/2. The router
+// answers with HTTP/1.1 unless it has HTTP/2 enabled, which the response's
+// Proto shows.
+func HTTP2RouteResponse(routerTLSAddr, caCertFile, host string, request RouteRequest) (*RouteResponse, error) {
+	tlsConfig, err := RouterTLSConfig(caCertFile, host)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true}
+	defer transport.CloseIdleConnections()
+
+	return routeResponse(&http.Client{Transport: transport}, "https", routerTLSAddr, host, request)
+}
+
+// RouterTLSConfig trusts certificates signed by caCertFile, e.g. the suite's
+// certificate authority, and asks the router for host's certificate.
+func RouterTLSConfig(caCertFile, host string) (*tls.Config, error) {
+	caCert, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	caCertPool := x509.NewCertPool()
+	if !caCertPool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("no certificates found in %s", caCertFile)
+	}
+
+	return &tls.Config{
+		RootCAs:    caCertPool,
+		ServerName: host,
+	}, nil
+}
+
+func routeResponse(client *http.Client, scheme, routerAddr, host string, request RouteRequest) (*RouteResponse, error) {
+	method := request.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	path := request.Path
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	httpRequest, err := http.NewRequest(method, fmt.Sprintf("%s://%s%s", scheme, routerAddr, path), bytes.NewReader(request.Body))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Host = host
+	for name, values := range request.Header {
+		httpRequest.Header[name] = values
+	}
+
+	response, err := client.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RouteResponse{
+		StatusCode: response.StatusCode,
+		Proto:      response.Proto,
+		Header:     response.Header,
+		Body:       body,
+	}, nil
+}
+
+// AppInstance is the instance of the go-server fixture that served a
+// request, as reported by its /instance endpoint.
+type AppInstance struct {
+	Index int    `json:"index"`
+	Guid  string `json:"guid"`
+}
+
+// RespondingInstance asks the go-server fixture routed at host which
+// instance is serving it.
+func RespondingInstance(routerAddr, host string) (AppInstance, error) {
+	response, err := HTTPRouteResponse(routerAddr, host, RouteRequest{Path: "/instance"})
+	if err != nil {
+		return AppInstance{}, err
+	}
+
+	return ParseAppInstance(response)
+}
+
+// ParseAppInstance reads the instance from a response of the go-server
+// fixture's /instance endpoint, however it was requested.
+func ParseAppInstance(response *RouteResponse) (AppInstance, error) {
+	if response.StatusCode != http.StatusOK {
+		return AppInstance{}, fmt.Errorf("unexpected status %d: %s", response.StatusCode, response.Body)
+	}
+
+	var instance AppInstance
+	err := json.Unmarshal(response.Body, &instance)
+	return instance, err
+}
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketThroughRouter upgrades a request for host to a WebSocket through
+// the router's HTTP port, and returns the upgraded connection. The go-server
+// fixture's /websocket endpoint echoes whatever is written to it.
+func WebSocketThroughRouter(routerAddr, host, path string) (net.Conn, error) {
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	conn, err := net.DialTimeout("tcp", routerAddr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodGet, "http://"+routerAddr+path, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	request.Host = host
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", key)
+
+	err = request.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("expected %d upgrading to a websocket, got %d", http.StatusSwitchingProtocols, response.StatusCode)
+	}
+
+	accept := sha1.Sum([]byte(key + webSocketGUID))
+	if response.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		conn.Close()
+		return nil, errors.New("websocket upgrade returned the wrong Sec-WebSocket-Accept")
+	}
+
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// bufferedConn reads what was buffered reading the upgrade response before
+// reading from the connection.
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// TCPRouteRequest writes payload to a TCP route, e.g. the TCP router's
+// external port for an app, and returns everything the app writes back until
+// it closes the connection.
+func TCPRouteRequest(tcpRouteAddr string, payload []byte) ([]byte, error) {
+	conn, err := net.DialTimeout("tcp", tcpRouteAddr, 10*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.Write(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(conn)
+}
+
+// TCPRouteInstance asks the go-server fixture behind a TCP route, e.g. the
+// TCP router's address for the route's external port, which instance is
+// serving it.
+func TCPRouteInstance(tcpRouteAddr string) (AppInstance, error) {
+	data, err := TCPRouteRequest(tcpRouteAddr, []byte("GET /instance HTTP/1.0\r\n\r\n"))
+	if err != nil {
+		return AppInstance{}, err
+	}
+
+	response, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return AppInstance{}, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return AppInstance{}, err
+	}
+
+	return ParseAppInstance(&RouteResponse{
+		StatusCode: response.StatusCode,
+		Proto:      response.Proto,
+		Header:     response.Header,
+		Body:       body,
+	})
+}
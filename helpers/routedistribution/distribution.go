@@ -0,0 +1,226 @@
+package routedistribution
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+)
+
+// maxConcurrentRequests bounds the requests Measure has in flight at once.
+const maxConcurrentRequests = 10
+
+var (
+	// RouterNotFoundBody matches the body of the router's 404 for a route it
+	// does not know.
+	RouterNotFoundBody = regexp.MustCompile(`Requested route \('.*'\) does not exist`)
+	// RouterBadGatewayBody is in the body of the router's 502 for an
+	// endpoint that failed.
+	RouterBadGatewayBody = []byte("Registered endpoint failed to handle the request")
+)
+
+// Distribution records how the router spread requests for a route across the
+// instances of an app.
+type Distribution struct {
+	Requests int
+	// Instances counts the successful responses of each instance, keyed by
+	// the index it responded with.
+	Instances map[string]int
+	// StatusCodes counts the responses by status code, including the
+	// router's own.
+	StatusCodes map[int]int
+	// RouterErrors counts the 404s for unknown routes and the 502s for
+	// failed endpoints that came from the router rather than the app.
+	RouterErrors int
+	// Errors are the requests that got no response at all.
+	Errors    []error
+	Latencies []time.Duration
+}
+
+// Measure sends n requests for host's index endpoint through the router,
+// at most maxConcurrentRequests at a time, and records which instance
+// answered each of them.
+func Measure(routerAddr, host string, n int) *Distribution {
+	distribution := &Distribution{
+		Requests:    n,
+		Instances:   map[string]int{},
+		StatusCodes: map[int]int{},
+	}
+
+	transport := &http.Transport{MaxIdleConnsPerHost: maxConcurrentRequests}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
+
+	lock := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	slots := make(chan struct{}, maxConcurrentRequests)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			start := time.Now()
+			body, status, err := get(client, routerAddr, host)
+			latency := time.Since(start)
+
+			lock.Lock()
+			defer lock.Unlock()
+			distribution.record(body, status, latency, err)
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(distribution.Latencies, func(i, j int) bool {
+		return distribution.Latencies[i] < distribution.Latencies[j]
+	})
+	return distribution
+}
+
+func get(client *http.Client, routerAddr, host string) ([]byte, int, error) {
+	request, err := http.NewRequest(http.MethodGet, "http://"+routerAddr+"/", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	request.Host = host
+
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return body, response.StatusCode, nil
+}
+
+func (d *Distribution) record(body []byte, status int, latency time.Duration, err error) {
+	if err != nil {
+		d.Errors = append(d.Errors, err)
+		return
+	}
+
+	d.StatusCodes[status]++
+	d.Latencies = append(d.Latencies, latency)
+
+	switch {
+	case status == http.StatusNotFound && RouterNotFoundBody.Match(body):
+		d.RouterErrors++
+	case status == http.StatusBadGateway && bytes.Contains(body, RouterBadGatewayBody):
+		d.RouterErrors++
+	case status == http.StatusOK:
+		d.Instances[string(body)]++
+	}
+}
+
+// LatencyPercentile returns the latency p percent of the responses were
+// at least as fast as, e.g. 50 for the median.
+func (d *Distribution) LatencyPercentile(p float64) time.Duration {
+	if len(d.Latencies) == 0 {
+		return 0
+	}
+
+	i := int(math.Ceil(p/100*float64(len(d.Latencies)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return d.Latencies[i]
+}
+
+func (d *Distribution) String() string {
+	indices := []string{}
+	for index := range d.Instances {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "%d requests:\n", d.Requests)
+	for _, index := range indices {
+		fmt.Fprintf(buffer, "  instance %s: %d\n", index, d.Instances[index])
+	}
+	fmt.Fprintf(buffer, "  router errors: %d\n", d.RouterErrors)
+	fmt.Fprintf(buffer, "  failed requests: %d\n", len(d.Errors))
+	fmt.Fprintf(buffer, "  status codes: %v\n", d.StatusCodes)
+	fmt.Fprintf(buffer, "  latency p50=%s p99=%s\n", d.LatencyPercentile(50), d.LatencyPercentile(99))
+	return buffer.String()
+}
+
+// BeRoughlyBalanced succeeds if each of the app's instances, indexed 0 to
+// instances-1, served within tolerance, a fraction such as 0.2, of the mean
+// number of requests per instance, and no other instance served any. An
+// instance that never responded fails the match. Both the round-robin and
+// least-connection router balancing algorithms should pass with a tolerance
+// that allows for concurrent requests overlapping. Router errors and failed
+// requests are not counted; check them separately, e.g.
+// Expect(distribution.RouterErrors).To(BeZero()).
+func BeRoughlyBalanced(instances int, tolerance float64) gomega.OmegaMatcher {
+	return &RoughlyBalancedMatcher{Instances: instances, Tolerance: tolerance}
+}
+
+type RoughlyBalancedMatcher struct {
+	Instances int
+	Tolerance float64
+
+	min, max   float64
+	unbalanced []string
+}
+
+func (matcher *RoughlyBalancedMatcher) Match(actual interface{}) (success bool, err error) {
+	distribution, ok := actual.(*Distribution)
+	if !ok {
+		return false, fmt.Errorf("BeRoughlyBalanced expects a *routedistribution.Distribution, got\n%s", format.Object(actual, 1))
+	}
+	if matcher.Instances <= 0 {
+		return false, fmt.Errorf("BeRoughlyBalanced expects at least one instance, got %d", matcher.Instances)
+	}
+
+	total := 0
+	for _, count := range distribution.Instances {
+		total += count
+	}
+	mean := float64(total) / float64(matcher.Instances)
+	matcher.min = mean * (1 - matcher.Tolerance)
+	matcher.max = mean * (1 + matcher.Tolerance)
+
+	expected := map[string]bool{}
+	matcher.unbalanced = []string{}
+	for i := 0; i < matcher.Instances; i++ {
+		index := strconv.Itoa(i)
+		expected[index] = true
+
+		count := float64(distribution.Instances[index])
+		if count < matcher.min || count > matcher.max {
+			matcher.unbalanced = append(matcher.unbalanced, index)
+		}
+	}
+	for index := range distribution.Instances {
+		if !expected[index] {
+			matcher.unbalanced = append(matcher.unbalanced, index)
+		}
+	}
+	sort.Strings(matcher.unbalanced)
+
+	return len(matcher.unbalanced) == 0, nil
+}
+
+func (matcher *RoughlyBalancedMatcher) FailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected\n%s\nto be balanced across %d instances with\n  %.1f-%.1f requests per instance\nbut instances %v are not", actual, matcher.Instances, matcher.min, matcher.max, matcher.unbalanced)
+}
+
+func (matcher *RoughlyBalancedMatcher) NegatedFailureMessage(actual interface{}) (message string) {
+	return fmt.Sprintf("Expected\n%s\nnot to be balanced across %d instances with\n  %.1f-%.1f requests per instance", actual, matcher.Instances, matcher.min, matcher.max)
+}
@@ -0,0 +1,129 @@
+package routedistribution_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/routedistribution"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Measure", func() {
+	var (
+		router   *httptest.Server
+		lock     sync.Mutex
+		inFlight int
+		peak     int
+	)
+
+	BeforeEach(func() {
+		inFlight, peak = 0, 0
+		requests := 0
+
+		router = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			inFlight++
+			if inFlight > peak {
+				peak = inFlight
+			}
+			index := requests % 3
+			requests++
+			lock.Unlock()
+
+			defer func() {
+				lock.Lock()
+				inFlight--
+				lock.Unlock()
+			}()
+
+			time.Sleep(5 * time.Millisecond)
+			switch {
+			case r.Host != "app.example.com":
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprintf(w, "Requested route ('%s') does not exist.", r.Host)
+			default:
+				fmt.Fprint(w, index)
+			}
+		}))
+	})
+
+	AfterEach(func() {
+		router.Close()
+	})
+
+	It("records which instance answered each request", func() {
+		distribution := routedistribution.Measure(strings.TrimPrefix(router.URL, "http://"), "app.example.com", 60)
+
+		Expect(distribution.Requests).To(Equal(60))
+		Expect(distribution.Errors).To(BeEmpty())
+		Expect(distribution.Instances).To(Equal(map[string]int{"0": 20, "1": 20, "2": 20}))
+		Expect(distribution.StatusCodes).To(Equal(map[int]int{http.StatusOK: 60}))
+		Expect(distribution.Latencies).To(HaveLen(60))
+		Expect(distribution.LatencyPercentile(50)).To(BeNumerically("<=", distribution.LatencyPercentile(99)))
+	})
+
+	It("bounds the requests in flight", func() {
+		routedistribution.Measure(strings.TrimPrefix(router.URL, "http://"), "app.example.com", 50)
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(peak).To(BeNumerically("<=", 10))
+	})
+
+	It("counts the router's 404s separately", func() {
+		distribution := routedistribution.Measure(strings.TrimPrefix(router.URL, "http://"), "unknown.example.com", 5)
+
+		Expect(distribution.RouterErrors).To(Equal(5))
+		Expect(distribution.Instances).To(BeEmpty())
+		Expect(distribution.StatusCodes).To(Equal(map[int]int{http.StatusNotFound: 5}))
+	})
+})
+
+var _ = Describe("BeRoughlyBalanced", func() {
+	distribution := func(instances map[string]int) *routedistribution.Distribution {
+		return &routedistribution.Distribution{Instances: instances}
+	}
+
+	It("matches instances within the tolerance of the mean", func() {
+		Expect(distribution(map[string]int{"0": 9, "1": 10, "2": 11})).To(routedistribution.BeRoughlyBalanced(3, 0.1))
+	})
+
+	It("matches instances exactly at the tolerance boundary", func() {
+		Expect(distribution(map[string]int{"0": 6, "1": 8, "2": 10})).To(routedistribution.BeRoughlyBalanced(3, 0.25))
+		Expect(distribution(map[string]int{"0": 6, "1": 8, "2": 10})).NotTo(routedistribution.BeRoughlyBalanced(3, 0.24))
+	})
+
+	It("reports an instance that never responded", func() {
+		actual := distribution(map[string]int{"0": 15, "1": 15})
+
+		matcher := routedistribution.BeRoughlyBalanced(3, 0.5)
+		Expect(matcher.Match(actual)).To(BeFalse())
+		Expect(matcher.FailureMessage(actual)).To(ContainSubstring("to be balanced across 3 instances with\n  5.0-15.0 requests per instance\nbut instances [2] are not"))
+	})
+
+	It("reports an instance that should not exist", func() {
+		actual := distribution(map[string]int{"0": 10, "1": 10, "3": 1})
+
+		matcher := routedistribution.BeRoughlyBalanced(2, 0.5)
+		Expect(matcher.Match(actual)).To(BeFalse())
+		Expect(matcher.FailureMessage(actual)).To(ContainSubstring("but instances [3] are not"))
+	})
+
+	It("reports the expected range when negated", func() {
+		actual := distribution(map[string]int{"0": 10, "1": 10})
+
+		matcher := routedistribution.BeRoughlyBalanced(2, 0.5)
+		Expect(matcher.Match(actual)).To(BeTrue())
+		Expect(matcher.NegatedFailureMessage(actual)).To(ContainSubstring("not to be balanced across 2 instances with\n  5.0-15.0 requests per instance"))
+	})
+
+	It("fails on anything but a distribution", func() {
+		_, err := routedistribution.BeRoughlyBalanced(2, 0.5).Match(map[string]int{})
+		Expect(err).To(HaveOccurred())
+	})
+})
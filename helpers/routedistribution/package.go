@@ -0,0 +1 @@
+package routedistribution // import "code.cloudfoundry.org/inigo/helpers/routedistribution"
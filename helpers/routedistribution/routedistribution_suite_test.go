@@ -0,0 +1,13 @@
+package routedistribution_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestRoutedistribution(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Routedistribution Suite")
+}
@@ -0,0 +1 @@
+package routeservice // import "code.cloudfoundry.org/inigo/helpers/routeservice"
@@ -0,0 +1,227 @@
+package routeservice
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+)
+
+const (
+	ForwardedURLHeader = "X-CF-Forwarded-Url"
+	SignatureHeader    = "X-CF-Proxy-Signature"
+	MetadataHeader     = "X-CF-Proxy-Metadata"
+)
+
+// Request is a request the router sent to the route service.
+type Request struct {
+	Method       string
+	ForwardedURL string
+	Signature    string
+	Metadata     string
+	Header       http.Header
+	Body         []byte
+	// StatusCode is the status the route service responded to the router
+	// with.
+	StatusCode int
+}
+
+// Server is a stand-in for a route service. It validates the headers the
+// router adds to requests for routes bound to it, records the requests, and
+// sends them back through the router to the app unless it is told to reject
+// them.
+type Server struct {
+	address    string
+	routerAddr string
+	tlsConfig  *tls.Config
+	client     *http.Client
+
+	lock         sync.Mutex
+	requests     []Request
+	modify       func(*http.Request)
+	rejectStatus int
+}
+
+// New creates a route service listening with TLS on address, e.g. for a
+// route_service_url of URL(). It forwards requests to the router's HTTP
+// listener at routerAddr.
+func New(address, routerAddr, serverCert, serverKey string) (*Server, error) {
+	cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		address:    address,
+		routerAddr: routerAddr,
+		tlsConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		},
+		client: &http.Client{
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}, nil
+}
+
+// URL is the route service URL to bind routes to.
+func (s *Server) URL() string {
+	return "https://" + s.address
+}
+
+func (s *Server) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: s}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Serve(tls.NewListener(listener, s.tlsConfig))
+	}()
+
+	close(ready)
+
+	select {
+	case <-signals:
+		return server.Close()
+	case err := <-errCh:
+		return err
+	}
+}
+
+// ModifyRequests changes the requests before they are sent back to the
+// router, e.g. to add a header the app can check for. nil stops modifying
+// them.
+func (s *Server) ModifyRequests(modify func(*http.Request)) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.modify = modify
+}
+
+// RejectRequests responds to requests with statusCode instead of sending
+// them on to the app. 0 sends them on again.
+func (s *Server) RejectRequests(statusCode int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.rejectStatus = statusCode
+}
+
+// Requests returns every request received so far, in the order they were
+// received.
+func (s *Server) Requests() []Request {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	requests := make([]Request, len(s.requests))
+	copy(requests, s.requests)
+	return requests
+}
+
+// Reset discards every request received so far.
+func (s *Server) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.requests = nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	request := Request{
+		Method:       r.Method,
+		ForwardedURL: r.Header.Get(ForwardedURLHeader),
+		Signature:    r.Header.Get(SignatureHeader),
+		Metadata:     r.Header.Get(MetadataHeader),
+		Header:       r.Header.Clone(),
+		Body:         body,
+	}
+
+	s.lock.Lock()
+	modify := s.modify
+	rejectStatus := s.rejectStatus
+	s.lock.Unlock()
+
+	request.StatusCode = s.respond(w, r, body, request, modify, rejectStatus)
+
+	s.lock.Lock()
+	s.requests = append(s.requests, request)
+	s.lock.Unlock()
+}
+
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, body []byte, request Request, modify func(*http.Request), rejectStatus int) int {
+	forwardedURL, err := validate(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return http.StatusBadRequest
+	}
+
+	if rejectStatus != 0 {
+		http.Error(w, "rejected by the route service", rejectStatus)
+		return rejectStatus
+	}
+
+	outgoing, err := http.NewRequest(r.Method, fmt.Sprintf("http://%s%s", s.routerAddr, forwardedURL.RequestURI()), bytes.NewReader(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	outgoing.Host = forwardedURL.Host
+	for name, values := range r.Header {
+		outgoing.Header[name] = values
+	}
+
+	if modify != nil {
+		modify(outgoing)
+	}
+
+	response, err := s.client.Do(outgoing)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway
+	}
+	defer response.Body.Close()
+
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+	return response.StatusCode
+}
+
+// validate checks that the router sent the forwarded URL, signature and
+// metadata headers. Only the router can check the signature itself, when the
+// request comes back to it.
+func validate(request Request) (*url.URL, error) {
+	if request.ForwardedURL == "" {
+		return nil, fmt.Errorf("missing %s header", ForwardedURLHeader)
+	}
+	if request.Signature == "" {
+		return nil, fmt.Errorf("missing %s header", SignatureHeader)
+	}
+	if request.Metadata == "" {
+		return nil, fmt.Errorf("missing %s header", MetadataHeader)
+	}
+
+	forwardedURL, err := url.Parse(request.ForwardedURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s header: %s", ForwardedURLHeader, err)
+	}
+	if forwardedURL.Host == "" {
+		return nil, fmt.Errorf("invalid %s header: no host in %q", ForwardedURLHeader, request.ForwardedURL)
+	}
+	return forwardedURL, nil
+}
@@ -0,0 +1,136 @@
+package routeservice_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/inigo/helpers/routeservice"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server", func() {
+	var (
+		router         *httptest.Server
+		routerRequests chan *http.Request
+		server         *routeservice.Server
+	)
+
+	BeforeEach(func() {
+		routerRequests = make(chan *http.Request, 1)
+		router = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			routerRequests <- r
+			w.Header().Set("X-App", "yes")
+			w.WriteHeader(http.StatusTeapot)
+			w.Write(append([]byte("app got "), body...))
+		}))
+
+		certs := filepath.Join("..", "..", "fixtures", "certs", "metron")
+
+		var err error
+		server, err = routeservice.New(
+			"127.0.0.1:0",
+			strings.TrimPrefix(router.URL, "http://"),
+			filepath.Join(certs, "metron.crt"),
+			filepath.Join(certs, "metron.key"),
+		)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		router.Close()
+	})
+
+	send := func(header http.Header) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("POST", server.URL()+"/", strings.NewReader("hello"))
+		for name, values := range header {
+			for _, value := range values {
+				request.Header.Add(name, value)
+			}
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	routerHeaders := func() http.Header {
+		header := http.Header{}
+		header.Set(routeservice.ForwardedURLHeader, "https://app.example.com/some/path?q=1")
+		header.Set(routeservice.SignatureHeader, "signature")
+		header.Set(routeservice.MetadataHeader, "metadata")
+		return header
+	}
+
+	It("sends requests back through the router to the forwarded URL", func() {
+		response := send(routerHeaders())
+		Expect(response.Code).To(Equal(http.StatusTeapot))
+		Expect(response.Header().Get("X-App")).To(Equal("yes"))
+		Expect(response.Body.String()).To(Equal("app got hello"))
+
+		var forwarded *http.Request
+		Expect(routerRequests).To(Receive(&forwarded))
+		Expect(forwarded.Method).To(Equal("POST"))
+		Expect(forwarded.Host).To(Equal("app.example.com"))
+		Expect(forwarded.URL.RequestURI()).To(Equal("/some/path?q=1"))
+		Expect(forwarded.Header.Get(routeservice.SignatureHeader)).To(Equal("signature"))
+		Expect(forwarded.Header.Get(routeservice.MetadataHeader)).To(Equal("metadata"))
+	})
+
+	It("records the requests", func() {
+		send(routerHeaders())
+
+		requests := server.Requests()
+		Expect(requests).To(HaveLen(1))
+		Expect(requests[0].Method).To(Equal("POST"))
+		Expect(requests[0].ForwardedURL).To(Equal("https://app.example.com/some/path?q=1"))
+		Expect(requests[0].Signature).To(Equal("signature"))
+		Expect(requests[0].Metadata).To(Equal("metadata"))
+		Expect(requests[0].Body).To(Equal([]byte("hello")))
+		Expect(requests[0].StatusCode).To(Equal(http.StatusTeapot))
+
+		server.Reset()
+		Expect(server.Requests()).To(BeEmpty())
+	})
+
+	It("modifies the requests before sending them back", func() {
+		server.ModifyRequests(func(r *http.Request) {
+			r.Header.Set("X-Route-Service", "was-here")
+		})
+		send(routerHeaders())
+
+		var forwarded *http.Request
+		Expect(routerRequests).To(Receive(&forwarded))
+		Expect(forwarded.Header.Get("X-Route-Service")).To(Equal("was-here"))
+		Expect(server.Requests()[0].Header.Get("X-Route-Service")).To(BeEmpty())
+	})
+
+	It("rejects the requests when told to", func() {
+		server.RejectRequests(http.StatusForbidden)
+		response := send(routerHeaders())
+		Expect(response.Code).To(Equal(http.StatusForbidden))
+		Expect(routerRequests).NotTo(Receive())
+		Expect(server.Requests()[0].StatusCode).To(Equal(http.StatusForbidden))
+
+		server.RejectRequests(0)
+		Expect(send(routerHeaders()).Code).To(Equal(http.StatusTeapot))
+	})
+
+	for _, header := range []string{routeservice.ForwardedURLHeader, routeservice.SignatureHeader, routeservice.MetadataHeader} {
+		header := header
+
+		It("responds with 400 when the router does not send "+header, func() {
+			headers := routerHeaders()
+			headers.Del(header)
+
+			response := send(headers)
+			Expect(response.Code).To(Equal(http.StatusBadRequest))
+			Expect(response.Body.String()).To(ContainSubstring(header))
+			Expect(routerRequests).NotTo(Receive())
+			Expect(server.Requests()[0].StatusCode).To(Equal(http.StatusBadRequest))
+		})
+	}
+})
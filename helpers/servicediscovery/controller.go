@@ -0,0 +1,278 @@
+package servicediscovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	RegisterSubject   = "service-discovery.register"
+	UnregisterSubject = "service-discovery.unregister"
+
+	// Domain is the domain the internal routes of apps are registered under.
+	Domain = "apps.internal"
+)
+
+// RegistryMessage is a register or unregister message the route emitter
+// publishes, both for the router and for service discovery. Host and Port
+// are the address of the backend; URIs are the hostnames routed to it.
+type RegistryMessage struct {
+	Host                    string            `json:"host"`
+	Port                    uint32            `json:"port"`
+	TLSPort                 uint32            `json:"tls_port,omitempty"`
+	URIs                    []string          `json:"uris"`
+	App                     string            `json:"app,omitempty"`
+	RouteServiceURL         string            `json:"route_service_url,omitempty"`
+	PrivateInstanceId       string            `json:"private_instance_id,omitempty"`
+	PrivateInstanceIndex    string            `json:"private_instance_index,omitempty"`
+	ServerCertDomainSAN     string            `json:"server_cert_domain_san,omitempty"`
+	IsolationSegment        string            `json:"isolation_segment,omitempty"`
+	StaleThresholdInSeconds int               `json:"stale_threshold_in_seconds,omitempty"`
+	EndpointUpdatedAtNs     int64             `json:"endpoint_updated_at_ns,omitempty"`
+	Tags                    map[string]string `json:"tags,omitempty"`
+}
+
+// Instance is an app instance registered for an internal hostname.
+type Instance struct {
+	IP    string
+	Index string
+	App   string
+}
+
+// Controller is a stand-in for the service discovery controller and the
+// bosh-dns adapter in front of it. It records the internal routes the route
+// emitter registers over NATS and answers DNS queries for them, e.g. A
+// queries for app.apps.internal with the container IPs of every instance and
+// for 0.app.apps.internal with the IP of instance 0.
+//
+// It listens on the host, so containers cannot resolve through it: garden
+// hands containers its DNS servers when it starts and denies them any
+// network access. Tests query it with Lookup instead.
+type Controller struct {
+	natsAddress string
+	dnsAddress  string
+
+	lock  sync.Mutex
+	hosts map[string]map[string]Instance
+}
+
+// New creates a controller that subscribes to the NATS server at natsAddress
+// and serves DNS over UDP on dnsAddress.
+func New(natsAddress, dnsAddress string) *Controller {
+	return &Controller{
+		natsAddress: natsAddress,
+		dnsAddress:  dnsAddress,
+		hosts:       map[string]map[string]Instance{},
+	}
+}
+
+func (c *Controller) DNSAddress() string {
+	return c.dnsAddress
+}
+
+func (c *Controller) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	conn, err := nats.Connect("nats://"+c.natsAddress, nats.MaxReconnects(-1))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	for subject, handle := range map[string]func(RegistryMessage){
+		RegisterSubject:   c.Register,
+		UnregisterSubject: c.Unregister,
+	} {
+		handle := handle
+		_, err := conn.Subscribe(subject, func(msg *nats.Msg) {
+			message := RegistryMessage{}
+			if err := json.Unmarshal(msg.Data, &message); err != nil {
+				return
+			}
+			handle(message)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+
+	packetConn, err := net.ListenPacket("udp", c.dnsAddress)
+	if err != nil {
+		return err
+	}
+
+	server := &dns.Server{PacketConn: packetConn, Handler: c}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ActivateAndServe()
+	}()
+
+	close(ready)
+
+	select {
+	case <-signals:
+		return server.Shutdown()
+	case err := <-errCh:
+		return err
+	}
+}
+
+// Register adds the instance in message to each of its hostnames, as if the
+// route emitter had published it.
+func (c *Controller) Register(message RegistryMessage) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, uri := range message.URIs {
+		hostname := normalize(uri)
+		if c.hosts[hostname] == nil {
+			c.hosts[hostname] = map[string]Instance{}
+		}
+		c.hosts[hostname][instanceKey(message)] = Instance{
+			IP:    message.Host,
+			Index: message.PrivateInstanceIndex,
+			App:   message.App,
+		}
+	}
+}
+
+// Unregister removes the instance in message from each of its hostnames.
+func (c *Controller) Unregister(message RegistryMessage) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, uri := range message.URIs {
+		hostname := normalize(uri)
+		delete(c.hosts[hostname], instanceKey(message))
+		if len(c.hosts[hostname]) == 0 {
+			delete(c.hosts, hostname)
+		}
+	}
+}
+
+// Instances returns the instances registered for hostname, ordered by IP.
+func (c *Controller) Instances(hostname string) []Instance {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	instances := []Instance{}
+	for _, instance := range c.hosts[normalize(hostname)] {
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].IP < instances[j].IP
+	})
+	return instances
+}
+
+// Reset forgets every registered instance.
+func (c *Controller) Reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.hosts = map[string]map[string]Instance{}
+}
+
+func (c *Controller) ServeDNS(w dns.ResponseWriter, request *dns.Msg) {
+	response := &dns.Msg{}
+	response.SetReply(request)
+	response.Authoritative = true
+
+	for _, question := range request.Question {
+		name := normalize(question.Name)
+		if !strings.HasSuffix(name, "."+Domain) {
+			response.Rcode = dns.RcodeRefused
+			break
+		}
+
+		ips, found := c.resolve(name)
+		if !found {
+			response.Rcode = dns.RcodeNameError
+			break
+		}
+
+		if question.Qtype != dns.TypeA && question.Qtype != dns.TypeANY {
+			continue
+		}
+		for _, ip := range ips {
+			response.Answer = append(response.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 0},
+				A:   net.ParseIP(ip),
+			})
+		}
+	}
+
+	w.WriteMsg(response)
+}
+
+// resolve finds the IPs for hostname, or for a single instance of it when
+// name is <index>.<hostname>.
+func (c *Controller) resolve(name string) ([]string, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if instances, ok := c.hosts[name]; ok {
+		ips := []string{}
+		for _, instance := range instances {
+			ips = append(ips, instance.IP)
+		}
+		sort.Strings(ips)
+		return ips, true
+	}
+
+	parts := strings.SplitN(name, ".", 2)
+	if len(parts) != 2 {
+		return nil, false
+	}
+	for _, instance := range c.hosts[parts[1]] {
+		if instance.Index == parts[0] {
+			return []string{instance.IP}, true
+		}
+	}
+	return nil, false
+}
+
+// Lookup resolves hostname, e.g. app.apps.internal or 0.app.apps.internal,
+// with the controller serving DNS on dnsAddress.
+func Lookup(dnsAddress, hostname string) ([]string, error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialer := net.Dialer{}
+			return dialer.DialContext(ctx, "udp", dnsAddress)
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ips, err := resolver.LookupHost(ctx, strings.TrimSuffix(hostname, ".")+".")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ips)
+	return ips, nil
+}
+
+func normalize(hostname string) string {
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
+
+// instanceKey identifies an instance across register and unregister
+// messages. Messages without an instance guid are keyed by IP.
+func instanceKey(message RegistryMessage) string {
+	if message.PrivateInstanceId != "" {
+		return message.PrivateInstanceId
+	}
+	return fmt.Sprintf("ip:%s", message.Host)
+}
@@ -0,0 +1,92 @@
+package servicediscovery_test
+
+import (
+	"net"
+
+	"code.cloudfoundry.org/inigo/helpers/servicediscovery"
+	"github.com/miekg/dns"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Controller", func() {
+	var (
+		controller *servicediscovery.Controller
+		server     *dns.Server
+		dnsAddress string
+	)
+
+	BeforeEach(func() {
+		packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		dnsAddress = packetConn.LocalAddr().String()
+
+		controller = servicediscovery.New("127.0.0.1:4222", dnsAddress)
+
+		started := make(chan struct{})
+		server = &dns.Server{PacketConn: packetConn, Handler: controller, NotifyStartedFunc: func() { close(started) }}
+		go server.ActivateAndServe()
+		Eventually(started).Should(BeClosed())
+
+		controller.Register(servicediscovery.RegistryMessage{
+			Host:                 "10.0.0.1",
+			URIs:                 []string{"app.apps.internal", "other.apps.internal"},
+			PrivateInstanceId:    "instance-0",
+			PrivateInstanceIndex: "0",
+		})
+		controller.Register(servicediscovery.RegistryMessage{
+			Host:                 "10.0.0.2",
+			URIs:                 []string{"app.apps.internal"},
+			PrivateInstanceId:    "instance-1",
+			PrivateInstanceIndex: "1",
+		})
+	})
+
+	AfterEach(func() {
+		Expect(server.Shutdown()).To(Succeed())
+	})
+
+	It("resolves a hostname to the IPs of all of its instances", func() {
+		Expect(servicediscovery.Lookup(dnsAddress, "app.apps.internal")).To(Equal([]string{"10.0.0.1", "10.0.0.2"}))
+		Expect(servicediscovery.Lookup(dnsAddress, "other.apps.internal")).To(Equal([]string{"10.0.0.1"}))
+	})
+
+	It("resolves an instance index of a hostname to the IP of that instance", func() {
+		Expect(servicediscovery.Lookup(dnsAddress, "1.app.apps.internal")).To(Equal([]string{"10.0.0.2"}))
+	})
+
+	It("does not resolve unknown hostnames", func() {
+		_, err := servicediscovery.Lookup(dnsAddress, "unknown.apps.internal")
+		Expect(err).To(HaveOccurred())
+
+		_, err = servicediscovery.Lookup(dnsAddress, "2.app.apps.internal")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("refuses hostnames outside apps.internal", func() {
+		_, err := servicediscovery.Lookup(dnsAddress, "example.com")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("stops resolving unregistered instances", func() {
+		controller.Unregister(servicediscovery.RegistryMessage{
+			Host:              "10.0.0.1",
+			URIs:              []string{"app.apps.internal", "other.apps.internal"},
+			PrivateInstanceId: "instance-0",
+		})
+
+		Expect(servicediscovery.Lookup(dnsAddress, "app.apps.internal")).To(Equal([]string{"10.0.0.2"}))
+		_, err := servicediscovery.Lookup(dnsAddress, "other.apps.internal")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("lists the registered instances", func() {
+		Expect(controller.Instances("app.apps.internal")).To(Equal([]servicediscovery.Instance{
+			{IP: "10.0.0.1", Index: "0"},
+			{IP: "10.0.0.2", Index: "1"},
+		}))
+
+		controller.Reset()
+		Expect(controller.Instances("app.apps.internal")).To(BeEmpty())
+	})
+})
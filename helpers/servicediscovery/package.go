@@ -0,0 +1 @@
+package servicediscovery // import "code.cloudfoundry.org/inigo/helpers/servicediscovery"
@@ -0,0 +1,13 @@
+package servicediscovery_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestServicediscovery(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Servicediscovery Suite")
+}
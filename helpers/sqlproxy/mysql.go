@@ -0,0 +1,90 @@
+package sqlproxy
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+)
+
+const (
+	mysqlComQuery       = 0x03
+	mysqlComStmtPrepare = 0x16
+	mysqlErrPacket      = 0xff
+)
+
+// proxyMySQL forwards client packets one at a time. Every command starts a
+// new sequence at 0, which distinguishes commands from the handshake and
+// authentication packets exchanged when the connection is opened.
+func (p *Proxy) proxyMySQL(client, server net.Conn) {
+	clientWriter := &lockedWriter{w: client}
+
+	go func() {
+		io.Copy(clientWriter, server)
+		client.Close()
+	}()
+
+	for {
+		header, payload, err := readMySQLPacket(client)
+		if err != nil {
+			server.Close()
+			return
+		}
+
+		sequence := header[3]
+		if sequence == 0 && len(payload) > 0 && (payload[0] == mysqlComQuery || payload[0] == mysqlComStmtPrepare) {
+			if fault, ok := p.faultFor(string(payload[1:])); ok {
+				if forward, reply := applyFault(fault, client, server); !forward {
+					if !reply {
+						return
+					}
+					_, err := clientWriter.Write(mysqlErrorPacket(sequence+1, fault.Error))
+					if err != nil {
+						return
+					}
+					continue
+				}
+			}
+		}
+
+		_, err = server.Write(append(header, payload...))
+		if err != nil {
+			return
+		}
+	}
+}
+
+func readMySQLPacket(r io.Reader) ([]byte, []byte, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return header, payload, nil
+}
+
+func mysqlErrorPacket(sequence byte, e *Error) []byte {
+	payload := []byte{mysqlErrPacket, 0, 0}
+	binary.LittleEndian.PutUint16(payload[1:], e.Code)
+	payload = append(payload, '#')
+	payload = append(payload, sqlState(e.SQLState, "HY000")...)
+	payload = append(payload, e.Message...)
+
+	length := len(payload)
+	header := []byte{byte(length), byte(length >> 8), byte(length >> 16), sequence}
+	return append(header, payload...)
+}
+
+func sqlState(state, defaultState string) string {
+	if len(state) != 5 {
+		return defaultState
+	}
+	return state
+}
@@ -0,0 +1 @@
+package sqlproxy // import "code.cloudfoundry.org/inigo/helpers/sqlproxy"
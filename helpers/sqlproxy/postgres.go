@@ -0,0 +1,229 @@
+package sqlproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync/atomic"
+)
+
+const (
+	postgresSSLRequestCode    = 80877103
+	postgresGSSENCRequestCode = 80877104
+
+	postgresQuery          = 'Q'
+	postgresParse          = 'P'
+	postgresSync           = 'S'
+	postgresErrorResponse  = 'E'
+	postgresReadyForQuery  = 'Z'
+	postgresStatusIdle     = 'I'
+	postgresStatusInTx     = 'T'
+	postgresStatusFailedTx = 'E'
+)
+
+// proxyPostgres forwards the startup message unchanged, declining any request
+// to upgrade to TLS, and then forwards client messages one at a time. An
+// injected error on an extended-protocol Parse discards the rest of the
+// message batch until the next Sync, as a real server would.
+func (p *Proxy) proxyPostgres(client, server net.Conn) {
+	clientWriter := &lockedWriter{w: client}
+
+	for {
+		startup, err := readPostgresStartupMessage(client)
+		if err != nil {
+			server.Close()
+			return
+		}
+
+		code := binary.BigEndian.Uint32(startup[4:8])
+		if code == postgresSSLRequestCode || code == postgresGSSENCRequestCode {
+			_, err := client.Write([]byte{'N'})
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		_, err = server.Write(startup)
+		if err != nil {
+			return
+		}
+		break
+	}
+
+	var txStatus atomic.Value
+	txStatus.Store(byte(postgresStatusIdle))
+
+	go func() {
+		defer client.Close()
+		for {
+			msgType, msg, err := readPostgresMessage(server)
+			if err != nil {
+				return
+			}
+			if msgType == postgresReadyForQuery && len(msg) > 5 {
+				txStatus.Store(msg[5])
+			}
+			_, err = clientWriter.Write(msg)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	discardUntilSync := false
+	for {
+		msgType, msg, err := readPostgresMessage(client)
+		if err != nil {
+			server.Close()
+			return
+		}
+
+		if discardUntilSync {
+			if msgType != postgresSync {
+				continue
+			}
+			discardUntilSync = false
+			_, err := clientWriter.Write(postgresReadyForQueryMessage(failedStatus(txStatus.Load().(byte))))
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		if query, ok := postgresQueryText(msgType, msg[5:]); ok {
+			if fault, ok := p.faultFor(query); ok {
+				if forward, reply := applyFault(fault, client, server); !forward {
+					if !reply {
+						return
+					}
+
+					response := postgresErrorMessage(fault.Error)
+					if msgType == postgresQuery {
+						response = append(response, postgresReadyForQueryMessage(failedStatus(txStatus.Load().(byte)))...)
+					} else {
+						discardUntilSync = true
+					}
+
+					_, err := clientWriter.Write(response)
+					if err != nil {
+						return
+					}
+					continue
+				}
+			}
+		}
+
+		_, err = server.Write(msg)
+		if err != nil {
+			return
+		}
+	}
+}
+
+func readPostgresStartupMessage(r io.Reader) ([]byte, error) {
+	header := make([]byte, 4)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, err
+	}
+
+	length := int(binary.BigEndian.Uint32(header))
+	if length < 8 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	msg := make([]byte, length)
+	copy(msg, header)
+	_, err = io.ReadFull(r, msg[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// readPostgresMessage returns the message type and the whole message,
+// including the type byte and length.
+func readPostgresMessage(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length := int(binary.BigEndian.Uint32(header[1:]))
+	if length < 4 {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+
+	msg := make([]byte, length+1)
+	copy(msg, header)
+	_, err = io.ReadFull(r, msg[5:])
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return header[0], msg, nil
+}
+
+func postgresQueryText(msgType byte, body []byte) (string, bool) {
+	switch msgType {
+	case postgresQuery:
+		return cString(body), true
+	case postgresParse:
+		nameEnd := bytes.IndexByte(body, 0)
+		if nameEnd < 0 {
+			return "", false
+		}
+		return cString(body[nameEnd+1:]), true
+	default:
+		return "", false
+	}
+}
+
+func cString(b []byte) string {
+	if end := bytes.IndexByte(b, 0); end >= 0 {
+		return string(b[:end])
+	}
+	return string(b)
+}
+
+func postgresErrorMessage(e *Error) []byte {
+	body := []byte{}
+	for _, field := range []struct {
+		code  byte
+		value string
+	}{
+		{'S', "ERROR"},
+		{'V', "ERROR"},
+		{'C', sqlState(e.SQLState, "XX000")},
+		{'M', e.Message},
+	} {
+		body = append(body, field.code)
+		body = append(body, field.value...)
+		body = append(body, 0)
+	}
+	body = append(body, 0)
+
+	return postgresMessage(postgresErrorResponse, body)
+}
+
+func postgresReadyForQueryMessage(status byte) []byte {
+	return postgresMessage(postgresReadyForQuery, []byte{status})
+}
+
+func postgresMessage(msgType byte, body []byte) []byte {
+	msg := make([]byte, 5, 5+len(body))
+	msg[0] = msgType
+	binary.BigEndian.PutUint32(msg[1:], uint32(4+len(body)))
+	return append(msg, body...)
+}
+
+func failedStatus(status byte) byte {
+	if status == postgresStatusInTx {
+		return postgresStatusFailedTx
+	}
+	return status
+}
@@ -0,0 +1,221 @@
+package sqlproxy
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// Fault describes how the proxy should misbehave when a query or prepared
+// statement sent by a client matches Pattern. Latency is applied first, then
+// the connection is either severed or answered with Error instead of being
+// forwarded to the database. Sever takes precedence over Error.
+type Fault struct {
+	Pattern *regexp.Regexp
+	Latency time.Duration
+	Error   *Error
+	Sever   bool
+}
+
+// Error is the error returned to the client in place of the database
+// response. Code is only used by the MySQL protocol.
+type Error struct {
+	Code     uint16
+	SQLState string
+	Message  string
+}
+
+var (
+	MySQLDeadlock        = &Error{Code: 1213, SQLState: "40001", Message: "Deadlock found when trying to get lock; try restarting transaction"}
+	MySQLLockWaitTimeout = &Error{Code: 1205, SQLState: "HY000", Message: "Lock wait timeout exceeded; try restarting transaction"}
+	MySQLServerGone      = &Error{Code: 2006, SQLState: "HY000", Message: "MySQL server has gone away"}
+
+	PostgresDeadlock             = &Error{SQLState: "40P01", Message: "deadlock detected"}
+	PostgresSerializationFailure = &Error{SQLState: "40001", Message: "could not serialize access due to concurrent update"}
+	PostgresAdminShutdown        = &Error{SQLState: "57P01", Message: "terminating connection due to administrator command"}
+)
+
+// Proxy is a pass-through TCP proxy that understands just enough of the MySQL
+// and Postgres wire protocols to find the queries sent by clients. Clients
+// must connect without TLS, since encrypted traffic cannot be inspected.
+type Proxy struct {
+	driverName      string
+	listenAddress   string
+	upstreamAddress string
+
+	lock   sync.Mutex
+	faults []Fault
+	// conns maps every client connection to its database connection, which
+	// is nil until the proxy has connected to the database.
+	conns map[net.Conn]net.Conn
+}
+
+func New(driverName, listenAddress, upstreamAddress string) *Proxy {
+	return &Proxy{
+		driverName:      driverName,
+		listenAddress:   listenAddress,
+		upstreamAddress: upstreamAddress,
+		conns:           map[net.Conn]net.Conn{},
+	}
+}
+
+func (p *Proxy) Address() string {
+	return p.listenAddress
+}
+
+// Inject adds a fault. Faults are matched in the order they were injected and
+// only the first matching fault is applied to a query.
+func (p *Proxy) Inject(fault Fault) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.faults = append(p.faults, fault)
+}
+
+// Reset removes all injected faults, restoring plain pass-through behaviour.
+func (p *Proxy) Reset() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.faults = nil
+}
+
+// SeverConnections closes every connection currently open through the proxy,
+// regardless of what the clients are doing.
+func (p *Proxy) SeverConnections() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for client, server := range p.conns {
+		client.Close()
+		if server != nil {
+			server.Close()
+		}
+		delete(p.conns, client)
+	}
+}
+
+// Connections returns the number of client connections currently open
+// through the proxy, including those still connecting to the database.
+func (p *Proxy) Connections() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.conns)
+}
+
+func (p *Proxy) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	var proxyConnection func(client, server net.Conn)
+	switch p.driverName {
+	case "mysql":
+		proxyConnection = p.proxyMySQL
+	case "postgres":
+		proxyConnection = p.proxyPostgres
+	default:
+		return fmt.Errorf("unsupported driver %s", p.driverName)
+	}
+
+	listener, err := net.Listen("tcp", p.listenAddress)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			client, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			// the client is tracked before connecting to the database, so
+			// SeverConnections cannot miss it while the database is dialed
+			p.track(client)
+			go func() {
+				defer client.Close()
+				defer p.untrack(client)
+
+				server, err := net.Dial("tcp", p.upstreamAddress)
+				if err != nil {
+					return
+				}
+				defer server.Close()
+
+				if !p.attach(client, server) {
+					return
+				}
+				proxyConnection(client, server)
+			}()
+		}
+	}()
+
+	close(ready)
+
+	<-signals
+	listener.Close()
+	p.SeverConnections()
+	return nil
+}
+
+func (p *Proxy) track(client net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.conns[client] = nil
+}
+
+// attach records the database connection of client, and reports false if
+// the client was severed while the proxy was connecting to the database.
+func (p *Proxy) attach(client, server net.Conn) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if _, ok := p.conns[client]; !ok {
+		return false
+	}
+	p.conns[client] = server
+	return true
+}
+
+func (p *Proxy) untrack(client net.Conn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.conns, client)
+}
+
+func (p *Proxy) faultFor(query string) (Fault, bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for _, fault := range p.faults {
+		if fault.Pattern == nil || fault.Pattern.MatchString(query) {
+			return fault, true
+		}
+	}
+	return Fault{}, false
+}
+
+// applyFault sleeps for the fault latency and reports whether the query
+// should still be forwarded to the database, or otherwise whether the client
+// should be answered with the fault's Error. The connection is closed if the
+// fault severs it, and nothing is written to it after that.
+func applyFault(fault Fault, client, server net.Conn) (forward, reply bool) {
+	if fault.Latency > 0 {
+		time.Sleep(fault.Latency)
+	}
+
+	if fault.Sever {
+		client.Close()
+		server.Close()
+		return false, false
+	}
+
+	return fault.Error == nil, fault.Error != nil
+}
+
+type lockedWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (l *lockedWriter) Write(b []byte) (int, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.w.Write(b)
+}
@@ -0,0 +1,13 @@
+package sqlproxy_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSqlproxy(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "SQLProxy Suite")
+}
@@ -0,0 +1,240 @@
+package sqlproxy_test
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"regexp"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/sqlproxy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+)
+
+var _ = Describe("Proxy", func() {
+	var (
+		upstream net.Listener
+		proxy    *sqlproxy.Proxy
+		process  ifrit.Process
+		conn     net.Conn
+	)
+
+	startProxy := func(driverName string, handleConnection func(net.Conn)) {
+		var err error
+		upstream, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			for {
+				c, err := upstream.Accept()
+				if err != nil {
+					return
+				}
+				go handleConnection(c)
+			}
+		}()
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		address := listener.Addr().String()
+		Expect(listener.Close()).To(Succeed())
+
+		proxy = sqlproxy.New(driverName, address, upstream.Addr().String())
+		process = ginkgomon.Invoke(proxy)
+
+		conn, err = net.Dial("tcp", proxy.Address())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	AfterEach(func() {
+		conn.Close()
+		ginkgomon.Kill(process)
+		upstream.Close()
+	})
+
+	Context("mysql", func() {
+		query := func(sequence byte, query string) []byte {
+			payload := append([]byte{0x03}, query...)
+			return append([]byte{byte(len(payload)), 0, 0, sequence}, payload...)
+		}
+
+		readPacket := func() []byte {
+			header := make([]byte, 4)
+			_, err := io.ReadFull(conn, header)
+			Expect(err).NotTo(HaveOccurred())
+			payload := make([]byte, int(header[0]))
+			_, err = io.ReadFull(conn, payload)
+			Expect(err).NotTo(HaveOccurred())
+			return append(header, payload...)
+		}
+
+		BeforeEach(func() {
+			// the fake database echoes every packet it receives
+			startProxy("mysql", func(c net.Conn) { io.Copy(c, c) })
+		})
+
+		It("passes queries through to the database", func() {
+			_, err := conn.Write(query(0, "SELECT 1"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(readPacket()).To(Equal(query(0, "SELECT 1")))
+		})
+
+		Context("when an error is injected", func() {
+			BeforeEach(func() {
+				proxy.Inject(sqlproxy.Fault{
+					Pattern: regexp.MustCompile("UPDATE actual_lrps"),
+					Error:   sqlproxy.MySQLDeadlock,
+				})
+			})
+
+			It("replies with the error instead of forwarding matching queries", func() {
+				_, err := conn.Write(query(0, "UPDATE actual_lrps SET state = 'RUNNING'"))
+				Expect(err).NotTo(HaveOccurred())
+
+				packet := readPacket()
+				Expect(packet[3]).To(BeEquivalentTo(1))
+				Expect(packet[4]).To(BeEquivalentTo(0xff))
+				Expect(binary.LittleEndian.Uint16(packet[5:7])).To(BeEquivalentTo(1213))
+				Expect(string(packet[7:13])).To(Equal("#40001"))
+			})
+
+			It("forwards queries that do not match", func() {
+				_, err := conn.Write(query(0, "SELECT 1"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(readPacket()).To(Equal(query(0, "SELECT 1")))
+			})
+
+			Context("and the faults are reset", func() {
+				BeforeEach(func() {
+					proxy.Reset()
+				})
+
+				It("forwards every query", func() {
+					_, err := conn.Write(query(0, "UPDATE actual_lrps SET state = 'RUNNING'"))
+					Expect(err).NotTo(HaveOccurred())
+					Expect(readPacket()).To(Equal(query(0, "UPDATE actual_lrps SET state = 'RUNNING'")))
+				})
+			})
+		})
+
+		Context("when latency is injected", func() {
+			BeforeEach(func() {
+				proxy.Inject(sqlproxy.Fault{Latency: 500 * time.Millisecond})
+			})
+
+			It("delays the query", func() {
+				start := time.Now()
+				_, err := conn.Write(query(0, "SELECT 1"))
+				Expect(err).NotTo(HaveOccurred())
+				readPacket()
+				Expect(time.Since(start)).To(BeNumerically(">=", 500*time.Millisecond))
+			})
+		})
+
+		Context("when the connection is severed", func() {
+			It("closes the client connection", func() {
+				proxy.Inject(sqlproxy.Fault{Pattern: regexp.MustCompile("COMMIT"), Sever: true})
+
+				_, err := conn.Write(query(0, "COMMIT"))
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = conn.Read(make([]byte, 1))
+				Expect(err).To(HaveOccurred())
+			})
+
+			It("closes all open connections on demand", func() {
+				Eventually(proxy.Connections).Should(Equal(1))
+				proxy.SeverConnections()
+
+				Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+				_, err := conn.Read(make([]byte, 1))
+				Expect(err).To(HaveOccurred())
+				Expect(err).NotTo(MatchError(ContainSubstring("timeout")))
+				Expect(proxy.Connections()).To(Equal(0))
+			})
+
+			It("does not reply with the error of a fault that also severs", func() {
+				proxy.Inject(sqlproxy.Fault{Pattern: regexp.MustCompile("COMMIT"), Sever: true, Error: sqlproxy.MySQLDeadlock})
+
+				_, err := conn.Write(query(0, "COMMIT"))
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(conn.SetReadDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+				_, err = conn.Read(make([]byte, 1))
+				Expect(err).To(Equal(io.EOF))
+			})
+		})
+	})
+
+	Context("postgres", func() {
+		message := func(msgType byte, body string) []byte {
+			msg := []byte{msgType, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(msg[1:], uint32(4+len(body)))
+			return append(msg, body...)
+		}
+
+		BeforeEach(func() {
+			// the fake database is ready as soon as it receives the startup
+			// message, and echoes every message after that
+			startProxy("postgres", func(c net.Conn) {
+				startup := make([]byte, 8)
+				if _, err := io.ReadFull(c, startup); err != nil {
+					return
+				}
+				c.Write(message('Z', "I"))
+				io.Copy(c, c)
+			})
+
+			sslRequest := []byte{0, 0, 0, 8, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(sslRequest[4:], 80877103)
+			_, err := conn.Write(sslRequest)
+			Expect(err).NotTo(HaveOccurred())
+
+			response := make([]byte, 1)
+			_, err = io.ReadFull(conn, response)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response).To(Equal([]byte{'N'}))
+
+			startup := []byte{0, 0, 0, 8, 0, 3, 0, 0}
+			_, err = conn.Write(startup)
+			Expect(err).NotTo(HaveOccurred())
+
+			ready := make([]byte, 6)
+			_, err = io.ReadFull(conn, ready)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ready).To(Equal(message('Z', "I")))
+		})
+
+		It("passes queries through to the database", func() {
+			_, err := conn.Write(message('Q', "SELECT 1\x00"))
+			Expect(err).NotTo(HaveOccurred())
+
+			response := make([]byte, 14)
+			_, err = io.ReadFull(conn, response)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response).To(Equal(message('Q', "SELECT 1\x00")))
+		})
+
+		It("replies with an error response followed by ready for query", func() {
+			proxy.Inject(sqlproxy.Fault{
+				Pattern: regexp.MustCompile("DELETE FROM tasks"),
+				Error:   sqlproxy.PostgresDeadlock,
+			})
+
+			_, err := conn.Write(message('Q', "DELETE FROM tasks\x00"))
+			Expect(err).NotTo(HaveOccurred())
+
+			expected := append(
+				message('E', "SERROR\x00VERROR\x00C40P01\x00Mdeadlock detected\x00\x00"),
+				message('Z', "I")...,
+			)
+			response := make([]byte, len(expected))
+			_, err = io.ReadFull(conn, response)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response).To(Equal(expected))
+		})
+	})
+})
@@ -0,0 +1,274 @@
+package sshhelpers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/onsi/gomega/gbytes"
+	"golang.org/x/crypto/ssh"
+)
+
+// DiegoUser is the username the ssh proxy authenticates with diego auth, for
+// the instance of the LRP at index.
+func DiegoUser(processGuid string, index int) string {
+	return fmt.Sprintf("diego:%s/%d", processGuid, index)
+}
+
+// CFUser is the username the ssh proxy authenticates with CF auth, using a
+// one-time code from UAA as the password, for the instance of the app at
+// index.
+func CFUser(appGuid string, index int) string {
+	return fmt.Sprintf("cf:%s/%d", appGuid, index)
+}
+
+// Fingerprint formats a host key the way the ssh proxy and sshd log it.
+func Fingerprint(key ssh.PublicKey) string {
+	return fmt.Sprintf("%s (%s)", ssh.FingerprintSHA256(key), ssh.FingerprintLegacyMD5(key))
+}
+
+// VerifyHostKey only accepts hosts presenting expected, e.g.
+// componentMaker.SSHConfig().HostKey.PublicKey().
+func VerifyHostKey(expected ssh.PublicKey) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if !bytes.Equal(key.Marshal(), expected.Marshal()) {
+			return fmt.Errorf("host key of %s has fingerprint %s, expected %s", hostname, Fingerprint(key), Fingerprint(expected))
+		}
+		return nil
+	}
+}
+
+// Client is an SSH connection to an app instance, usually through the ssh
+// proxy.
+type Client struct {
+	*ssh.Client
+
+	httpClientLock sync.Mutex
+	httpClient     *http.Client
+}
+
+// Dial connects to the ssh proxy at address as user, e.g. DiegoUser with an
+// empty password, verifying that it presents hostKey.
+func Dial(address, user, password string, hostKey ssh.PublicKey) (*Client, error) {
+	client, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: VerifyHostKey(hostKey),
+		Timeout:         10 * time.Second,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Client: client}, nil
+}
+
+// Result is the outcome of a command that ran to completion.
+type Result struct {
+	Stdout     []byte
+	Stderr     []byte
+	ExitStatus int
+}
+
+// Run runs command in the instance. A command that exits non-zero is not an
+// error; check the ExitStatus.
+func (c *Client) Run(command string) (Result, error) {
+	session, err := c.NewSession()
+	if err != nil {
+		return Result{}, err
+	}
+	defer session.Close()
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	exitStatus, err := exitStatus(session.Run(command))
+	return Result{Stdout: stdout.Bytes(), Stderr: stderr.Bytes(), ExitStatus: exitStatus}, err
+}
+
+func exitStatus(err error) (int, error) {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), nil
+	}
+	if err != nil {
+		return -1, err
+	}
+	return 0, nil
+}
+
+// Shell is an interactive session with a PTY. Its Output can be matched with
+// gbytes.Say.
+type Shell struct {
+	session *ssh.Session
+	stdin   io.WriteCloser
+	Output  *gbytes.Buffer
+}
+
+// Shell starts command with a PTY, or the user's login shell when command is
+// empty.
+func (c *Client) Shell(command string) (*Shell, error) {
+	session, err := c.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	modes := ssh.TerminalModes{ssh.ECHO: 0}
+	if err := session.RequestPty("xterm", 24, 80, modes); err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	output := gbytes.NewBuffer()
+	session.Stdout = output
+	session.Stderr = output
+
+	if command == "" {
+		err = session.Shell()
+	} else {
+		err = session.Start(command)
+	}
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	return &Shell{session: session, stdin: stdin, Output: output}, nil
+}
+
+// Send types input into the shell.
+func (s *Shell) Send(input string) error {
+	_, err := io.WriteString(s.stdin, input)
+	return err
+}
+
+// Wait closes the shell's input and waits for it to exit.
+func (s *Shell) Wait() (int, error) {
+	s.stdin.Close()
+	return exitStatus(s.session.Wait())
+}
+
+func (s *Shell) Close() error {
+	return s.session.Close()
+}
+
+// Forward is a port forward through an SSH connection. Addr is the address
+// it listens on.
+type Forward struct {
+	Addr string
+
+	listener net.Listener
+}
+
+// ForwardLocal listens on a local port and forwards its connections to
+// remoteAddress, as seen from the instance, e.g. 127.0.0.1:8080.
+func (c *Client) ForwardLocal(remoteAddress string) (*Forward, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	return forward(listener, func() (net.Conn, error) {
+		return c.Dial("tcp", remoteAddress)
+	}), nil
+}
+
+// ForwardRemote listens on remoteAddress in the instance and forwards its
+// connections to localAddress.
+func (c *Client) ForwardRemote(remoteAddress, localAddress string) (*Forward, error) {
+	listener, err := c.Listen("tcp", remoteAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	return forward(listener, func() (net.Conn, error) {
+		return net.Dial("tcp", localAddress)
+	}), nil
+}
+
+func forward(listener net.Listener, dial func() (net.Conn, error)) *Forward {
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				target, err := dial()
+				if err != nil {
+					return
+				}
+				defer target.Close()
+
+				copyBoth(conn, target)
+			}()
+		}
+	}()
+
+	return &Forward{Addr: listener.Addr().String(), listener: listener}
+}
+
+// copyBoth copies between the connections until either side is done.
+func copyBoth(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// Close stops listening. Connections already forwarded are left open until
+// either side closes them.
+func (f *Forward) Close() error {
+	return f.listener.Close()
+}
+
+// HTTPClient makes requests from the instance, e.g. to
+// http://127.0.0.1:8080 for the app listening in its container. Every call
+// returns the same client, whose connections are closed with the Client.
+func (c *Client) HTTPClient() *http.Client {
+	c.httpClientLock.Lock()
+	defer c.httpClientLock.Unlock()
+
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{
+			Transport: &http.Transport{
+				Dial: c.Dial,
+			},
+			Timeout: 5 * time.Second,
+		}
+	}
+	return c.httpClient
+}
+
+// Close closes the idle connections of the HTTPClient and then the SSH
+// connection.
+func (c *Client) Close() error {
+	c.httpClientLock.Lock()
+	if c.httpClient != nil {
+		c.httpClient.CloseIdleConnections()
+	}
+	c.httpClientLock.Unlock()
+
+	return c.Client.Close()
+}
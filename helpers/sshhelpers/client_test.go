@@ -0,0 +1,185 @@
+package sshhelpers_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/inigo/helpers/sshhelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"golang.org/x/crypto/ssh"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		server *testServer
+		client *sshhelpers.Client
+		tmpDir string
+	)
+
+	BeforeEach(func() {
+		var err error
+		server, err = newTestServer(sshhelpers.DiegoUser("process-guid", 1), "")
+		Expect(err).NotTo(HaveOccurred())
+
+		tmpDir, err = ioutil.TempDir("", "sshhelpers")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		client, err = sshhelpers.Dial(server.Address(), sshhelpers.DiegoUser("process-guid", 1), "", server.hostKey.PublicKey())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		client.Close()
+		server.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	Describe("usernames", func() {
+		It("formats diego and cf usernames for an instance", func() {
+			Expect(sshhelpers.DiegoUser("process-guid", 1)).To(Equal("diego:process-guid/1"))
+			Expect(sshhelpers.CFUser("app-guid", 0)).To(Equal("cf:app-guid/0"))
+		})
+	})
+
+	Describe("Dial", func() {
+		It("fails with the wrong credentials", func() {
+			_, err := sshhelpers.Dial(server.Address(), sshhelpers.DiegoUser("process-guid", 2), "", server.hostKey.PublicKey())
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("fails when the host presents a different host key", func() {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).NotTo(HaveOccurred())
+			otherKey, err := ssh.NewSignerFromKey(key)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = sshhelpers.Dial(server.Address(), sshhelpers.DiegoUser("process-guid", 1), "", otherKey.PublicKey())
+			Expect(err).To(MatchError(ContainSubstring(ssh.FingerprintSHA256(server.hostKey.PublicKey()))))
+		})
+	})
+
+	Describe("Run", func() {
+		It("returns the output and exit status of the command", func() {
+			result, err := client.Run("echo out; echo err >&2; exit 3")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(result.Stdout)).To(Equal("out\n"))
+			Expect(string(result.Stderr)).To(Equal("err\n"))
+			Expect(result.ExitStatus).To(Equal(3))
+		})
+	})
+
+	Describe("Shell", func() {
+		It("sends input to the command and records its output", func() {
+			shell, err := client.Shell("")
+			Expect(err).NotTo(HaveOccurred())
+			defer shell.Close()
+
+			Expect(shell.Send("echo hello-shell\n")).To(Succeed())
+			Eventually(shell.Output).Should(gbytes.Say("hello-shell"))
+
+			Expect(shell.Send("exit 4\n")).To(Succeed())
+			Expect(shell.Wait()).To(Equal(4))
+		})
+	})
+
+	Describe("file transfer", func() {
+		var remotePath string
+
+		BeforeEach(func() {
+			remotePath = filepath.Join(tmpDir, "file with spaces")
+		})
+
+		It("uploads and downloads over sftp", func() {
+			Expect(client.SFTPUpload(remotePath, []byte("sftp contents"))).To(Succeed())
+			Expect(ioutil.ReadFile(remotePath)).To(Equal([]byte("sftp contents")))
+
+			Expect(client.SFTPDownload(remotePath)).To(Equal([]byte("sftp contents")))
+		})
+
+		It("uploads and downloads over scp", func() {
+			Expect(client.SCPUpload(remotePath, []byte("scp contents"), 0640)).To(Succeed())
+			Expect(ioutil.ReadFile(remotePath)).To(Equal([]byte("scp contents")))
+			info, err := os.Stat(remotePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)))
+
+			Expect(client.SCPDownload(remotePath)).To(Equal([]byte("scp contents")))
+		})
+
+		It("does not write past the contents it uploads over scp", func() {
+			remotePath := filepath.Join(tmpDir, "scp-slice")
+			backing := []byte("scp contents and more")
+			contents := backing[:len("scp contents")]
+
+			Expect(client.SCPUpload(remotePath, contents, 0640)).To(Succeed())
+			Expect(ioutil.ReadFile(remotePath)).To(Equal([]byte("scp contents")))
+			Expect(string(backing)).To(Equal("scp contents and more"))
+		})
+
+		It("fails to download missing files over scp", func() {
+			_, err := client.SCPDownload(filepath.Join(tmpDir, "missing"))
+			Expect(err).To(MatchError(ContainSubstring("No such file")))
+		})
+	})
+
+	Describe("port forwarding", func() {
+		var app *httptest.Server
+
+		BeforeEach(func() {
+			app = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "hello from "+r.URL.Path)
+			}))
+		})
+
+		AfterEach(func() {
+			app.Close()
+		})
+
+		get := func(httpClient *http.Client, url string) string {
+			response, err := httpClient.Get(url)
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+			Expect(err).NotTo(HaveOccurred())
+			return string(body)
+		}
+
+		It("forwards a local port to the instance", func() {
+			forward, err := client.ForwardLocal(strings.TrimPrefix(app.URL, "http://"))
+			Expect(err).NotTo(HaveOccurred())
+			defer forward.Close()
+
+			Expect(get(http.DefaultClient, "http://"+forward.Addr+"/local")).To(Equal("hello from /local"))
+		})
+
+		It("forwards a port in the instance to a local address", func() {
+			forward, err := client.ForwardRemote("127.0.0.1:0", strings.TrimPrefix(app.URL, "http://"))
+			Expect(err).NotTo(HaveOccurred())
+			defer forward.Close()
+
+			_, port, err := net.SplitHostPort(forward.Addr)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(port).NotTo(Equal("0"))
+
+			Expect(get(http.DefaultClient, "http://"+forward.Addr+"/remote")).To(Equal("hello from /remote"))
+		})
+
+		It("makes http requests from the instance", func() {
+			Expect(get(client.HTTPClient(), app.URL+"/http")).To(Equal("hello from /http"))
+			Expect(client.HTTPClient()).To(BeIdenticalTo(client.HTTPClient()))
+		})
+	})
+})
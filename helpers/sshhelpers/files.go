@@ -0,0 +1,201 @@
+package sshhelpers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/sftp"
+)
+
+// SFTPUpload writes data to remotePath in the instance over the sftp
+// subsystem.
+func (c *Client) SFTPUpload(remotePath string, data []byte) error {
+	client, err := sftp.NewClient(c.Client)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	file, err := client.Create(remotePath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// SFTPDownload reads remotePath in the instance over the sftp subsystem.
+func (c *Client) SFTPDownload(remotePath string) ([]byte, error) {
+	client, err := sftp.NewClient(c.Client)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	file, err := client.Open(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ioutil.ReadAll(file)
+}
+
+// SCPUpload writes data to remotePath in the instance with mode, speaking the
+// scp protocol to `scp -t` as the scp command line tool does.
+func (c *Client) SCPUpload(remotePath string, data []byte, mode os.FileMode) error {
+	session, err := c.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr := &bytes.Buffer{}
+	session.Stderr = stderr
+
+	if err := session.Start("scp -t " + shellQuote(remotePath)); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(stdout)
+	err = func() error {
+		if err := readSCPAck(reader); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(stdin, "C%04o %d %s\n", mode.Perm(), len(data), path.Base(remotePath)); err != nil {
+			return err
+		}
+		if err := readSCPAck(reader); err != nil {
+			return err
+		}
+		if _, err := stdin.Write(data); err != nil {
+			return err
+		}
+		if _, err := stdin.Write([]byte{0}); err != nil {
+			return err
+		}
+		return readSCPAck(reader)
+	}()
+	stdin.Close()
+
+	if waitErr := session.Wait(); err == nil && waitErr != nil {
+		err = fmt.Errorf("scp -t failed: %s: %s", waitErr, stderr.String())
+	}
+	return err
+}
+
+// SCPDownload reads remotePath in the instance, speaking the scp protocol to
+// `scp -f` as the scp command line tool does.
+func (c *Client) SCPDownload(remotePath string) ([]byte, error) {
+	session, err := c.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &bytes.Buffer{}
+	session.Stderr = stderr
+
+	if err := session.Start("scp -f " + shellQuote(remotePath)); err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(stdout)
+	data, err := func() ([]byte, error) {
+		if _, err := stdin.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+
+		first, err := reader.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if first[0] != 'C' {
+			if err := readSCPAck(reader); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("unexpected scp response %q", first[0])
+		}
+
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		fields := strings.SplitN(strings.TrimSpace(header), " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid scp file header: %q", strings.TrimSpace(header))
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid scp file size: %q", fields[1])
+		}
+
+		if _, err := stdin.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		if err := readSCPAck(reader); err != nil {
+			return nil, err
+		}
+		if _, err := stdin.Write([]byte{0}); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}()
+	stdin.Close()
+
+	if waitErr := session.Wait(); err == nil && waitErr != nil {
+		err = fmt.Errorf("scp -f failed: %s: %s", waitErr, stderr.String())
+	}
+	return data, err
+}
+
+// readSCPAck reads the status byte scp sends after each step: 0 for
+// success, or 1 or 2 followed by an error message.
+func readSCPAck(reader *bufio.Reader) error {
+	status, err := reader.ReadByte()
+	if err != nil {
+		return err
+	}
+	if status == 0 {
+		return nil
+	}
+
+	message, _ := reader.ReadString('\n')
+	return fmt.Errorf("scp: %s", strings.TrimSpace(message))
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
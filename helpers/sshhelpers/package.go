@@ -0,0 +1 @@
+package sshhelpers // import "code.cloudfoundry.org/inigo/helpers/sshhelpers"
@@ -0,0 +1,271 @@
+package sshhelpers_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"syscall"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testServer is an SSH server in the test process standing in for the ssh
+// proxy and the sshd in the container. It runs commands on the local
+// machine.
+type testServer struct {
+	listener net.Listener
+	hostKey  ssh.Signer
+	config   *ssh.ServerConfig
+}
+
+func newTestServer(user, password string) (*testServer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	hostKey, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, p []byte) (*ssh.Permissions, error) {
+			if conn.User() == user && string(p) == password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("invalid credentials for %s", conn.User())
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	server := &testServer{listener: listener, hostKey: hostKey, config: config}
+	go server.serve()
+	return server, nil
+}
+
+func (s *testServer) Address() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testServer) Close() {
+	s.listener.Close()
+}
+
+func (s *testServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+			if err != nil {
+				return
+			}
+			defer serverConn.Close()
+
+			go handleGlobalRequests(serverConn, requests)
+
+			for newChannel := range channels {
+				switch newChannel.ChannelType() {
+				case "session":
+					go handleSession(newChannel)
+				case "direct-tcpip":
+					go handleDirectTCPIP(newChannel)
+				default:
+					newChannel.Reject(ssh.UnknownChannelType, "unsupported")
+				}
+			}
+		}()
+	}
+}
+
+func handleSession(newChannel ssh.NewChannel) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	for request := range requests {
+		switch request.Type {
+		case "pty-req", "env":
+			request.Reply(true, nil)
+		case "exec", "shell":
+			command := "sh"
+			if request.Type == "exec" {
+				var payload struct{ Command string }
+				ssh.Unmarshal(request.Payload, &payload)
+				command = payload.Command
+			}
+			request.Reply(true, nil)
+			run(channel, command)
+			return
+		case "subsystem":
+			var payload struct{ Name string }
+			ssh.Unmarshal(request.Payload, &payload)
+			if payload.Name != "sftp" {
+				request.Reply(false, nil)
+				continue
+			}
+			request.Reply(true, nil)
+
+			server, err := sftp.NewServer(channel)
+			if err == nil {
+				server.Serve()
+			}
+			return
+		default:
+			request.Reply(false, nil)
+		}
+	}
+}
+
+func run(channel ssh.Channel, command string) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = channel
+	cmd.Stderr = channel.Stderr()
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return
+	}
+	go func() {
+		io.Copy(stdin, channel)
+		stdin.Close()
+	}()
+
+	status := 0
+	if err := cmd.Run(); err != nil {
+		status = 255
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			status = exitErr.Sys().(syscall.WaitStatus).ExitStatus()
+		}
+	}
+
+	exitStatus := make([]byte, 4)
+	binary.BigEndian.PutUint32(exitStatus, uint32(status))
+	channel.SendRequest("exit-status", false, exitStatus)
+}
+
+func handleDirectTCPIP(newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer conn.Close()
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(requests)
+
+	copyUntilDone(channel, conn)
+}
+
+func handleGlobalRequests(serverConn *ssh.ServerConn, requests <-chan *ssh.Request) {
+	for request := range requests {
+		if request.Type != "tcpip-forward" {
+			request.Reply(false, nil)
+			continue
+		}
+
+		var payload struct {
+			Addr string
+			Port uint32
+		}
+		if err := ssh.Unmarshal(request.Payload, &payload); err != nil {
+			request.Reply(false, nil)
+			continue
+		}
+
+		listener, err := net.Listen("tcp", net.JoinHostPort(payload.Addr, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			request.Reply(false, nil)
+			continue
+		}
+		port := uint32(listener.Addr().(*net.TCPAddr).Port)
+		request.Reply(true, ssh.Marshal(struct{ Port uint32 }{port}))
+
+		go func() {
+			<-waitClosed(serverConn)
+			listener.Close()
+		}()
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+
+				origin := conn.RemoteAddr().(*net.TCPAddr)
+				channel, requests, err := serverConn.OpenChannel("forwarded-tcpip", ssh.Marshal(struct {
+					Addr       string
+					Port       uint32
+					OriginAddr string
+					OriginPort uint32
+				}{payload.Addr, port, origin.IP.String(), uint32(origin.Port)}))
+				if err != nil {
+					conn.Close()
+					continue
+				}
+				go ssh.DiscardRequests(requests)
+
+				go func() {
+					defer conn.Close()
+					defer channel.Close()
+					copyUntilDone(channel, conn)
+				}()
+			}
+		}()
+	}
+}
+
+func waitClosed(serverConn *ssh.ServerConn) <-chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		serverConn.Wait()
+		close(closed)
+	}()
+	return closed
+}
+
+func copyUntilDone(a io.ReadWriter, b io.ReadWriter) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
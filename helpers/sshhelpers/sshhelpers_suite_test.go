@@ -0,0 +1,13 @@
+package sshhelpers_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestSshhelpers(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Sshhelpers Suite")
+}
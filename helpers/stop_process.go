@@ -31,10 +31,16 @@ func StopProcesses(processes ...ifrit.Process) {
 			case <-time.After(20 * time.Second):
 				fmt.Fprintf(GinkgoWriter, "!!!!!!!!!!!!!!!! STOP TIMEOUT !!!!!!!!!!!!!!!!")
 
+				dir := stopTimeoutDir()
+				stuck := stuckProcesses()
+				captureProfiles(dir, stuck)
+				before := componentOutputs()
+
 				process.Signal(syscall.SIGQUIT)
 				Eventually(process.Wait(), 10*time.Second).Should(Receive())
 
-				Expect(true).To(BeFalse(), "process did not shut down cleanly; SIGQUIT sent")
+				dumps := saveSIGQUITDumps(dir, stuck, before)
+				Expect(true).To(BeFalse(), fmt.Sprintf("process did not shut down cleanly; SIGQUIT sent; dumps %v written to %s", dumps, dir))
 			}
 		}
 	})
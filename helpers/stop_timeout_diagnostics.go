@@ -0,0 +1,133 @@
+package helpers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/inigo/helpers/componentlogs"
+	"code.cloudfoundry.org/inigo/helpers/componentstats"
+	. "github.com/onsi/ginkgo"
+)
+
+var stopTimeoutDiagnostics struct {
+	logs  *componentlogs.Store
+	stats *componentstats.Sampler
+}
+
+// RegisterStopTimeoutDiagnostics lets StopProcesses collect goroutine and
+// heap dumps of the component processes that do not stop in time.
+func RegisterStopTimeoutDiagnostics(logs *componentlogs.Store, stats *componentstats.Sampler) {
+	stopTimeoutDiagnostics.logs = logs
+	stopTimeoutDiagnostics.stats = stats
+}
+
+// stuckProcesses returns the component processes that were asked to stop
+// but are still running.
+func stuckProcesses() []componentstats.Process {
+	if stopTimeoutDiagnostics.stats == nil {
+		return nil
+	}
+	return stopTimeoutDiagnostics.stats.Stopping()
+}
+
+// captureProfiles saves the goroutine and heap profiles of the stuck
+// processes with a debug server, before they are sent SIGQUIT.
+func captureProfiles(dir string, stuck []componentstats.Process) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, process := range stuck {
+		if process.DebugAddress == "" {
+			continue
+		}
+
+		profiles := map[string]string{
+			"goroutines.txt": "/debug/pprof/goroutine?debug=2",
+			"heap.pprof":     "/debug/pprof/heap",
+		}
+		for suffix, path := range profiles {
+			name := fmt.Sprintf("%s-%d-%s", process.Component, process.Pid, suffix)
+			err := fetchToFile(client, "http://"+process.DebugAddress+path, filepath.Join(dir, name))
+			if err != nil {
+				fmt.Fprintf(GinkgoWriter, "failed to fetch %s of %s: %s\n", path, process.Component, err)
+			}
+		}
+	}
+}
+
+func fetchToFile(client *http.Client, url, path string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, body, 0644)
+}
+
+// componentOutputs returns the current output of every component, so that
+// the output written after a SIGQUIT can be picked out later.
+func componentOutputs() []componentlogs.Output {
+	if stopTimeoutDiagnostics.logs == nil {
+		return nil
+	}
+	return stopTimeoutDiagnostics.logs.Outputs()
+}
+
+// saveSIGQUITDumps writes the stderr each stuck process produced since
+// before was taken to a file named after its component and pid, if it
+// contains a goroutine dump.
+func saveSIGQUITDumps(dir string, stuck []componentstats.Process, before []componentlogs.Output) []string {
+	stuckPids := map[int]bool{}
+	for _, process := range stuck {
+		stuckPids[process.Pid] = true
+	}
+
+	written := map[int]int{}
+	for _, output := range before {
+		if output.Stream == componentlogs.Stderr {
+			written[output.Pid] = len(output.Contents)
+		}
+	}
+
+	saved := []string{}
+	for _, output := range componentOutputs() {
+		if output.Stream != componentlogs.Stderr || !stuckPids[output.Pid] {
+			continue
+		}
+
+		contents := output.Contents[written[output.Pid]:]
+		if !bytes.Contains(contents, []byte("goroutine ")) {
+			continue
+		}
+
+		name := fmt.Sprintf("%s-%d-sigquit.txt", output.Component, output.Pid)
+		err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0644)
+		if err != nil {
+			fmt.Fprintf(GinkgoWriter, "failed to save SIGQUIT dump of %s: %s\n", output.Component, err)
+			continue
+		}
+		saved = append(saved, name)
+	}
+	return saved
+}
+
+func stopTimeoutDir() string {
+	dir := filepath.Join(ArtifactsDir(), "stop-timeout")
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		fmt.Fprintf(GinkgoWriter, "failed to create %s: %s\n", dir, err)
+	}
+	return dir
+}
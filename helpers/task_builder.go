@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager"
+	. "github.com/onsi/gomega"
+)
+
+// TaskBuilder builds a Task for the inigo domain on the default preloaded
+// rootfs, e.g.
+//
+//	task := helpers.NewTask(helpers.GenerateGuid()).
+//		WithAction(&models.RunAction{User: "vcap", Path: "sh", Args: []string{"-c", "echo hi > /tmp/result"}}).
+//		WithResultFile("/tmp/result").
+//		Build()
+type TaskBuilder struct {
+	task *models.Task
+}
+
+func NewTask(taskGuid string) *TaskBuilder {
+	return &TaskBuilder{
+		task: &models.Task{
+			TaskGuid: taskGuid,
+			Domain:   defaultDomain,
+
+			TaskDefinition: &models.TaskDefinition{
+				RootFs: defaultPreloadedRootFS,
+			},
+		},
+	}
+}
+
+func (b *TaskBuilder) WithRootFS(rootfs string) *TaskBuilder {
+	b.task.RootFs = rootfs
+	return b
+}
+
+// WithImageCredentials sets the credentials used to pull a docker rootfs.
+func (b *TaskBuilder) WithImageCredentials(username, password string) *TaskBuilder {
+	b.task.ImageUsername = username
+	b.task.ImagePassword = password
+	return b
+}
+
+func (b *TaskBuilder) WithImageLayers(layers ...*models.ImageLayer) *TaskBuilder {
+	b.task.ImageLayers = append(b.task.ImageLayers, layers...)
+	return b
+}
+
+func (b *TaskBuilder) WithAction(action models.ActionInterface) *TaskBuilder {
+	b.task.Action = wrapAction(action)
+	return b
+}
+
+// WithResultFile sets the file whose contents become the result of the
+// task once it completes.
+func (b *TaskBuilder) WithResultFile(path string) *TaskBuilder {
+	b.task.ResultFile = path
+	return b
+}
+
+// WithCompletionCallbackURL sets the URL BBS posts the completed task to,
+// e.g. one served by Callback.
+func (b *TaskBuilder) WithCompletionCallbackURL(url string) *TaskBuilder {
+	b.task.CompletionCallbackUrl = url
+	return b
+}
+
+func (b *TaskBuilder) WithEgressRules(rules ...*models.SecurityGroupRule) *TaskBuilder {
+	b.task.EgressRules = append(b.task.EgressRules, rules...)
+	return b
+}
+
+func (b *TaskBuilder) WithVolumeMount(mount *models.VolumeMount) *TaskBuilder {
+	b.task.VolumeMounts = append(b.task.VolumeMounts, mount)
+	return b
+}
+
+// WithEnv adds an environment variable to every process of the task.
+func (b *TaskBuilder) WithEnv(name, value string) *TaskBuilder {
+	b.task.EnvironmentVariables = append(b.task.EnvironmentVariables, &models.EnvironmentVariable{Name: name, Value: value})
+	return b
+}
+
+func (b *TaskBuilder) WithPrivileged(privileged bool) *TaskBuilder {
+	b.task.Privileged = privileged
+	return b
+}
+
+// WithLogs sets the log guid and source the task's output is emitted with.
+// Tasks have no log rate limit of their own in the BBS models this suite is
+// built against, so per-task limits are out of scope; limit every container
+// with the rep's MaxLogLinesPerSecond instead.
+func (b *TaskBuilder) WithLogs(logGuid, logSource string) *TaskBuilder {
+	b.task.LogGuid = logGuid
+	b.task.LogSource = logSource
+	return b
+}
+
+func (b *TaskBuilder) WithResources(memoryMB, diskMB int) *TaskBuilder {
+	b.task.MemoryMb = int32(memoryMB)
+	b.task.DiskMb = int32(diskMB)
+	return b
+}
+
+func (b *TaskBuilder) WithMaxPids(maxPids int) *TaskBuilder {
+	b.task.MaxPids = int32(maxPids)
+	return b
+}
+
+func (b *TaskBuilder) WithPlacementTags(tags ...string) *TaskBuilder {
+	b.task.PlacementTags = tags
+	return b
+}
+
+// WithCertificateProperties sets the properties, such as the organizational
+// units, of the instance identity certificates.
+func (b *TaskBuilder) WithCertificateProperties(properties *models.CertificateProperties) *TaskBuilder {
+	b.task.CertificateProperties = properties
+	return b
+}
+
+// Build returns the task after checking that BBS would accept it.
+func (b *TaskBuilder) Build() *models.Task {
+	definition := *b.task.TaskDefinition
+	task := *b.task
+	task.TaskDefinition = &definition
+
+	ExpectWithOffset(1, task.Validate()).To(Succeed())
+	return &task
+}
+
+// RunTaskToCompletion desires the task, waits for it to complete, and
+// resolves and deletes it. It returns the task's result, or an error with
+// the failure reason if the task failed. Tasks with a completion callback are
+// resolved by BBS as soon as they complete, so wait for the callback instead.
+func RunTaskToCompletion(logger lager.Logger, client bbs.InternalClient, task *models.Task) (string, error) {
+	ExpectWithOffset(1, task.CompletionCallbackUrl).To(BeEmpty(), "tasks with a completion callback are resolved by BBS")
+
+	err := client.DesireTask(logger, task.TaskGuid, task.Domain, task.TaskDefinition)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	var completed models.Task
+	EventuallyWithOffset(1, TaskStatePoller(logger, client, task.TaskGuid, &completed)).Should(Equal(models.Task_Completed))
+
+	err = client.ResolvingTask(logger, task.TaskGuid)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	err = client.DeleteTask(logger, task.TaskGuid)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	if completed.Failed {
+		return "", errors.New(completed.FailureReason)
+	}
+	return completed.Result, nil
+}
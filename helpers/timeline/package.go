@@ -0,0 +1 @@
+package timeline // import "code.cloudfoundry.org/inigo/helpers/timeline"
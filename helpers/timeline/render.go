@@ -0,0 +1,149 @@
+package timeline
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const timeFormat = "15:04:05.000000"
+
+// Group is the part of the timeline that concerns a single guid or cell.
+type Group struct {
+	Name    string
+	Entries []Entry
+}
+
+// Groups splits the entries by guid and by cell id. Entries that carry both
+// appear in both groups.
+func Groups(entries []Entry) []Group {
+	byKey := map[string][]Entry{}
+	for _, entry := range entries {
+		if entry.Guid != "" {
+			key := "guid " + entry.Guid
+			byKey[key] = append(byKey[key], entry)
+		}
+		if entry.CellId != "" {
+			key := "cell " + entry.CellId
+			byKey[key] = append(byKey[key], entry)
+		}
+	}
+
+	keys := make([]string, 0, len(byKey))
+	for key := range byKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	groups := make([]Group, len(keys))
+	for i, key := range keys {
+		groups[i] = Group{Name: key, Entries: byKey[key]}
+	}
+	return groups
+}
+
+// WriteText renders the whole timeline followed by one section per guid and
+// cell.
+func WriteText(w io.Writer, entries []Entry) error {
+	sections := append([]Group{{Name: "all", Entries: entries}}, Groups(entries)...)
+	for _, section := range sections {
+		_, err := fmt.Fprintf(w, "=== %s ===\n", section.Name)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range section.Entries {
+			_, err := fmt.Fprintf(w, "%s %-10s %-16s %-36s %-12s %s\n",
+				entry.Time.Format(timeFormat), entry.Kind, entry.Source, entry.Guid, entry.CellId, entry.Summary)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = fmt.Fprintln(w)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var htmlTemplate = template.Must(template.New("timeline").Funcs(template.FuncMap{
+	"timestamp": func(entry Entry) string { return entry.Time.Format(timeFormat) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; font-size: 12px; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+td, th { border: 1px solid #ddd; padding: 2px 6px; text-align: left; vertical-align: top; }
+td.summary { font-family: monospace; white-space: pre-wrap; word-break: break-all; }
+tr.step { background: #fff3c4; font-weight: bold; }
+tr.bbs-event, tr.task-event { background: #e3f0ff; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+<li><a href="#all">all</a></li>
+{{range $i, $group := .Groups}}<li><a href="#group-{{$i}}">{{$group.Name}}</a></li>
+{{end}}</ul>
+<h2 id="all">all</h2>
+{{template "entries" .Entries}}
+{{range $i, $group := .Groups}}<h2 id="group-{{$i}}">{{$group.Name}}</h2>
+{{template "entries" $group.Entries}}
+{{end}}</body>
+</html>
+{{define "entries"}}<table>
+<tr><th>time</th><th>kind</th><th>source</th><th>guid</th><th>cell</th><th>summary</th></tr>
+{{range .}}<tr class="{{.Kind}}"><td>{{timestamp .}}</td><td>{{.Kind}}</td><td>{{.Source}}</td><td>{{.Guid}}</td><td>{{.CellId}}</td><td class="summary">{{.Summary}}</td></tr>
+{{end}}</table>{{end}}
+`))
+
+// WriteHTML renders the timeline as a single HTML page with the whole
+// timeline followed by one table per guid and cell.
+func WriteHTML(w io.Writer, title string, entries []Entry) error {
+	return htmlTemplate.Execute(w, struct {
+		Title   string
+		Entries []Entry
+		Groups  []Group
+	}{
+		Title:   title,
+		Entries: entries,
+		Groups:  Groups(entries),
+	})
+}
+
+// Export writes timeline.txt and timeline.html into dir.
+func (r *Recorder) Export(dir, title string) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	entries := r.Entries()
+
+	textFile, err := os.Create(filepath.Join(dir, "timeline.txt"))
+	if err != nil {
+		return err
+	}
+	defer textFile.Close()
+
+	err = WriteText(textFile, entries)
+	if err != nil {
+		return err
+	}
+
+	htmlFile, err := os.Create(filepath.Join(dir, "timeline.html"))
+	if err != nil {
+		return err
+	}
+	defer htmlFile.Close()
+
+	return WriteHTML(htmlFile, title, entries)
+}
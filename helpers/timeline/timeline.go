@@ -0,0 +1,235 @@
+package timeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers/bbsevents"
+	"code.cloudfoundry.org/inigo/helpers/componentlogs"
+	"github.com/onsi/ginkgo"
+)
+
+const (
+	KindStep      = "step"
+	KindBBSEvent  = "bbs-event"
+	KindTaskEvent = "task-event"
+	KindLog       = "log"
+)
+
+// Entry is a single point on the timeline. Guid is the process guid of an
+// LRP or the guid of a task.
+type Entry struct {
+	Time    time.Time
+	Kind    string
+	Source  string
+	Guid    string
+	CellId  string
+	Summary string
+}
+
+// Recorder collects BBS events, test steps and component logs while a spec
+// runs so that they can be rendered as a single timeline.
+type Recorder struct {
+	componentLogs *componentlogs.Store
+
+	lock      sync.Mutex
+	entries   []Entry
+	startedAt time.Time
+}
+
+// NewRecorder returns a recorder that adds the events received by the BBS
+// event recorder to the timeline, so that both share one subscription. It
+// must be created before the event recorder is started.
+func NewRecorder(bbsEvents *bbsevents.Recorder, componentLogs *componentlogs.Store) *Recorder {
+	r := &Recorder{
+		componentLogs: componentLogs,
+	}
+	bbsEvents.OnEvent(r.recordEvent)
+	return r
+}
+
+// Start starts the timeline. Component log lines written before Start are
+// left out.
+func (r *Recorder) Start() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.startedAt = time.Now()
+}
+
+// Record adds an entry to the timeline.
+func (r *Recorder) Record(entry Entry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.entries = append(r.entries, entry)
+}
+
+// Step records a test step.
+func (r *Recorder) Step(text string) {
+	r.Record(Entry{Kind: KindStep, Source: "test", Summary: text})
+}
+
+// By reports a step with ginkgo's By and records it. Steps reported with
+// ginkgo's By directly are not recorded.
+func (r *Recorder) By(text string, callbacks ...func()) {
+	r.Step(text)
+	ginkgo.By(text, callbacks...)
+}
+
+// Entries returns the recorded entries together with the component log
+// lines written since Start, in chronological order. Debug log lines are
+// left out unless they mention a guid that appears elsewhere on the
+// timeline.
+func (r *Recorder) Entries() []Entry {
+	r.lock.Lock()
+	entries := make([]Entry, len(r.entries))
+	copy(entries, r.entries)
+	startedAt := r.startedAt
+	r.lock.Unlock()
+
+	guids := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Guid != "" {
+			guids[entry.Guid] = true
+		}
+	}
+
+	if r.componentLogs != nil {
+		for _, line := range r.componentLogs.Logs().Where(componentlogs.Since(startedAt)) {
+			guid := lookupData(line.Data, "process-guid", "process_guid", "task-guid", "task_guid", "guid")
+			if line.LogLevel == "debug" && !guids[guid] {
+				continue
+			}
+
+			entries = append(entries, Entry{
+				Time:    line.Timestamp,
+				Kind:    KindLog,
+				Source:  line.Component,
+				Guid:    guid,
+				CellId:  lookupData(line.Data, "cell-id", "cell_id"),
+				Summary: fmt.Sprintf("%s %s", line.Message, formatData(line.Data)),
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
+
+func (r *Recorder) recordEvent(event models.Event) {
+	entry := describeEvent(event)
+	entry.Kind = KindBBSEvent
+	entry.Source = "bbs"
+	switch event.(type) {
+	case *models.TaskCreatedEvent, *models.TaskChangedEvent, *models.TaskRemovedEvent:
+		entry.Kind = KindTaskEvent
+	}
+	r.Record(entry)
+}
+
+func describeEvent(event models.Event) Entry {
+	entry := Entry{Summary: event.EventType()}
+
+	switch e := event.(type) {
+	case *models.DesiredLRPCreatedEvent:
+		entry.Guid = e.DesiredLrp.ProcessGuid
+		entry.Summary += fmt.Sprintf(" instances=%d", e.DesiredLrp.Instances)
+	case *models.DesiredLRPChangedEvent:
+		entry.Guid = e.After.ProcessGuid
+		entry.Summary += fmt.Sprintf(" instances=%d was=%d", e.After.Instances, e.Before.Instances)
+	case *models.DesiredLRPRemovedEvent:
+		entry.Guid = e.DesiredLrp.ProcessGuid
+	case *models.ActualLRPInstanceCreatedEvent:
+		describeActualLRP(&entry, e.ActualLrp)
+	case *models.ActualLRPInstanceChangedEvent:
+		entry.Guid = e.ProcessGuid
+		entry.CellId = e.CellId
+		describeActualLRPState(&entry, e.Index, e.After.State, e.After.Presence, e.After.PlacementError)
+		entry.Summary += " was=" + e.Before.State
+	case *models.ActualLRPInstanceRemovedEvent:
+		describeActualLRP(&entry, e.ActualLrp)
+	case *models.ActualLRPCrashedEvent:
+		entry.Guid = e.ProcessGuid
+		entry.CellId = e.CellId
+		entry.Summary += fmt.Sprintf(" index=%d crash-count=%d reason=%q", e.Index, e.CrashCount, e.CrashReason)
+	case *models.TaskCreatedEvent:
+		describeTask(&entry, e.Task)
+	case *models.TaskChangedEvent:
+		describeTask(&entry, e.After)
+		entry.Summary += fmt.Sprintf(" was=%s", e.Before.State)
+	case *models.TaskRemovedEvent:
+		describeTask(&entry, e.Task)
+	}
+
+	return entry
+}
+
+func describeActualLRP(entry *Entry, lrp *models.ActualLRP) {
+	entry.Guid = lrp.ProcessGuid
+	entry.CellId = lrp.CellId
+	describeActualLRPState(entry, lrp.Index, lrp.State, lrp.Presence, lrp.PlacementError)
+}
+
+func describeActualLRPState(entry *Entry, index int32, state string, presence models.ActualLRP_Presence, placementError string) {
+	entry.Summary += fmt.Sprintf(" index=%d state=%s", index, state)
+	if presence == models.ActualLRP_Evacuating {
+		entry.Summary += " evacuating"
+	}
+	if placementError != "" {
+		entry.Summary += fmt.Sprintf(" placement-error=%q", placementError)
+	}
+}
+
+func describeTask(entry *Entry, task *models.Task) {
+	entry.Guid = task.TaskGuid
+	entry.CellId = task.CellId
+	entry.Summary += fmt.Sprintf(" state=%s", task.State)
+	if task.Failed {
+		entry.Summary += fmt.Sprintf(" failure-reason=%q", task.FailureReason)
+	}
+}
+
+func lookupData(data map[string]interface{}, keys ...string) string {
+	for _, key := range keys {
+		if value, ok := data[key]; ok {
+			return fmt.Sprint(value)
+		}
+	}
+
+	for _, value := range data {
+		if nested, ok := value.(map[string]interface{}); ok {
+			for _, key := range keys {
+				if value, ok := nested[key]; ok {
+					return fmt.Sprint(value)
+				}
+			}
+		}
+	}
+
+	return ""
+}
+
+func formatData(data map[string]interface{}) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		if key != "session" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s=%v", key, data[key])
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,168 @@
+package timeline_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"code.cloudfoundry.org/bbs/events/eventfakes"
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/inigo/helpers/bbsevents"
+	"code.cloudfoundry.org/inigo/helpers/componentlogs"
+	"code.cloudfoundry.org/inigo/helpers/timeline"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+type bufferProvider struct {
+	buffer *gbytes.Buffer
+}
+
+func (p bufferProvider) Buffer() *gbytes.Buffer {
+	return p.buffer
+}
+
+func newEventSource(events chan models.Event) *eventfakes.FakeEventSource {
+	eventSource := &eventfakes.FakeEventSource{}
+	eventSource.NextStub = func() (models.Event, error) {
+		event, ok := <-events
+		if !ok {
+			return nil, errors.New("closed")
+		}
+		return event, nil
+	}
+	eventSource.CloseStub = func() error {
+		close(events)
+		return nil
+	}
+	return eventSource
+}
+
+var _ = Describe("Recorder", func() {
+	var (
+		bbsClient     *fake_bbs.FakeClient
+		lrpEvents     chan models.Event
+		taskEvents    chan models.Event
+		componentLogs *componentlogs.Store
+		repOut        *gbytes.Buffer
+		bbsEvents     *bbsevents.Recorder
+		recorder      *timeline.Recorder
+	)
+
+	BeforeEach(func() {
+		lrpEvents = make(chan models.Event, 10)
+		taskEvents = make(chan models.Event, 10)
+
+		bbsClient = &fake_bbs.FakeClient{}
+		bbsClient.SubscribeToInstanceEventsReturns(newEventSource(lrpEvents), nil)
+		bbsClient.SubscribeToTaskEventsReturns(newEventSource(taskEvents), nil)
+
+		repOut = gbytes.NewBuffer()
+		componentLogs = componentlogs.NewStore()
+		componentLogs.Capture("rep-0", 100, bufferProvider{repOut})
+		Eventually(componentLogs.Components).Should(ConsistOf("rep-0"))
+
+		bbsEvents = bbsevents.NewRecorder(lagertest.NewTestLogger("test"), bbsClient)
+		recorder = timeline.NewRecorder(bbsEvents, componentLogs)
+		recorder.Start()
+		Expect(bbsEvents.Start()).To(Succeed())
+	})
+
+	AfterEach(func() {
+		bbsEvents.Stop()
+	})
+
+	It("records BBS events, steps and component logs in order", func() {
+		lrpEvents <- models.NewDesiredLRPCreatedEvent(&models.DesiredLRP{ProcessGuid: "process-guid", Instances: 1})
+		Eventually(recorder.Entries).Should(HaveLen(1))
+
+		recorder.By("starting the app")
+
+		task := &models.Task{TaskGuid: "task-guid", State: models.Task_Running}
+		task.CellId = "cell-0"
+		taskEvents <- models.NewTaskCreatedEvent(task)
+		Eventually(recorder.Entries).Should(HaveLen(3))
+
+		time.Sleep(time.Millisecond)
+		repOut.Write([]byte(`{"timestamp":"` + epoch(time.Now()) + `","source":"rep","message":"rep.started-lrp","log_level":1,"data":{"process-guid":"process-guid","cell-id":"cell-0"}}` + "\n"))
+		repOut.Write([]byte(`{"timestamp":"` + epoch(time.Now()) + `","source":"rep","message":"rep.noise","log_level":0,"data":{}}` + "\n"))
+
+		entries := recorder.Entries()
+		Expect(entries).To(HaveLen(4))
+
+		Expect(entries[0].Kind).To(Equal(timeline.KindBBSEvent))
+		Expect(entries[0].Guid).To(Equal("process-guid"))
+
+		Expect(entries[1].Kind).To(Equal(timeline.KindStep))
+		Expect(entries[1].Summary).To(Equal("starting the app"))
+
+		Expect(entries[2].Kind).To(Equal(timeline.KindTaskEvent))
+		Expect(entries[2].Guid).To(Equal("task-guid"))
+		Expect(entries[2].CellId).To(Equal("cell-0"))
+
+		Expect(entries[3].Kind).To(Equal(timeline.KindLog))
+		Expect(entries[3].Source).To(Equal("rep-0"))
+		Expect(entries[3].Guid).To(Equal("process-guid"))
+		Expect(entries[3].CellId).To(Equal("cell-0"))
+	})
+
+	It("describes LRP instance changes", func() {
+		lrpEvents <- &models.ActualLRPInstanceChangedEvent{
+			ActualLRPKey:         models.NewActualLRPKey("process-guid", 1, "inigo"),
+			ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid", "cell-0"),
+			Before:               &models.ActualLRPInfo{State: models.ActualLRPStateClaimed},
+			After:                &models.ActualLRPInfo{State: models.ActualLRPStateRunning, Presence: models.ActualLRP_Evacuating},
+		}
+		Eventually(recorder.Entries).Should(HaveLen(1))
+
+		entry := recorder.Entries()[0]
+		Expect(entry.Kind).To(Equal(timeline.KindBBSEvent))
+		Expect(entry.Guid).To(Equal("process-guid"))
+		Expect(entry.CellId).To(Equal("cell-0"))
+		Expect(entry.Summary).To(Equal("actual_lrp_instance_changed index=1 state=RUNNING evacuating was=CLAIMED"))
+	})
+
+	Describe("rendering", func() {
+		var entries []timeline.Entry
+
+		BeforeEach(func() {
+			now := time.Now()
+			entries = []timeline.Entry{
+				{Time: now, Kind: timeline.KindStep, Source: "test", Summary: "<desiring>"},
+				{Time: now.Add(time.Second), Kind: timeline.KindBBSEvent, Source: "bbs", Guid: "process-guid", CellId: "cell-0", Summary: "actual_lrp_instance_changed"},
+			}
+		})
+
+		It("groups entries by guid and cell", func() {
+			groups := timeline.Groups(entries)
+			Expect(groups).To(HaveLen(2))
+			Expect(groups[0].Name).To(Equal("cell cell-0"))
+			Expect(groups[1].Name).To(Equal("guid process-guid"))
+			Expect(groups[1].Entries).To(Equal(entries[1:]))
+		})
+
+		It("renders text with a section per group", func() {
+			buffer := &bytes.Buffer{}
+			Expect(timeline.WriteText(buffer, entries)).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("=== all ==="))
+			Expect(buffer.String()).To(ContainSubstring("=== guid process-guid ==="))
+			Expect(buffer.String()).To(ContainSubstring("<desiring>"))
+		})
+
+		It("renders escaped html", func() {
+			buffer := &bytes.Buffer{}
+			Expect(timeline.WriteHTML(buffer, "my spec", entries)).To(Succeed())
+			Expect(buffer.String()).To(ContainSubstring("<title>my spec</title>"))
+			Expect(buffer.String()).To(ContainSubstring("&lt;desiring&gt;"))
+			Expect(buffer.String()).To(ContainSubstring(`<h2 id="group-1">guid process-guid</h2>`))
+		})
+	})
+})
+
+func epoch(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
@@ -16,6 +16,7 @@ import (
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/inigo/helpers"
 	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/leakcheck"
 	"code.cloudfoundry.org/inigo/helpers/portauthority"
 	"code.cloudfoundry.org/inigo/world"
 	"code.cloudfoundry.org/lager"
@@ -53,6 +54,7 @@ var (
 	driverPluginsPath string
 	csiPluginsPath    string
 	certDepot         string
+	leakChecker       *leakcheck.Checker
 )
 
 var _ = SynchronizedBeforeSuite(func() []byte {
@@ -106,7 +108,9 @@ var _ = SynchronizedBeforeSuite(func() []byte {
 	Expect(err).NotTo(HaveOccurred())
 
 	componentMaker = world.MakeComponentMaker(builtArtifacts, addresses, allocator, certAuthority)
+	leakChecker = helpers.NewLeakChecker(componentMaker, leakcheck.PortRange{From: uint16(startPort), To: uint16(endPort)})
 	componentMaker.Setup()
+	helpers.RegisterStopTimeoutDiagnostics(componentMaker.ComponentLogs(), componentMaker.ComponentStats())
 })
 
 var _ = AfterSuite(func() {
@@ -115,6 +119,13 @@ var _ = AfterSuite(func() {
 })
 
 var _ = BeforeEach(func() {
+	helpers.SnapshotLeaks(leakChecker)
+})
+
+var _ = BeforeEach(func() {
+	componentMaker.ComponentLogs().Reset()
+	componentMaker.ComponentStats().Reset()
+
 	logger = lagertest.NewTestLogger("volman-inigo-suite")
 
 	gardenProcess = ginkgomon.Invoke(componentMaker.Garden())
@@ -143,6 +154,9 @@ var _ = BeforeEach(func() {
 })
 
 var _ = AfterEach(func() {
+	helpers.ExportComponentLogsOnFailure(componentMaker.ComponentLogs())
+	helpers.ReportComponentStats(componentMaker.ComponentStats())
+
 	destroyContainerErrors := helpers.CleanupGarden(gardenClient)
 
 	helpers.StopProcesses(gardenProcess, driverSyncerProcess, localDriverProcess, localNodePluginProcess)
@@ -157,6 +171,10 @@ var _ = AfterEach(func() {
 	os.Remove(filepath.Join(csiPluginsPath, "dead-csi-plugin.json"))
 })
 
+var _ = AfterEach(func() {
+	helpers.CheckForLeaks(leakChecker)
+})
+
 func TestVolman(t *testing.T) {
 	helpers.RegisterDefaultTimeouts()
 
@@ -0,0 +1,81 @@
+package world
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	cfhttp "code.cloudfoundry.org/cfhttp/v2"
+	"code.cloudfoundry.org/tlsconfig"
+	"github.com/tedsuo/rata"
+)
+
+// BBSClient is a BBS client that can tell whether its BBS serves the
+// ActualLRPs endpoint, which older BBS versions lack.
+type BBSClient struct {
+	bbs.InternalClient
+
+	requestGenerator *rata.RequestGenerator
+	httpClient       *http.Client
+
+	lock               sync.Mutex
+	actualLRPsEndpoint *bool
+}
+
+func newBBSClient(client bbs.InternalClient, url string, sslConfig SSLConfig) (*BBSClient, error) {
+	tlsConfig, err := tlsconfig.Build(
+		tlsconfig.WithInternalServiceDefaults(),
+		tlsconfig.WithIdentityFromFile(sslConfig.ClientCert, sslConfig.ClientKey),
+	).Client(
+		tlsconfig.WithAuthorityFromFile(sslConfig.CACert),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BBSClient{
+		InternalClient:   client,
+		requestGenerator: rata.NewRequestGenerator(url, bbs.Routes),
+		httpClient:       cfhttp.NewClient(cfhttp.WithTLSConfig(tlsConfig), cfhttp.WithRequestTimeout(10*time.Second)),
+	}, nil
+}
+
+// HasActualLRPsEndpoint reports whether the BBS serves the ActualLRPs
+// endpoint. The first successful answer is kept for the lifetime of the
+// client, so every poller using the client asks BBS at most once.
+func (c *BBSClient) HasActualLRPsEndpoint() (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.actualLRPsEndpoint != nil {
+		return *c.actualLRPsEndpoint, nil
+	}
+
+	// an empty body is an ActualLRPsRequest without a filter
+	request, err := c.requestGenerator.CreateRequest(bbs.ActualLRPsRoute_r0, nil, http.NoBody)
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/x-protobuf")
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	var found bool
+	switch response.StatusCode {
+	case http.StatusOK:
+		found = true
+	case http.StatusNotFound:
+		found = false
+	default:
+		return false, fmt.Errorf("probing %s: unexpected status %s", request.URL.Path, response.Status)
+	}
+
+	c.actualLRPsEndpoint = &found
+	return found, nil
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/url"
 	"os"
 	"os/exec"
 	"path"
@@ -30,6 +31,7 @@ import (
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/consuladapter"
 	"code.cloudfoundry.org/consuladapter/consulrunner"
+	"code.cloudfoundry.org/debugserver"
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	sshproxyconfig "code.cloudfoundry.org/diego-ssh/cmd/ssh-proxy/config"
 	"code.cloudfoundry.org/diego-ssh/keys"
@@ -43,8 +45,17 @@ import (
 	gardenclient "code.cloudfoundry.org/garden/client"
 	gardenconnection "code.cloudfoundry.org/garden/client/connection"
 	"code.cloudfoundry.org/guardian/gqt/runner"
+	"code.cloudfoundry.org/inigo/helpers/bbsdb"
 	"code.cloudfoundry.org/inigo/helpers/certauthority"
+	"code.cloudfoundry.org/inigo/helpers/componentlogs"
+	"code.cloudfoundry.org/inigo/helpers/componentstats"
+	"code.cloudfoundry.org/inigo/helpers/fakecf"
+	"code.cloudfoundry.org/inigo/helpers/fakeloggregator"
 	"code.cloudfoundry.org/inigo/helpers/portauthority"
+	"code.cloudfoundry.org/inigo/helpers/procstat"
+	"code.cloudfoundry.org/inigo/helpers/routeservice"
+	"code.cloudfoundry.org/inigo/helpers/servicediscovery"
+	"code.cloudfoundry.org/inigo/helpers/sqlproxy"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagerflags"
 	"code.cloudfoundry.org/locket"
@@ -56,6 +67,7 @@ import (
 	routeemitterconfig "code.cloudfoundry.org/route-emitter/cmd/route-emitter/config"
 	routingapi "code.cloudfoundry.org/route-emitter/cmd/route-emitter/runners"
 	routingapiconfig "code.cloudfoundry.org/routing-api/config"
+	routingapimodels "code.cloudfoundry.org/routing-api/models"
 	"code.cloudfoundry.org/volman"
 	volmanclient "code.cloudfoundry.org/volman/vollocal"
 	"github.com/go-sql-driver/mysql"
@@ -85,6 +97,20 @@ type (
 
 const (
 	LifecycleFilename = "lifecycle.tar.gz"
+
+	routerStatusUser     = "router-status"
+	routerStatusPassword = "router-status-password"
+
+	tcpRouterPortCount = 5
+
+	// the debug servers of locket, the bbs and the auctioneer, followed by
+	// one per rep index
+	maxRepIndex         = 2
+	debugPortCount      = 3 + maxRepIndex + 1
+	locketDebugPort     = 0
+	bbsDebugPort        = 1
+	auctioneerDebugPort = 2
+	repDebugPort        = 3
 )
 
 type BuiltArtifacts struct {
@@ -169,6 +195,17 @@ func DBInfo() (string, string) {
 	return dbDriverName, dbBaseConnectionString
 }
 
+// DefaultEncryptionConfig is the encryption configuration BBS is started
+// with unless it is overridden.
+func DefaultEncryptionConfig() encryption.EncryptionConfig {
+	return encryption.EncryptionConfig{
+		ActiveKeyLabel: "secure-key-1",
+		EncryptionKeys: map[string]string{
+			"secure-key-1": "secure-passphrase",
+		},
+	}
+}
+
 func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses ComponentAddresses, allocator portauthority.PortAllocator, certAuthority certauthority.CertAuthority) commonComponentMaker {
 	startCheckTimeout := 10 * time.Second
 	if timeout, found := os.LookupEnv("START_CHECK_TIMEOUT_DURATION"); found && timeout != "" {
@@ -177,6 +214,13 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("%s not a valid duration", timeout))
 	}
 
+	statsInterval := time.Second
+	if interval, found := os.LookupEnv("COMPONENT_STATS_INTERVAL"); found && interval != "" {
+		var err error
+		statsInterval, err = time.ParseDuration(interval)
+		Expect(err).NotTo(HaveOccurred(), fmt.Sprintf("%s not a valid duration", interval))
+	}
+
 	grootfsBinPath := os.Getenv("GROOTFS_BINPATH")
 	grootfsStorePath := os.Getenv("GROOTFS_STORE_PATH")
 	gardenBinPath := os.Getenv("GARDEN_BINPATH")
@@ -233,6 +277,30 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 
 	sqlCACert := filepath.Join(os.Getenv("GOPATH"), "src", "code.cloudfoundry.org", "inigo", "fixtures", "certs", "sql-certs", "server-ca.crt")
 
+	metronCertsPath := filepath.Join(os.Getenv("GOPATH"), "src", "code.cloudfoundry.org", "inigo", "fixtures", "certs", "metron")
+	loggregatorSSLConfig := SSLConfig{
+		ServerCert: filepath.Join(metronCertsPath, "metron.crt"),
+		ServerKey:  filepath.Join(metronCertsPath, "metron.key"),
+		ClientCert: filepath.Join(metronCertsPath, "client.crt"),
+		ClientKey:  filepath.Join(metronCertsPath, "client.key"),
+		CACert:     filepath.Join(metronCertsPath, "CA.crt"),
+	}
+
+	loggregatorPort, err := allocator.ClaimPorts(1)
+	Expect(err).NotTo(HaveOccurred())
+
+	routerStatusPort, err := allocator.ClaimPorts(1)
+	Expect(err).NotTo(HaveOccurred())
+
+	routerTLSPort, err := allocator.ClaimPorts(1)
+	Expect(err).NotTo(HaveOccurred())
+
+	tcpRouterPorts, err := allocator.ClaimPorts(tcpRouterPortCount)
+	Expect(err).NotTo(HaveOccurred())
+
+	debugPorts, err := allocator.ClaimPorts(debugPortCount)
+	Expect(err).NotTo(HaveOccurred())
+
 	bbsSSLConfig := SSLConfig{
 		ServerCert: bbsServerCert,
 		ServerKey:  bbsServerKey,
@@ -326,14 +394,24 @@ func makeCommonComponentMaker(builtArtifacts BuiltArtifacts, worldAddresses Comp
 		repSSL:                 repSSLConfig,
 		auctioneerSSL:          auctioneerSSLConfig,
 		routingAPISSL:          routingApiSSLConfig,
+		loggregatorSSL:         loggregatorSSLConfig,
+		loggregatorPort:        int(loggregatorPort),
+		routerStatusPort:       int(routerStatusPort),
+		routerTLSPort:          int(routerTLSPort),
+		tcpRouterPorts:         int(tcpRouterPorts),
+		debugPorts:             int(debugPorts),
 		sqlCACertFile:          sqlCACert,
 		volmanDriverConfigDir:  volmanConfigDir,
 		dbDriverName:           dbDriverName,
 		dbBaseConnectionString: dbBaseConnectionString,
 
 		portAllocator: allocator,
+		certAuthority: certAuthority,
 
 		startCheckTimeout: startCheckTimeout,
+
+		componentLogs:  componentlogs.NewStore(),
+		componentStats: componentstats.NewSampler(statsInterval),
 	}
 }
 
@@ -357,7 +435,10 @@ type ComponentMaker interface {
 	RepClientFactory() rep.ClientFactory
 	BBSServiceClient(logger lager.Logger) serviceclient.ServiceClient
 	BBSURL() string
+	BBSDB(logger lager.Logger) *bbsdb.DB
 	BBSSSLConfig() SSLConfig
+	ComponentLogs() *componentlogs.Store
+	ComponentStats() *componentstats.Sampler
 	Consul(argv ...string) ifrit.Runner
 	ConsulCluster() string
 	CsiLocalNodePlugin(logger lager.Logger) ifrit.Runner
@@ -368,17 +449,39 @@ type ComponentMaker interface {
 	GardenWithoutDefaultStack() ifrit.Runner
 	GrootFSDeleteStore()
 	GrootFSInitStore()
+	GrootFSStorePaths() []string
 	Locket(modifyConfigFuncs ...func(*locketconfig.LocketConfig)) ifrit.Runner
+	Loggregator() *fakeloggregator.Ingress
 	NATS(argv ...string) ifrit.Runner
-	Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner
-	RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner
+	NATSCluster(size int, modifyConfigFuncs ...func(*NATSConfig)) *NATSCluster
+	NATSTLS() func(*NATSConfig)
+	Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ComponentRunner
+	RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ComponentRunner
 	RepSSLConfig() SSLConfig
 	RouteEmitter(fs ...func(config *routeemitterconfig.RouteEmitterConfig)) ifrit.Runner
 	RouteEmitterN(n int, fs ...func(config *routeemitterconfig.RouteEmitterConfig)) ifrit.Runner
-	Router() ifrit.Runner
-	RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) *routingapi.RoutingAPIRunner
+	ServiceDiscovery() *servicediscovery.Controller
+	Router(modifyConfigFuncs ...func(*RouterConfig)) ifrit.Runner
+	RouterStatusURL() string
+	RouterTLSAddress() string
+	RouterTLS(hostnames ...string) func(*RouterConfig)
+	RouterBackendTLS(caCertFiles ...string) func(*RouterConfig)
+	CACert() string
+	RouteService() *routeservice.Server
+	RouterRouteServices(secret string) func(*RouterConfig)
+	RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) *RoutingAPIRunner
+	TCPRouter(routingAPIPort int, modifyConfigFuncs ...func(*TCPRouterConfig)) ifrit.Runner
+	TCPRouterExternalPorts() []uint32
+	TCPRouterAddress(externalPort uint32) string
 	SQL(argv ...string) ifrit.Runner
+	SQLConnectionString() string
+	SQLProxy() *sqlproxy.Proxy
+	BBSThroughSQLProxy(proxy *sqlproxy.Proxy) func(*bbsconfig.BBSConfig)
+	LocketThroughSQLProxy(proxy *sqlproxy.Proxy) func(*locketconfig.LocketConfig)
 	SSHProxy(modifyConfigFuncs ...func(*sshproxyconfig.SSHProxyConfig)) ifrit.Runner
+	FakeUAA() *fakecf.UAA
+	FakeCC(uaa *fakecf.UAA) *fakecf.CloudController
+	SSHProxyCFAuth(cc *fakecf.CloudController, uaa *fakecf.UAA) func(*sshproxyconfig.SSHProxyConfig)
 	Setup()
 	Teardown()
 	VolmanClient(logger lager.Logger) (volman.Manager, ifrit.Runner)
@@ -396,12 +499,21 @@ type commonComponentMaker struct {
 	repSSL                 SSLConfig
 	auctioneerSSL          SSLConfig
 	routingAPISSL          SSLConfig
+	loggregatorSSL         SSLConfig
+	loggregatorPort        int
+	routerStatusPort       int
+	routerTLSPort          int
+	tcpRouterPorts         int
+	debugPorts             int
 	sqlCACertFile          string
 	volmanDriverConfigDir  string
 	dbDriverName           string
 	dbBaseConnectionString string
 	portAllocator          portauthority.PortAllocator
+	certAuthority          certauthority.CertAuthority
 	startCheckTimeout      time.Duration
+	componentLogs          *componentlogs.Store
+	componentStats         *componentstats.Sampler
 }
 
 func (maker commonComponentMaker) VolmanDriverConfigDir() string {
@@ -432,13 +544,190 @@ func (maker commonComponentMaker) RepSSLConfig() SSLConfig {
 	return maker.repSSL
 }
 
+// ComponentLogs returns the store capturing the output of every component
+// started from this maker.
+func (maker commonComponentMaker) ComponentLogs() *componentlogs.Store {
+	return maker.componentLogs
+}
+
+// ComponentStats returns the sampler recording the resource usage of every
+// component started from this maker.
+func (maker commonComponentMaker) ComponentStats() *componentstats.Sampler {
+	return maker.componentStats
+}
+
+// ComponentRunner is the ginkgomon.Runner of a component whose logs and
+// resource usage are captured once it has started.
+type ComponentRunner struct {
+	*ginkgomon.Runner
+	onStart  func(*ginkgomon.Runner)
+	onSignal func(*ginkgomon.Runner)
+}
+
+// Run runs the component, calling onStart once it has passed its start
+// check and before reporting it ready, and onSignal before a signal is
+// passed on to a started component. Runners that are never run capture
+// nothing.
+func (r *ComponentRunner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	return runWithHooks(r.Runner, signals, ready, func() {
+		r.onStart(r.Runner)
+	}, func() {
+		r.onSignal(r.Runner)
+	})
+}
+
+// runWithHooks runs runner, calling onStart once it is ready and before
+// reporting it ready, and onSignal, after onStart, before a signal is
+// passed on to a started runner.
+func runWithHooks(runner ifrit.Runner, signals <-chan os.Signal, ready chan<- struct{}, onStart, onSignal func()) error {
+	started := make(chan struct{})
+	hooked := make(chan struct{})
+	exited := make(chan struct{})
+	forwarded := make(chan struct{})
+	innerSignals := make(chan os.Signal)
+
+	go func() {
+		for {
+			select {
+			case signal := <-signals:
+				select {
+				case <-started:
+					<-hooked
+					onSignal()
+				default:
+				}
+
+				select {
+				case innerSignals <- signal:
+				case <-exited:
+					return
+				}
+			case <-exited:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(forwarded)
+
+		select {
+		case <-started:
+		case <-exited:
+			// the runner may have started and exited already
+			select {
+			case <-started:
+			default:
+				return
+			}
+		}
+
+		onStart()
+		close(hooked)
+		close(ready)
+	}()
+
+	err := runner.Run(innerSignals, started)
+	close(exited)
+	<-forwarded
+	return err
+}
+
+// RoutingAPIRunner is a routing API whose resource usage is tracked. The
+// routing API creates its ginkgomon.Runner inside Run, so its pid is found
+// among the children of the suite once it is ready and its output is not
+// captured.
+type RoutingAPIRunner struct {
+	*routingapi.RoutingAPIRunner
+	binPath string
+	stats   *componentstats.Sampler
+}
+
+func (r *RoutingAPIRunner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	pid := 0
+	return runWithHooks(r.RoutingAPIRunner, signals, ready, func() {
+		pid = childPid(r.binPath)
+		if pid != 0 {
+			r.stats.TrackPid("routing-api", pid, "")
+		}
+	}, func() {
+		if pid != 0 {
+			r.stats.MarkStopping(pid)
+		}
+	})
+}
+
+// childPid returns the pid of the most recently started child of the suite
+// running binPath, or 0 if there is none.
+func childPid(binPath string) int {
+	stats, err := procstat.All()
+	if err != nil {
+		return 0
+	}
+
+	if resolved, err := filepath.EvalSymlinks(binPath); err == nil {
+		binPath = resolved
+	}
+
+	pid := 0
+	startTime := uint64(0)
+	for _, stat := range stats {
+		if stat.PPid != os.Getpid() || stat.StartTime < startTime {
+			continue
+		}
+		exe, err := os.Readlink(filepath.Join("/proc", strconv.Itoa(stat.Pid), "exe"))
+		if err != nil || exe != binPath {
+			continue
+		}
+		pid = stat.Pid
+		startTime = stat.StartTime
+	}
+	return pid
+}
+
+func (maker commonComponentMaker) track(runner *ginkgomon.Runner) *ComponentRunner {
+	return maker.trackWithDebugServer(runner, "")
+}
+
+// trackWithDebugServer captures the logs and resource usage of the
+// component once it starts. The debug address is used to count the
+// component's goroutines.
+func (maker commonComponentMaker) trackWithDebugServer(runner *ginkgomon.Runner, debugAddress string) *ComponentRunner {
+	return &ComponentRunner{
+		Runner: runner,
+		onStart: func(runner *ginkgomon.Runner) {
+			pid := runner.Command.Process.Pid
+			maker.componentLogs.Capture(runner.Name, pid, runner)
+			maker.componentLogs.CaptureStderr(runner.Name, pid, runner)
+			maker.componentStats.TrackPid(runner.Name, pid, debugAddress)
+		},
+		onSignal: func(runner *ginkgomon.Runner) {
+			maker.componentStats.MarkStopping(runner.Command.Process.Pid)
+		},
+	}
+}
+
+// debugAddress returns the debug server address at the given offset into
+// the debug ports, which are claimed once so that every run of a component
+// reuses its port.
+func (maker commonComponentMaker) debugAddress(offset int) string {
+	return fmt.Sprintf("127.0.0.1:%d", maker.debugPorts+offset)
+}
+
+func (maker commonComponentMaker) repDebugAddress(n int) string {
+	Expect(n).To(BeNumerically("<=", maxRepIndex), "no debug port for rep-%d", n)
+	return maker.debugAddress(repDebugPort + n)
+}
+
 func (maker commonComponentMaker) Setup() {
 	if runtime.GOOS != "windows" {
 		maker.GrootFSInitStore()
 	}
+	maker.componentStats.Start()
 }
 
 func (maker commonComponentMaker) Teardown() {
+	maker.componentStats.Stop()
 	if runtime.GOOS != "windows" {
 		maker.GrootFSDeleteStore()
 	}
@@ -448,8 +737,12 @@ func (maker commonComponentMaker) NATS(argv ...string) ifrit.Runner {
 	host, port, err := net.SplitHostPort(maker.addresses.NATS)
 	Expect(err).NotTo(HaveOccurred())
 
-	return ginkgomon.New(ginkgomon.Config{
-		Name:              "gnatsd",
+	return maker.natsRunner("gnatsd", host, port, argv...)
+}
+
+func (maker commonComponentMaker) natsRunner(name, host, port string, argv ...string) *ComponentRunner {
+	return maker.track(ginkgomon.New(ginkgomon.Config{
+		Name:              name,
 		AnsiColorCode:     "30m",
 		StartCheck:        "gnatsd is ready",
 		StartCheckTimeout: maker.startCheckTimeout,
@@ -460,9 +753,11 @@ func (maker commonComponentMaker) NATS(argv ...string) ifrit.Runner {
 				"--port", port,
 			}, argv...)...,
 		),
-	})
+	}))
 }
 
+// SQL creates this node's diego database on the shared SQL server and drops
+// it on exit. It starts no process of its own, so there is nothing to track.
 func (maker commonComponentMaker) SQL(argv ...string) ifrit.Runner {
 	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 		defer GinkgoRecover()
@@ -502,6 +797,44 @@ func (maker commonComponentMaker) SQL(argv ...string) ifrit.Runner {
 	})
 }
 
+// SQLConnectionString returns the connection string for this node's
+// diego database, including the SQL CA.
+func (maker commonComponentMaker) SQLConnectionString() string {
+	return appendExtraConnectionStringParam(maker.dbDriverName, maker.addresses.SQL, maker.sqlCACertFile)
+}
+
+// SQLProxy returns a proxy in front of the SQL server that can inject
+// faults into the queries of the components pointed at it with
+// BBSThroughSQLProxy and LocketThroughSQLProxy.
+func (maker commonComponentMaker) SQLProxy() *sqlproxy.Proxy {
+	port, err := maker.portAllocator.ClaimPorts(1)
+	Expect(err).NotTo(HaveOccurred())
+
+	return sqlproxy.New(
+		maker.dbDriverName,
+		fmt.Sprintf("127.0.0.1:%d", port),
+		sqlServerAddress(maker.dbDriverName, maker.dbBaseConnectionString),
+	)
+}
+
+func (maker commonComponentMaker) BBSThroughSQLProxy(proxy *sqlproxy.Proxy) func(*bbsconfig.BBSConfig) {
+	return func(cfg *bbsconfig.BBSConfig) {
+		cfg.DatabaseConnectionString = proxiedConnectionString(maker.dbDriverName, maker.addresses.SQL, proxy.Address())
+		// the proxy cannot inspect encrypted connections
+		cfg.SQLCACertFile = ""
+	}
+}
+
+func (maker commonComponentMaker) LocketThroughSQLProxy(proxy *sqlproxy.Proxy) func(*locketconfig.LocketConfig) {
+	return func(cfg *locketconfig.LocketConfig) {
+		cfg.DatabaseConnectionString = proxiedConnectionString(maker.dbDriverName, maker.addresses.SQL, proxy.Address())
+		// the proxy cannot inspect encrypted connections
+		cfg.SQLCACertFile = ""
+	}
+}
+
+// Consul runs a single node consul cluster. It is not tracked: the cluster
+// runner starts and stops the agent itself without exposing its process.
 func (maker commonComponentMaker) Consul(argv ...string) ifrit.Runner {
 	_, port, err := net.SplitHostPort(maker.addresses.Consul)
 	Expect(err).NotTo(HaveOccurred())
@@ -540,6 +873,18 @@ func (maker commonComponentMaker) Consul(argv ...string) ifrit.Runner {
 	})
 }
 
+// GrootFSStorePaths returns the grootfs stores garden creates container
+// images in.
+func (maker commonComponentMaker) GrootFSStorePaths() []string {
+	if runtime.GOOS == "windows" {
+		return []string{maker.gardenConfig.GrootFSStorePath}
+	}
+	return []string{
+		maker.gardenConfig.UnprivilegedGrootfsConfig.StorePath,
+		maker.gardenConfig.PrivilegedGrootfsConfig.StorePath,
+	}
+}
+
 func (maker commonComponentMaker) GrootFSInitStore() {
 	err := maker.grootfsInitStore(maker.gardenConfig.UnprivilegedGrootfsConfig)
 	Expect(err).NotTo(HaveOccurred())
@@ -702,12 +1047,12 @@ func (maker commonComponentMaker) garden(includeDefaultStack bool, fs ...func(*r
 	gardenRunner.Runner.StartCheck = "guardian.started"
 	gardenRunner.Runner.StartCheckTimeout = maker.startCheckTimeout
 
-	members = append(members, grouper.Member{Name: "garden", Runner: gardenRunner})
+	members = append(members, grouper.Member{Name: "garden", Runner: maker.track(gardenRunner.Runner)})
 
 	return grouper.NewOrdered(os.Interrupt, members)
 }
 
-func (maker commonComponentMaker) RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) *routingapi.RoutingAPIRunner {
+func (maker commonComponentMaker) RoutingAPI(modifyConfigFuncs ...func(*routingapi.Config)) *RoutingAPIRunner {
 	binPath := maker.artifacts.Executables["routing-api"]
 
 	sqlConfig := routingapi.SQLConfig{
@@ -728,6 +1073,16 @@ func (maker commonComponentMaker) RoutingAPI(modifyConfigFuncs ...func(*routinga
 		sqlConfig.Password = "diego_pw"
 	}
 
+	modifyConfigFuncs = append([]func(*routingapi.Config){func(c *routingapi.Config) {
+		c.RouterGroups = routingapimodels.RouterGroups{
+			{
+				Name:            "default-tcp",
+				Type:            "tcp",
+				ReservablePorts: routingapimodels.ReservablePorts(fmt.Sprintf("%d-%d", maker.tcpRouterPorts, maker.tcpRouterPorts+tcpRouterPortCount-1)),
+			},
+		}
+	}}, modifyConfigFuncs...)
+
 	modifyConfigFuncs = append(modifyConfigFuncs, func(c *routingapi.Config) {
 		c.Locket = maker.locketClientConfig()
 	})
@@ -746,11 +1101,17 @@ func (maker commonComponentMaker) RoutingAPI(modifyConfigFuncs ...func(*routinga
 
 	runner, err := routingapi.NewRoutingAPIRunner(binPath, int(port+1), sqlConfig, modifyConfigFuncs...)
 	Expect(err).NotTo(HaveOccurred())
-	return runner
+	return &RoutingAPIRunner{
+		RoutingAPIRunner: runner,
+		binPath:          binPath,
+		stats:            maker.componentStats,
+	}
 }
 
 func (maker commonComponentMaker) Locket(modifyConfigFuncs ...func(*locketconfig.LocketConfig)) ifrit.Runner {
-	return locketrunner.NewLocketRunner(maker.artifacts.Executables["locket"], func(cfg *locketconfig.LocketConfig) {
+	debugAddress := maker.debugAddress(locketDebugPort)
+
+	runner := locketrunner.NewLocketRunner(maker.artifacts.Executables["locket"], func(cfg *locketconfig.LocketConfig) {
 		cfg.CertFile = maker.locketSSL.ServerCert
 		cfg.KeyFile = maker.locketSSL.ServerKey
 		cfg.CaFile = maker.locketSSL.CACert
@@ -759,6 +1120,7 @@ func (maker commonComponentMaker) Locket(modifyConfigFuncs ...func(*locketconfig
 		cfg.DatabaseDriver = maker.dbDriverName
 		cfg.ListenAddress = maker.addresses.Locket
 		cfg.SQLCACertFile = maker.sqlCACertFile
+		cfg.DebugAddress = debugAddress
 		cfg.LagerConfig = lagerflags.LagerConfig{
 			LogLevel: "debug",
 		}
@@ -766,7 +1128,37 @@ func (maker commonComponentMaker) Locket(modifyConfigFuncs ...func(*locketconfig
 		for _, modifyConfig := range modifyConfigFuncs {
 			modifyConfig(cfg)
 		}
+		debugAddress = cfg.DebugAddress
 	})
+
+	return maker.trackWithDebugServer(runner, debugAddress)
+}
+
+// Loggregator returns a stand-in for the loggregator agent. The rep, BBS and
+// auctioneer are always configured to send their logs and metrics to it.
+func (maker commonComponentMaker) Loggregator() *fakeloggregator.Ingress {
+	ingress, err := fakeloggregator.New(
+		fmt.Sprintf("127.0.0.1:%d", maker.loggregatorPort),
+		maker.loggregatorSSL.ServerCert,
+		maker.loggregatorSSL.ServerKey,
+		maker.loggregatorSSL.CACert,
+	)
+	Expect(err).NotTo(HaveOccurred())
+	return ingress
+}
+
+func (maker commonComponentMaker) loggregatorConfig(sourceID string) loggingclient.Config {
+	return loggingclient.Config{
+		UseV2API:           true,
+		APIPort:            maker.loggregatorPort,
+		CACertPath:         maker.loggregatorSSL.CACert,
+		CertPath:           maker.loggregatorSSL.ClientCert,
+		KeyPath:            maker.loggregatorSSL.ClientKey,
+		JobOrigin:          sourceID,
+		SourceID:           sourceID,
+		BatchFlushInterval: 10 * time.Millisecond,
+		BatchMaxSize:       1,
+	}
 }
 
 func (maker commonComponentMaker) RouteEmitterN(n int, fs ...func(config *routeemitterconfig.RouteEmitterConfig)) ifrit.Runner {
@@ -809,7 +1201,7 @@ func (maker commonComponentMaker) RouteEmitterN(n int, fs ...func(config *routee
 	err = encoder.Encode(&cfg)
 	Expect(err).NotTo(HaveOccurred())
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:              name,
 		AnsiColorCode:     "36m",
 		StartCheck:        `"` + name + `.watcher.sync.complete"`,
@@ -822,7 +1214,23 @@ func (maker commonComponentMaker) RouteEmitterN(n int, fs ...func(config *routee
 			configFile.Close()
 			os.RemoveAll(configFile.Name())
 		},
-	})
+	}))
+}
+
+// RouteEmitterInternalRoutes has the route emitter register the internal
+// routes of LRPs for service discovery, e.g. with ServiceDiscovery.
+func RouteEmitterInternalRoutes(cfg *routeemitterconfig.RouteEmitterConfig) {
+	cfg.EnableInternalEmitter = true
+}
+
+// ServiceDiscovery creates a service discovery stand-in that resolves the
+// internal routes the route emitter registers over NATS, serving DNS on its
+// own port.
+func (maker commonComponentMaker) ServiceDiscovery() *servicediscovery.Controller {
+	port, err := maker.portAllocator.ClaimPorts(1)
+	Expect(err).NotTo(HaveOccurred())
+
+	return servicediscovery.New(maker.addresses.NATS, fmt.Sprintf("127.0.0.1:%d", port))
 }
 
 func (maker commonComponentMaker) FileServer() (ifrit.Runner, string) {
@@ -875,7 +1283,7 @@ func (maker commonComponentMaker) FileServer() (ifrit.Runner, string) {
 	err = encoder.Encode(&cfg)
 	Expect(err).NotTo(HaveOccurred())
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:              "file-server",
 		AnsiColorCode:     "92m",
 		StartCheck:        `"file-server.ready"`,
@@ -891,10 +1299,100 @@ func (maker commonComponentMaker) FileServer() (ifrit.Runner, string) {
 			err = os.RemoveAll(configFile.Name())
 			Expect(err).NotTo(HaveOccurred())
 		},
-	}), servedFilesDir
+	})), servedFilesDir
+}
+
+// RouterStatusURL is the base URL of the router's status server, including
+// its basic auth credentials. The route table is served at /routes.
+func (maker commonComponentMaker) RouterStatusURL() string {
+	return fmt.Sprintf("http://%s:%s@127.0.0.1:%d", routerStatusUser, routerStatusPassword, maker.routerStatusPort)
+}
+
+// RouterTLSAddress is the address of the router's TLS listener, once it is
+// enabled with RouterTLS.
+func (maker commonComponentMaker) RouterTLSAddress() string {
+	return fmt.Sprintf("127.0.0.1:%d", maker.routerTLSPort)
+}
+
+// CACert is the path of the CA that signs the certificates of the
+// components.
+func (maker commonComponentMaker) CACert() string {
+	_, caCert := maker.certAuthority.CAAndKey()
+	return caCert
+}
+
+// RouterTLS enables the router's TLS listener on RouterTLSAddress, serving a
+// certificate for the hostnames signed by CACert.
+func (maker commonComponentMaker) RouterTLS(hostnames ...string) func(*RouterConfig) {
+	keyFile, certFile, err := maker.certAuthority.GenerateSelfSignedCertAndKey("router", hostnames, false)
+	Expect(err).NotTo(HaveOccurred())
+
+	return func(cfg *RouterConfig) {
+		cfg.EnableSSL = true
+		cfg.SSLPort = uint16(maker.routerTLSPort)
+		cfg.TLSPEM = []RouterTLSPEM{readTLSPEM(certFile, keyFile)}
+		cfg.MinTLSVersion = "TLSv1.2"
+		cfg.CipherSuites = "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"
+	}
+}
+
+// RouterBackendTLS has the router connect to the TLS ports of app instances,
+// validating their instance identity certificates against the caCertFiles,
+// e.g. the instance identity CA, and presenting a client certificate signed
+// by CACert.
+func (maker commonComponentMaker) RouterBackendTLS(caCertFiles ...string) func(*RouterConfig) {
+	caCerts := ""
+	for _, caCertFile := range caCertFiles {
+		caCert, err := ioutil.ReadFile(caCertFile)
+		Expect(err).NotTo(HaveOccurred())
+		caCerts += string(caCert)
+	}
+
+	return func(cfg *RouterConfig) {
+		cfg.CACerts += caCerts
+		cfg.Backends.EnableTLS = true
+		cfg.Backends.TLSPEM = readTLSPEM(maker.bbsSSL.ClientCert, maker.bbsSSL.ClientKey)
+	}
+}
+
+// RouteService creates a route service on its own port, serving a
+// certificate signed by CACert. Run it and bind routes to its URL; the router
+// only sends them to it once RouterRouteServices is enabled.
+func (maker commonComponentMaker) RouteService() *routeservice.Server {
+	port, err := maker.portAllocator.ClaimPorts(1)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyFile, certFile, err := maker.certAuthority.GenerateSelfSignedCertAndKey("route_service", nil, false)
+	Expect(err).NotTo(HaveOccurred())
+
+	server, err := routeservice.New(fmt.Sprintf("127.0.0.1:%d", port), maker.addresses.Router, certFile, keyFile)
+	Expect(err).NotTo(HaveOccurred())
+	return server
 }
 
-func (maker commonComponentMaker) Router() ifrit.Runner {
+// RouterRouteServices has the router send requests for routes bound to a
+// route service through it, signing them with secret, and trust the
+// certificates of route services signed by CACert.
+func (maker commonComponentMaker) RouterRouteServices(secret string) func(*RouterConfig) {
+	caCert, err := ioutil.ReadFile(maker.CACert())
+	Expect(err).NotTo(HaveOccurred())
+
+	return func(cfg *RouterConfig) {
+		cfg.RouteServicesSecret = secret
+		cfg.CACerts += string(caCert)
+	}
+}
+
+func readTLSPEM(certFile, keyFile string) RouterTLSPEM {
+	cert, err := ioutil.ReadFile(certFile)
+	Expect(err).NotTo(HaveOccurred())
+	key, err := ioutil.ReadFile(keyFile)
+	Expect(err).NotTo(HaveOccurred())
+
+	return RouterTLSPEM{CertChain: string(cert), PrivateKey: string(key)}
+}
+
+func (maker commonComponentMaker) Router(modifyConfigFuncs ...func(*RouterConfig)) ifrit.Runner {
 	_, routerPort, err := net.SplitHostPort(maker.addresses.Router)
 	Expect(err).NotTo(HaveOccurred())
 
@@ -907,86 +1405,43 @@ func (maker commonComponentMaker) Router() ifrit.Runner {
 	natsPortInt, err := strconv.Atoi(natsPort)
 	Expect(err).NotTo(HaveOccurred())
 
-	routerConfig := `
-status:
-  port: 0
-  user: ""
-  pass: ""
-nats:
-- host: %s
-  port: %d
-  user: ""
-  pass: ""
-logging:
-  file: /dev/stdout
-  syslog: ""
-  level: info
-  loggregator_enabled: false
-  metron_address: 127.0.0.1:65534
-port: %d
-index: 0
-zone: ""
-tracing:
-  enable_zipkin: false
-trace_key: ""
-access_log:
-  file: ""
-  enable_streaming: false
-enable_access_log_streaming: false
-debug_addr: ""
-enable_proxy: false
-enable_ssl: false
-ssl_port: 0
-ssl_cert_path: ""
-ssl_key_path: ""
-sslcertificate:
-  certificate: []
-  privatekey: null
-  ocspstaple: []
-  signedcertificatetimestamps: []
-  leaf: null
-skip_ssl_validation: false
-cipher_suites: "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256"
-ciphersuites: []
-load_balancer_healthy_threshold: 0s
-publish_start_message_interval: 0s
-suspend_pruning_if_nats_unavailable: false
-prune_stale_droplets_interval: 5s
-droplet_stale_threshold: 10s
-publish_active_apps_interval: 0s
-start_response_delay_interval: 1s
-endpoint_timeout: 0s
-route_services_timeout: 0s
-secure_cookies: false
-oauth:
-  token_endpoint: ""
-  port: 0
-  skip_ssl_validation: false
-  client_name: ""
-  client_secret: ""
-  ca_certs: ""
-routing_api:
-  uri: ""
-  port: 0
-  auth_disabled: false
-route_services_secret: ""
-route_services_secret_decrypt_only: ""
-route_services_recommend_https: false
-extra_headers_to_log: []
-token_fetcher_max_retries: 0
-token_fetcher_retry_interval: 0s
-token_fetcher_expiration_buffer_time: 0
-pid_file: ""
-`
-	routerConfig = fmt.Sprintf(routerConfig, natsHost, uint16(natsPortInt), uint16(routerPortInt))
+	routerConfig := RouterConfig{
+		Status: RouterStatusConfig{
+			Port: uint16(maker.routerStatusPort),
+			User: routerStatusUser,
+			Pass: routerStatusPassword,
+		},
+		Nats: []RouterNatsConfig{
+			{Host: natsHost, Port: uint16(natsPortInt)},
+		},
+		Logging: RouterLoggingConfig{
+			File:          "/dev/stdout",
+			Level:         "info",
+			MetronAddress: "127.0.0.1:65534",
+		},
+		Port:                       uint16(routerPortInt),
+		CipherSuites:               "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA256",
+		BalancingAlgorithm:         RouterRoundRobin,
+		PruneStaleDropletsInterval: 5 * time.Second,
+		DropletStaleThreshold:      10 * time.Second,
+		StartResponseDelayInterval: 1 * time.Second,
+		ExtraHeadersToLog:          []string{},
+	}
+
+	for _, modifyConfig := range modifyConfigFuncs {
+		modifyConfig(&routerConfig)
+	}
+
+	data, err := yaml.Marshal(&routerConfig)
+	Expect(err).NotTo(HaveOccurred())
 
 	configFile, err := ioutil.TempFile(os.TempDir(), "router-config")
 	Expect(err).NotTo(HaveOccurred())
 	defer configFile.Close()
-	_, err = configFile.Write([]byte(routerConfig))
+	_, err = configFile.Write(data)
 	Expect(err).NotTo(HaveOccurred())
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:              "router",
 		AnsiColorCode:     "93m",
 		StartCheck:        "router.started",
@@ -999,9 +1454,125 @@ pid_file: ""
 			err := os.Remove(configFile.Name())
 			Expect(err).NotTo(HaveOccurred())
 		},
-	})
+	}))
+}
+
+// TCPRouterExternalPorts are the ports the default-tcp router group of
+// RoutingAPI reserves for TCP routes.
+func (maker commonComponentMaker) TCPRouterExternalPorts() []uint32 {
+	ports := []uint32{}
+	for i := 0; i < tcpRouterPortCount; i++ {
+		ports = append(ports, uint32(maker.tcpRouterPorts+i))
+	}
+	return ports
+}
+
+// TCPRouterAddress is the address TCPRouter accepts connections for the TCP
+// route with externalPort on.
+func (maker commonComponentMaker) TCPRouterAddress(externalPort uint32) string {
+	return fmt.Sprintf("127.0.0.1:%d", externalPort)
+}
+
+// TCPRouter runs the TCP router against the routing API listening on
+// routingAPIPort, e.g. RoutingAPI().Config.API.ListenPort. It configures an
+// haproxy from the PATH to forward TCPRouterExternalPorts to the backends of
+// the TCP routes, and stops the haproxy when it exits. Routes take effect
+// asynchronously, so poll them with Eventually. It fails the current spec
+// when haproxy is not on the PATH.
+func (maker commonComponentMaker) TCPRouter(routingAPIPort int, modifyConfigFuncs ...func(*TCPRouterConfig)) ifrit.Runner {
+	haproxyPath, err := exec.LookPath("haproxy")
+	Expect(err).NotTo(HaveOccurred(), "the TCP router needs haproxy on the PATH")
+
+	configDir := TempDir("tcp-router")
+	baseConfigPath := filepath.Join(configDir, "haproxy.conf.template")
+	haproxyConfigPath := filepath.Join(configDir, "haproxy.conf")
+	pidFile := filepath.Join(configDir, "haproxy.pid")
+	reloaderPath := filepath.Join(configDir, "haproxy_reloader")
+
+	err = ioutil.WriteFile(baseConfigPath, []byte(tcpRouterHAProxyBaseConfig), 0644)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = ioutil.WriteFile(haproxyConfigPath, []byte(tcpRouterHAProxyBaseConfig), 0644)
+	Expect(err).NotTo(HaveOccurred())
+
+	reloader := fmt.Sprintf(tcpRouterHAProxyReloader, haproxyPath, haproxyConfigPath, pidFile)
+	err = ioutil.WriteFile(reloaderPath, []byte(reloader), 0755)
+	Expect(err).NotTo(HaveOccurred())
+
+	tcpRouterConfig := TCPRouterConfig{
+		RoutingAPI: RouterRoutingAPIConfig{
+			URI:          "http://127.0.0.1",
+			Port:         routingAPIPort,
+			AuthDisabled: true,
+		},
+		HAProxyPIDFile:               pidFile,
+		IsolationSegments:            []string{},
+		ReservedSystemComponentPorts: []int{},
+	}
+
+	for _, modifyConfig := range modifyConfigFuncs {
+		modifyConfig(&tcpRouterConfig)
+	}
+
+	data, err := yaml.Marshal(&tcpRouterConfig)
+	Expect(err).NotTo(HaveOccurred())
+
+	configPath := filepath.Join(configDir, "tcp_router.yml")
+	err = ioutil.WriteFile(configPath, data, 0644)
+	Expect(err).NotTo(HaveOccurred())
+
+	return maker.track(ginkgomon.New(ginkgomon.Config{
+		Name:          "tcp-router",
+		AnsiColorCode: "94m",
+		Command: exec.Command(
+			maker.artifacts.Executables["tcp-router"],
+			"-config", configPath,
+			"-tcpLoadBalancerBaseConfig", baseConfigPath,
+			"-tcpLoadBalancerConfig", haproxyConfigPath,
+			"-haproxyReloader", reloaderPath,
+			"-syncInterval", "1s",
+			"-logLevel", "debug",
+		),
+		Cleanup: func() {
+			if data, err := ioutil.ReadFile(pidFile); err == nil {
+				if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil {
+					if haproxy, err := os.FindProcess(pid); err == nil {
+						haproxy.Kill()
+					}
+				}
+			}
+			err := os.RemoveAll(configDir)
+			Expect(err).NotTo(HaveOccurred())
+		},
+	}))
 }
 
+const tcpRouterHAProxyBaseConfig = `global
+  maxconn 4096
+
+defaults
+  mode tcp
+  timeout connect 5s
+  timeout client 1m
+  timeout server 1m
+`
+
+// tcpRouterHAProxyReloader starts haproxy, or has a new haproxy take over
+// from the running one, with the configuration the TCP router wrote.
+const tcpRouterHAProxyReloader = `#!/bin/bash
+set -e
+
+haproxy=%s
+config=%s
+pidfile=%s
+
+if [ -s "$pidfile" ]; then
+  "$haproxy" -f "$config" -D -p "$pidfile" -sf $(cat "$pidfile")
+else
+  "$haproxy" -f "$config" -D -p "$pidfile"
+fi
+`
+
 func (maker commonComponentMaker) SSHProxy(modifyConfigFuncs ...func(*sshproxyconfig.SSHProxyConfig)) ifrit.Runner {
 	sshProxyConfig := sshproxyconfig.SSHProxyConfig{
 		Address:            maker.addresses.SSHProxy,
@@ -1033,7 +1604,7 @@ func (maker commonComponentMaker) SSHProxy(modifyConfigFuncs ...func(*sshproxyco
 	err = encoder.Encode(&sshProxyConfig)
 	Expect(err).NotTo(HaveOccurred())
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:              "ssh-proxy",
 		AnsiColorCode:     "96m",
 		StartCheck:        "ssh-proxy.started",
@@ -1044,7 +1615,43 @@ func (maker commonComponentMaker) SSHProxy(modifyConfigFuncs ...func(*sshproxyco
 				"-config", configFile.Name(),
 			})...,
 		),
-	})
+	}))
+}
+
+// FakeUAA creates a UAA stand-in on its own port, serving a certificate
+// signed by CACert, that issues the one-time codes CF ssh users log in with.
+func (maker commonComponentMaker) FakeUAA() *fakecf.UAA {
+	port, err := maker.portAllocator.ClaimPorts(1)
+	Expect(err).NotTo(HaveOccurred())
+
+	keyFile, certFile, err := maker.certAuthority.GenerateSelfSignedCertAndKey("uaa", nil, false)
+	Expect(err).NotTo(HaveOccurred())
+
+	uaa, err := fakecf.NewUAA(fmt.Sprintf("127.0.0.1:%d", port), certFile, keyFile, "ssh-proxy", "ssh-proxy-secret")
+	Expect(err).NotTo(HaveOccurred())
+	return uaa
+}
+
+// FakeCC creates a Cloud Controller stand-in on its own port that authorizes
+// ssh access to the apps added to it with the tokens issued by uaa.
+func (maker commonComponentMaker) FakeCC(uaa *fakecf.UAA) *fakecf.CloudController {
+	port, err := maker.portAllocator.ClaimPorts(1)
+	Expect(err).NotTo(HaveOccurred())
+
+	return fakecf.NewCloudController(fmt.Sprintf("127.0.0.1:%d", port), uaa)
+}
+
+// SSHProxyCFAuth has the ssh proxy authenticate CF users, i.e.
+// cf:<app guid>/<index> with a one-time code from uaa, by asking cc.
+func (maker commonComponentMaker) SSHProxyCFAuth(cc *fakecf.CloudController, uaa *fakecf.UAA) func(*sshproxyconfig.SSHProxyConfig) {
+	return func(cfg *sshproxyconfig.SSHProxyConfig) {
+		cfg.EnableCFAuth = true
+		cfg.CCAPIURL = cc.URL()
+		cfg.UAATokenURL = uaa.TokenURL()
+		cfg.UAAUsername = uaa.ClientID()
+		cfg.UAAPassword = uaa.ClientSecret()
+		cfg.UAACACert = maker.CACert()
+	}
 }
 
 func (maker commonComponentMaker) DefaultStack() string {
@@ -1056,6 +1663,7 @@ func (maker commonComponentMaker) GardenClient() garden.Client {
 	return gardenclient.New(gardenconnection.New("tcp", maker.addresses.Garden))
 }
 
+// BBSClient returns a *BBSClient.
 func (maker commonComponentMaker) BBSClient() bbs.InternalClient {
 	client, err := bbs.NewClient(
 		maker.BBSURL(),
@@ -1065,7 +1673,10 @@ func (maker commonComponentMaker) BBSClient() bbs.InternalClient {
 		0, 0,
 	)
 	Expect(err).NotTo(HaveOccurred())
-	return client
+
+	bbsClient, err := newBBSClient(client, maker.BBSURL(), maker.bbsSSL)
+	Expect(err).NotTo(HaveOccurred())
+	return bbsClient
 }
 
 func (maker commonComponentMaker) RepClientFactory() rep.ClientFactory {
@@ -1099,6 +1710,14 @@ func (maker commonComponentMaker) BBSURL() string {
 	return "https://" + maker.addresses.BBS
 }
 
+// BBSDB opens this node's diego database for inspection, decrypting with
+// DefaultEncryptionConfig.
+func (maker commonComponentMaker) BBSDB(logger lager.Logger) *bbsdb.DB {
+	db, err := bbsdb.Open(logger, maker.dbDriverName, maker.SQLConnectionString(), DefaultEncryptionConfig())
+	Expect(err).NotTo(HaveOccurred())
+	return db
+}
+
 func (maker commonComponentMaker) ConsulCluster() string {
 	return "http://" + maker.addresses.Consul
 }
@@ -1118,7 +1737,7 @@ func (maker commonComponentMaker) VolmanDriver(logger lager.Logger) (ifrit.Runne
 	debugServerPort, err := maker.portAllocator.ClaimPorts(1)
 	Expect(err).NotTo(HaveOccurred())
 	debugServerAddress := fmt.Sprintf("0.0.0.0:%d", debugServerPort)
-	fakeDriverRunner := ginkgomon.New(ginkgomon.Config{
+	fakeDriverRunner := maker.trackWithDebugServer(ginkgomon.New(ginkgomon.Config{
 		Name: "local-driver",
 		Command: exec.Command(
 			maker.artifacts.Executables["local-driver"],
@@ -1131,7 +1750,7 @@ func (maker commonComponentMaker) VolmanDriver(logger lager.Logger) (ifrit.Runne
 			"-uniqueVolumeIds",
 		),
 		StartCheck: "localdriver-server.started",
-	})
+	}), debugServerAddress)
 
 	client, err := driverhttp.NewRemoteClient("http://"+maker.addresses.FakeVolmanDriver, nil)
 	Expect(err).NotTo(HaveOccurred())
@@ -1140,7 +1759,7 @@ func (maker commonComponentMaker) VolmanDriver(logger lager.Logger) (ifrit.Runne
 }
 
 func (maker commonComponentMaker) CsiLocalNodePlugin(logger lager.Logger) ifrit.Runner {
-	localNodePluginRunner := ginkgomon.New(ginkgomon.Config{
+	localNodePluginRunner := maker.track(ginkgomon.New(ginkgomon.Config{
 		Name: "local-node-plugin",
 		Command: exec.Command(
 			maker.artifacts.Executables["local-node-plugin"],
@@ -1149,7 +1768,7 @@ func (maker commonComponentMaker) CsiLocalNodePlugin(logger lager.Logger) ifrit.
 			"-volumesRoot", path.Join(maker.volmanDriverConfigDir, fmt.Sprintf("local-node-volumes-%d", config.GinkgoConfig.ParallelNode)),
 		),
 		StartCheck: "local-node-plugin.started",
-	})
+	}))
 
 	return localNodePluginRunner
 }
@@ -1208,7 +1827,7 @@ func (maker v0ComponentMaker) Auctioneer(modifyConfigFuncs ...func(*auctioneerco
 		"-startingContainerWeight", strconv.FormatFloat(cfg.StartingContainerWeight, 'f', -1, 64),
 	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:              "auctioneer",
 		AnsiColorCode:     "35m",
 		StartCheck:        `"auctioneer.started"`,
@@ -1217,7 +1836,7 @@ func (maker v0ComponentMaker) Auctioneer(modifyConfigFuncs ...func(*auctioneerco
 			maker.artifacts.Executables["auctioneer"],
 			args...,
 		),
-	})
+	}))
 }
 
 func (maker v0ComponentMaker) RouteEmitter(modifyConfigFuncs ...func(config *routeemitterconfig.RouteEmitterConfig)) ifrit.Runner {
@@ -1238,7 +1857,7 @@ func (maker v0ComponentMaker) RouteEmitter(modifyConfigFuncs ...func(config *rou
 		f(&cfg)
 	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:              "route-emitter",
 		AnsiColorCode:     "36m",
 		StartCheck:        `"route-emitter.started"`,
@@ -1256,13 +1875,13 @@ func (maker v0ComponentMaker) RouteEmitter(modifyConfigFuncs ...func(config *rou
 				"-bbsCACert", cfg.BBSCACertFile,
 			}...,
 		),
-	})
+	}))
 }
 
 func (maker v0ComponentMaker) FileServer() (ifrit.Runner, string) {
 	servedFilesDir := TempDir("file-server-files")
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:              "file-server",
 		AnsiColorCode:     "92m",
 		StartCheck:        `"file-server.ready"`,
@@ -1280,7 +1899,7 @@ func (maker v0ComponentMaker) FileServer() (ifrit.Runner, string) {
 			err := os.RemoveAll(servedFilesDir)
 			Expect(err).NotTo(HaveOccurred())
 		},
-	}), servedFilesDir
+	})), servedFilesDir
 }
 
 func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) ifrit.Runner {
@@ -1293,12 +1912,9 @@ func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 		ConsulCluster:            maker.ConsulCluster(),
 		DatabaseConnectionString: maker.addresses.SQL,
 		DatabaseDriver:           maker.dbDriverName,
-		EncryptionConfig: encryption.EncryptionConfig{
-			ActiveKeyLabel: "secure-key-1",
-			EncryptionKeys: map[string]string{"secure-key-1": "secure-passphrase"},
-		},
-		HealthAddress: maker.addresses.Health,
-		ListenAddress: maker.addresses.BBS,
+		EncryptionConfig:         DefaultEncryptionConfig(),
+		HealthAddress:            maker.addresses.Health,
+		ListenAddress:            maker.addresses.BBS,
 		LagerConfig: lagerflags.LagerConfig{
 			LogLevel: "debug",
 		},
@@ -1334,7 +1950,7 @@ func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 		"-requireSSL",
 	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:              "bbs",
 		AnsiColorCode:     "32m",
 		StartCheck:        "bbs.started",
@@ -1343,14 +1959,14 @@ func (maker v0ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 			maker.artifacts.Executables["bbs"],
 			args...,
 		),
-	})
+	}))
 }
 
-func (maker v0ComponentMaker) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+func (maker v0ComponentMaker) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ComponentRunner {
 	return maker.RepN(0, modifyConfigFuncs...)
 }
 
-func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ComponentRunner {
 	host, portString, err := net.SplitHostPort(maker.addresses.Rep)
 	Expect(err).NotTo(HaveOccurred())
 	port, err := strconv.Atoi(portString)
@@ -1454,7 +2070,7 @@ func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 		args = append(args, "-preloadedRootFS", fmt.Sprintf("%s:%s", rootfs.Name, rootfs.Path))
 	}
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.track(ginkgomon.New(ginkgomon.Config{
 		Name:          name,
 		AnsiColorCode: "33m",
 		StartCheck:    `"` + name + `.started"`,
@@ -1468,7 +2084,7 @@ func (maker v0ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 		Cleanup: func() {
 			os.RemoveAll(tmpDir)
 		},
-	})
+	}))
 }
 
 func (maker v1ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig)) ifrit.Runner {
@@ -1492,14 +2108,9 @@ func (maker v1ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 		RepClientSessionCacheSize:       0,
 		RepRequireTLS:                   false,
 
-		AdvertiseURL:  maker.BBSURL(),
-		ConsulCluster: maker.ConsulCluster(),
-		EncryptionConfig: encryption.EncryptionConfig{
-			ActiveKeyLabel: "secure-key-1",
-			EncryptionKeys: map[string]string{
-				"secure-key-1": "secure-passphrase",
-			},
-		},
+		AdvertiseURL:     maker.BBSURL(),
+		ConsulCluster:    maker.ConsulCluster(),
+		EncryptionConfig: DefaultEncryptionConfig(),
 		LagerConfig: lagerflags.LagerConfig{
 			LogLevel: "debug",
 		},
@@ -1525,6 +2136,8 @@ func (maker v1ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 		SQLCACertFile:                  maker.sqlCACertFile,
 		ClientLocketConfig:             maker.locketClientConfig(),
 		UUID:                           "bbs-inigo-lock-owner",
+		LoggregatorConfig:              maker.loggregatorConfig("bbs"),
+		DebugServerConfig:              debugserver.DebugServerConfig{DebugAddress: maker.debugAddress(bbsDebugPort)},
 	}
 
 	for _, modifyConfig := range modifyConfigFuncs {
@@ -1534,14 +2147,14 @@ func (maker v1ComponentMaker) BBS(modifyConfigFuncs ...func(*bbsconfig.BBSConfig
 	runner := bbsrunner.New(maker.artifacts.Executables["bbs"], config)
 	runner.AnsiColorCode = "32m"
 	runner.StartCheckTimeout = maker.startCheckTimeout
-	return runner
+	return maker.trackWithDebugServer(runner, config.DebugAddress)
 }
 
-func (maker v1ComponentMaker) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+func (maker v1ComponentMaker) Rep(modifyConfigFuncs ...func(*repconfig.RepConfig)) *ComponentRunner {
 	return maker.RepN(0, modifyConfigFuncs...)
 }
 
-func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ginkgomon.Runner {
+func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.RepConfig)) *ComponentRunner {
 	host, portString, err := net.SplitHostPort(maker.addresses.Rep)
 	Expect(err).NotTo(HaveOccurred())
 	port, err := strconv.Atoi(portString)
@@ -1636,6 +2249,8 @@ func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 		LagerConfig: lagerflags.LagerConfig{
 			LogLevel: "debug",
 		},
+		LoggregatorConfig: maker.loggregatorConfig(name),
+		DebugServerConfig: debugserver.DebugServerConfig{DebugAddress: maker.repDebugAddress(n)},
 	}
 
 	if runtime.GOOS == "windows" {
@@ -1658,7 +2273,7 @@ func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 	err = json.NewEncoder(configFile).Encode(repConfig)
 	Expect(err).NotTo(HaveOccurred())
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.trackWithDebugServer(ginkgomon.New(ginkgomon.Config{
 		Name:          name,
 		AnsiColorCode: "33m",
 		StartCheck:    `"` + name + `.started"`,
@@ -1672,7 +2287,7 @@ func (maker v1ComponentMaker) RepN(n int, modifyConfigFuncs ...func(*repconfig.R
 			os.RemoveAll(tmpDir)
 			os.RemoveAll(healthcheckDummyDir)
 		},
-	})
+	}), repConfig.DebugAddress)
 }
 
 func (maker v1ComponentMaker) Auctioneer(modifyConfigFuncs ...func(cfg *auctioneerconfig.AuctioneerConfig)) ifrit.Runner {
@@ -1704,6 +2319,8 @@ func (maker v1ComponentMaker) Auctioneer(modifyConfigFuncs ...func(cfg *auctione
 		LocksLocketEnabled: true,
 		ClientLocketConfig: maker.locketClientConfig(),
 		UUID:               "auctioneer-inigo-lock-owner",
+		LoggregatorConfig:  maker.loggregatorConfig("auctioneer"),
+		DebugServerConfig:  debugserver.DebugServerConfig{DebugAddress: maker.debugAddress(auctioneerDebugPort)},
 	}
 
 	for _, modifyConfig := range modifyConfigFuncs {
@@ -1716,7 +2333,7 @@ func (maker v1ComponentMaker) Auctioneer(modifyConfigFuncs ...func(cfg *auctione
 	err = json.NewEncoder(configFile).Encode(auctioneerConfig)
 	Expect(err).NotTo(HaveOccurred())
 
-	return ginkgomon.New(ginkgomon.Config{
+	return maker.trackWithDebugServer(ginkgomon.New(ginkgomon.Config{
 		Name:              "auctioneer",
 		AnsiColorCode:     "35m",
 		StartCheck:        `"auctioneer.started"`,
@@ -1725,7 +2342,7 @@ func (maker v1ComponentMaker) Auctioneer(modifyConfigFuncs ...func(cfg *auctione
 			maker.artifacts.Executables["auctioneer"],
 			"-config", configFile.Name(),
 		),
-	})
+	}), auctioneerConfig.DebugAddress)
 }
 
 func (maker v1ComponentMaker) RouteEmitter(modifyConfigFuncs ...func(config *routeemitterconfig.RouteEmitterConfig)) ifrit.Runner {
@@ -1818,6 +2435,46 @@ func appendExtraConnectionStringParam(driverName, databaseConnectionString, sqlC
 	return databaseConnectionString
 }
 
+func sqlServerAddress(driverName, databaseConnectionString string) string {
+	switch driverName {
+	case "mysql":
+		cfg, err := mysql.ParseDSN(databaseConnectionString)
+		Expect(err).NotTo(HaveOccurred())
+		return cfg.Addr
+	case "postgres":
+		u, err := url.Parse(databaseConnectionString)
+		Expect(err).NotTo(HaveOccurred())
+		if u.Port() == "" {
+			return net.JoinHostPort(u.Hostname(), "5432")
+		}
+		return u.Host
+	}
+
+	Fail(fmt.Sprintf("unsupported database driver %s", driverName))
+	return ""
+}
+
+func proxiedConnectionString(driverName, databaseConnectionString, proxyAddress string) string {
+	switch driverName {
+	case "mysql":
+		cfg, err := mysql.ParseDSN(databaseConnectionString)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.Addr = proxyAddress
+		return cfg.FormatDSN()
+	case "postgres":
+		u, err := url.Parse(databaseConnectionString)
+		Expect(err).NotTo(HaveOccurred())
+		u.Host = proxyAddress
+		query := u.Query()
+		query.Set("sslmode", "disable")
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	Fail(fmt.Sprintf("unsupported database driver %s", driverName))
+	return ""
+}
+
 func intPtr(i int) *int {
 	return &i
 }
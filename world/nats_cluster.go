@@ -0,0 +1,218 @@
+package world
+
+import (
+	"fmt"
+	"strings"
+
+	routeemitterconfig "code.cloudfoundry.org/route-emitter/cmd/route-emitter/config"
+	"github.com/nats-io/nats.go"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+)
+
+// NATSConfig is how every node of a NATSCluster is started.
+type NATSConfig struct {
+	// Username and Password, when set, are required of clients.
+	Username string
+	Password string
+	// TLS, when set, requires clients to connect with TLS and present a
+	// certificate signed by the CA.
+	TLS *NATSTLSConfig
+}
+
+type NATSTLSConfig struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// ClientCertFile and ClientKeyFile are presented by the clients the
+	// cluster configures.
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+// NATSAuth has the cluster enforce the credentials, e.g. the nats:nats the
+// route emitter is configured with by default.
+func NATSAuth(username, password string) func(*NATSConfig) {
+	return func(cfg *NATSConfig) {
+		cfg.Username = username
+		cfg.Password = password
+	}
+}
+
+// NATSTLS has the cluster require TLS, serving a certificate signed by
+// CACert and verifying that clients present one too.
+func (maker commonComponentMaker) NATSTLS() func(*NATSConfig) {
+	keyFile, certFile, err := maker.certAuthority.GenerateSelfSignedCertAndKey("nats_server", nil, false)
+	Expect(err).NotTo(HaveOccurred())
+
+	return func(cfg *NATSConfig) {
+		cfg.TLS = &NATSTLSConfig{
+			CertFile:       certFile,
+			KeyFile:        keyFile,
+			CAFile:         maker.CACert(),
+			ClientCertFile: maker.bbsSSL.ClientCert,
+			ClientKeyFile:  maker.bbsSSL.ClientKey,
+		}
+	}
+}
+
+// NATSCluster is a set of gnatsd nodes routed to each other, on their own
+// ports rather than Addresses().NATS. Nodes can be killed and restarted
+// individually to test how clients fail over and reconnect.
+type NATSCluster struct {
+	maker        commonComponentMaker
+	config       NATSConfig
+	clientPorts  []int
+	clusterPorts []int
+	processes    []ifrit.Process
+}
+
+// NATSCluster creates a cluster of size nodes. Start it with Start, and stop
+// it with Stop.
+func (maker commonComponentMaker) NATSCluster(size int, modifyConfigFuncs ...func(*NATSConfig)) *NATSCluster {
+	config := NATSConfig{}
+	for _, modifyConfig := range modifyConfigFuncs {
+		modifyConfig(&config)
+	}
+
+	ports, err := maker.portAllocator.ClaimPorts(2 * size)
+	Expect(err).NotTo(HaveOccurred())
+
+	cluster := &NATSCluster{
+		maker:     maker,
+		config:    config,
+		processes: make([]ifrit.Process, size),
+	}
+	for i := 0; i < size; i++ {
+		cluster.clientPorts = append(cluster.clientPorts, int(ports)+i)
+		cluster.clusterPorts = append(cluster.clusterPorts, int(ports)+size+i)
+	}
+	return cluster
+}
+
+// Start starts every node that is not running.
+func (c *NATSCluster) Start() {
+	for i := range c.processes {
+		if c.processes[i] == nil {
+			c.StartNode(i)
+		}
+	}
+}
+
+// StartNode starts node i and waits for it to accept connections.
+func (c *NATSCluster) StartNode(i int) {
+	Expect(c.processes[i]).To(BeNil(), "NATS node %d is already running", i)
+	c.processes[i] = ginkgomon.Invoke(c.nodeRunner(i))
+}
+
+// KillNode kills node i without giving it a chance to shut down cleanly.
+func (c *NATSCluster) KillNode(i int) {
+	Expect(c.processes[i]).NotTo(BeNil(), "NATS node %d is not running", i)
+	ginkgomon.Kill(c.processes[i])
+	c.processes[i] = nil
+}
+
+// Stop stops every running node.
+func (c *NATSCluster) Stop() {
+	for i, process := range c.processes {
+		if process != nil {
+			ginkgomon.Interrupt(process)
+			c.processes[i] = nil
+		}
+	}
+}
+
+// NodeAddress is the address clients connect to node i on.
+func (c *NATSCluster) NodeAddress(i int) string {
+	return fmt.Sprintf("127.0.0.1:%d", c.clientPorts[i])
+}
+
+// Address is the comma separated addresses of every node, as the route
+// emitter and NATSRecorder take them.
+func (c *NATSCluster) Address() string {
+	addresses := []string{}
+	for i := range c.clientPorts {
+		addresses = append(addresses, c.NodeAddress(i))
+	}
+	return strings.Join(addresses, ",")
+}
+
+// ClientOptions are the options a NATS client needs to connect to the
+// cluster, and to keep reconnecting while nodes are down.
+func (c *NATSCluster) ClientOptions() []nats.Option {
+	options := []nats.Option{nats.MaxReconnects(-1)}
+	if c.config.Username != "" {
+		options = append(options, nats.UserInfo(c.config.Username, c.config.Password))
+	}
+	if c.config.TLS != nil {
+		options = append(options,
+			nats.RootCAs(c.config.TLS.CAFile),
+			nats.ClientCert(c.config.TLS.ClientCertFile, c.config.TLS.ClientKeyFile),
+		)
+	}
+	return options
+}
+
+// RouteEmitterConfig has the route emitter connect to every node of the
+// cluster.
+func (c *NATSCluster) RouteEmitterConfig(cfg *routeemitterconfig.RouteEmitterConfig) {
+	cfg.NATSAddresses = c.Address()
+	cfg.NATSUsername = c.config.Username
+	cfg.NATSPassword = c.config.Password
+	if c.config.TLS != nil {
+		cfg.NATSTLSEnabled = true
+		cfg.NATSCACertFile = c.config.TLS.CAFile
+		cfg.NATSClientCertFile = c.config.TLS.ClientCertFile
+		cfg.NATSClientKeyFile = c.config.TLS.ClientKeyFile
+	}
+}
+
+// RouterConfig has the router connect to every node of the cluster. The
+// router's NATS configuration has no TLS settings, so it cannot be used with
+// a cluster that requires TLS.
+func (c *NATSCluster) RouterConfig(cfg *RouterConfig) {
+	Expect(c.config.TLS).To(BeNil(), "the router cannot connect to NATS with TLS")
+
+	cfg.Nats = nil
+	for _, port := range c.clientPorts {
+		cfg.Nats = append(cfg.Nats, RouterNatsConfig{
+			Host: "127.0.0.1",
+			Port: uint16(port),
+			User: c.config.Username,
+			Pass: c.config.Password,
+		})
+	}
+}
+
+func (c *NATSCluster) nodeRunner(i int) *ComponentRunner {
+	argv := []string{
+		"--cluster", fmt.Sprintf("nats://127.0.0.1:%d", c.clusterPorts[i]),
+	}
+
+	routes := []string{}
+	for j, port := range c.clusterPorts {
+		if j != i {
+			routes = append(routes, fmt.Sprintf("nats://127.0.0.1:%d", port))
+		}
+	}
+	if len(routes) > 0 {
+		argv = append(argv, "--routes", strings.Join(routes, ","))
+	}
+
+	if c.config.Username != "" {
+		argv = append(argv, "--user", c.config.Username, "--pass", c.config.Password)
+	}
+
+	if c.config.TLS != nil {
+		argv = append(argv,
+			"--tls",
+			"--tlscert", c.config.TLS.CertFile,
+			"--tlskey", c.config.TLS.KeyFile,
+			"--tlscacert", c.config.TLS.CAFile,
+			"--tlsverify",
+		)
+	}
+
+	return c.maker.natsRunner(fmt.Sprintf("gnatsd-%d", i), "127.0.0.1", fmt.Sprint(c.clientPorts[i]), argv...)
+}
@@ -0,0 +1,173 @@
+package world
+
+import (
+	"time"
+)
+
+const (
+	RouterRoundRobin      = "round-robin"
+	RouterLeastConnection = "least-connection"
+)
+
+// RouterConfig is the gorouter configuration. It only has the settings inigo
+// uses; gorouter leaves the ones it is not given at their defaults.
+type RouterConfig struct {
+	Status  RouterStatusConfig  `yaml:"status"`
+	Nats    []RouterNatsConfig  `yaml:"nats"`
+	Logging RouterLoggingConfig `yaml:"logging"`
+
+	Port  uint16 `yaml:"port"`
+	Index uint   `yaml:"index"`
+	Zone  string `yaml:"zone"`
+
+	Tracing                  RouterTracingConfig   `yaml:"tracing"`
+	TraceKey                 string                `yaml:"trace_key"`
+	AccessLog                RouterAccessLogConfig `yaml:"access_log"`
+	EnableAccessLogStreaming bool                  `yaml:"enable_access_log_streaming"`
+	DebugAddr                string                `yaml:"debug_addr"`
+	EnableProxy              bool                  `yaml:"enable_proxy"`
+
+	EnableSSL            bool           `yaml:"enable_ssl"`
+	SSLPort              uint16         `yaml:"ssl_port"`
+	TLSPEM               []RouterTLSPEM `yaml:"tls_pem,omitempty"`
+	MinTLSVersion        string         `yaml:"min_tls_version,omitempty"`
+	ClientCertValidation string         `yaml:"client_cert_validation,omitempty"`
+	CipherSuites         string         `yaml:"cipher_suites"`
+	EnableHTTP2          bool           `yaml:"enable_http2,omitempty"`
+
+	// CACerts are the PEM encoded certificates backends are validated with.
+	CACerts           string               `yaml:"ca_certs,omitempty"`
+	SkipSSLValidation bool                 `yaml:"skip_ssl_validation"`
+	Backends          RouterBackendsConfig `yaml:"backends,omitempty"`
+
+	BalancingAlgorithm       string   `yaml:"balancing_algorithm,omitempty"`
+	StickySessionCookieNames []string `yaml:"sticky_session_cookie_names,omitempty"`
+	SecureCookies            bool     `yaml:"secure_cookies"`
+
+	LoadBalancerHealthyThreshold    time.Duration `yaml:"load_balancer_healthy_threshold"`
+	PublishStartMessageInterval     time.Duration `yaml:"publish_start_message_interval"`
+	SuspendPruningIfNatsUnavailable bool          `yaml:"suspend_pruning_if_nats_unavailable"`
+	PruneStaleDropletsInterval      time.Duration `yaml:"prune_stale_droplets_interval"`
+	DropletStaleThreshold           time.Duration `yaml:"droplet_stale_threshold"`
+	PublishActiveAppsInterval       time.Duration `yaml:"publish_active_apps_interval"`
+	StartResponseDelayInterval      time.Duration `yaml:"start_response_delay_interval"`
+	EndpointTimeout                 time.Duration `yaml:"endpoint_timeout"`
+	RouteServicesTimeout            time.Duration `yaml:"route_services_timeout"`
+
+	OAuth      RouterOAuthConfig      `yaml:"oauth"`
+	RoutingAPI RouterRoutingAPIConfig `yaml:"routing_api"`
+
+	RouteServicesSecret            string `yaml:"route_services_secret"`
+	RouteServicesSecretDecryptOnly string `yaml:"route_services_secret_decrypt_only"`
+	RouteServicesRecommendHTTPS    bool   `yaml:"route_services_recommend_https"`
+
+	ExtraHeadersToLog                []string      `yaml:"extra_headers_to_log"`
+	TokenFetcherMaxRetries           uint32        `yaml:"token_fetcher_max_retries"`
+	TokenFetcherRetryInterval        time.Duration `yaml:"token_fetcher_retry_interval"`
+	TokenFetcherExpirationBufferTime int64         `yaml:"token_fetcher_expiration_buffer_time"`
+	PidFile                          string        `yaml:"pid_file"`
+}
+
+type RouterStatusConfig struct {
+	Port uint16 `yaml:"port"`
+	User string `yaml:"user"`
+	Pass string `yaml:"pass"`
+}
+
+type RouterNatsConfig struct {
+	Host string `yaml:"host"`
+	Port uint16 `yaml:"port"`
+	User string `yaml:"user"`
+	Pass string `yaml:"pass"`
+}
+
+type RouterLoggingConfig struct {
+	File               string `yaml:"file"`
+	Syslog             string `yaml:"syslog"`
+	Level              string `yaml:"level"`
+	LoggregatorEnabled bool   `yaml:"loggregator_enabled"`
+	MetronAddress      string `yaml:"metron_address"`
+}
+
+type RouterTracingConfig struct {
+	EnableZipkin bool `yaml:"enable_zipkin"`
+}
+
+type RouterAccessLogConfig struct {
+	File            string `yaml:"file"`
+	EnableStreaming bool   `yaml:"enable_streaming"`
+}
+
+// RouterTLSPEM is a PEM encoded certificate chain and its private key.
+type RouterTLSPEM struct {
+	CertChain  string `yaml:"cert_chain"`
+	PrivateKey string `yaml:"private_key"`
+}
+
+type RouterBackendsConfig struct {
+	EnableTLS bool `yaml:"enable_tls"`
+	// TLSPEM is the client certificate presented to backends that require
+	// one.
+	TLSPEM   RouterTLSPEM `yaml:"tls_pem,omitempty"`
+	MaxConns int64        `yaml:"max_conns,omitempty"`
+}
+
+type RouterOAuthConfig struct {
+	TokenEndpoint     string `yaml:"token_endpoint"`
+	Port              int    `yaml:"port"`
+	SkipSSLValidation bool   `yaml:"skip_ssl_validation"`
+	ClientName        string `yaml:"client_name"`
+	ClientSecret      string `yaml:"client_secret"`
+	CACerts           string `yaml:"ca_certs"`
+}
+
+type RouterRoutingAPIConfig struct {
+	URI          string `yaml:"uri"`
+	Port         int    `yaml:"port"`
+	AuthDisabled bool   `yaml:"auth_disabled"`
+}
+
+// RouterAccessLog has the router write its access log to path.
+func RouterAccessLog(path string) func(*RouterConfig) {
+	return func(cfg *RouterConfig) {
+		cfg.AccessLog.File = path
+	}
+}
+
+// RouterRoutingAPI has the router fetch TCP router groups and routes from the
+// routing API listening on port, e.g. RoutingAPI().Config.API.ListenPort.
+func RouterRoutingAPI(port int) func(*RouterConfig) {
+	return func(cfg *RouterConfig) {
+		cfg.RoutingAPI = RouterRoutingAPIConfig{
+			URI:          "http://127.0.0.1",
+			Port:         port,
+			AuthDisabled: true,
+		}
+	}
+}
+
+// RouterBalancingAlgorithm selects how the router balances requests across
+// the endpoints of a route, RouterRoundRobin or RouterLeastConnection.
+func RouterBalancingAlgorithm(algorithm string) func(*RouterConfig) {
+	return func(cfg *RouterConfig) {
+		cfg.BalancingAlgorithm = algorithm
+	}
+}
+
+// RouterStickySessions has the router pin clients that send any of the
+// cookies to the endpoint that set it. Without it the router only honors
+// JSESSIONID.
+func RouterStickySessions(cookieNames ...string) func(*RouterConfig) {
+	return func(cfg *RouterConfig) {
+		cfg.StickySessionCookieNames = cookieNames
+	}
+}
+
+// TCPRouterConfig is the TCP router configuration.
+type TCPRouterConfig struct {
+	OAuth                        RouterOAuthConfig      `yaml:"oauth"`
+	RoutingAPI                   RouterRoutingAPIConfig `yaml:"routing_api"`
+	HAProxyPIDFile               string                 `yaml:"haproxy_pid_file"`
+	IsolationSegments            []string               `yaml:"isolation_segments"`
+	ReservedSystemComponentPorts []int                  `yaml:"reserved_system_component_ports"`
+}
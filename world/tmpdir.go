@@ -3,10 +3,16 @@ package world
 import (
 	"io/ioutil"
 	"os"
+	"sync"
 
 	. "github.com/onsi/gomega"
 )
 
+var (
+	tempDirsLock sync.Mutex
+	tempDirs     []string
+)
+
 func TempDir(prefix string) string {
 	tmpDir, err := ioutil.TempDir(os.TempDir(), prefix)
 	Expect(err).NotTo(HaveOccurred())
@@ -14,5 +20,25 @@ func TempDir(prefix string) string {
 	err = os.Chmod(tmpDir, 0777)
 	Expect(err).NotTo(HaveOccurred())
 
+	tempDirsLock.Lock()
+	tempDirs = append(tempDirs, tmpDir)
+	tempDirsLock.Unlock()
+
 	return tmpDir
 }
+
+// TempDirs returns the directories created by TempDir that still exist.
+func TempDirs() []string {
+	tempDirsLock.Lock()
+	defer tempDirsLock.Unlock()
+
+	existing := []string{}
+	for _, dir := range tempDirs {
+		if _, err := os.Stat(dir); err == nil {
+			existing = append(existing, dir)
+		}
+	}
+	tempDirs = existing
+
+	return append([]string{}, existing...)
+}